@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	s3cp "github.com/reedobrien/s3cp/lib"
+)
+
+// compose concatenates source objects, e.g. log shards, into one object.
+func compose(args []string) {
+	fs := flag.NewFlagSet("compose", flag.ExitOnError)
+	contentType := fs.String("contentType", "", "The Content-Type of the destination.")
+	dest := fs.String("dest", "", "The destination as bucket/key or s3://bucket/key.")
+	expectedBucketOwner := fs.String("expectedBucketOwner", "", "If set, the account ID that must own the destination bucket.")
+	expectedSourceBucketOwner := fs.String("expectedSourceBucketOwner", "", "If set, the account ID that must own the source buckets.")
+	manifest := fs.String("manifest", "", "A file listing the sources in order, one per line, or - for stdin. Added after any -source.")
+	var sources stringsFlag
+	fs.Var(&sources, "source", "A source as bucket/key or s3://bucket/key. Repeat in order.")
+	client := addClientFlags(fs)
+	logs := addLogFlags(fs)
+	sse := addEncryptionFlags(fs)
+	fs.Parse(args)
+
+	logger, err := logs.logger()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *manifest != "" {
+		lines, err := readManifest(*manifest)
+		if err != nil {
+			log.Fatal(err)
+		}
+		sources = append(sources, lines...)
+	}
+	if *dest == "" || len(sources) == 0 {
+		log.Fatal("compose requires a dest and at least one source")
+	}
+
+	in := s3cp.ComposeInput{}
+	in.Destination, err = s3cp.ParseLocation(*dest)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, s := range sources {
+		loc, err := s3cp.ParseLocation(s)
+		if err != nil {
+			log.Fatal(err)
+		}
+		in.Sources = append(in.Sources, loc)
+	}
+
+	in.Template.Encryption, in.Template.SourceEncryption, err = sse.encryption()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *contentType != "" {
+		in.Template.COI.ContentType = contentType
+	}
+	if *expectedBucketOwner != "" {
+		in.Template.COI.ExpectedBucketOwner = expectedBucketOwner
+	}
+	if *expectedSourceBucketOwner != "" {
+		in.Template.COI.ExpectedSourceBucketOwner = expectedSourceBucketOwner
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	copier, err := client.copier(logger)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := copier.Compose(ctx, in); err != nil {
+		logger.Error("compose failed", "dest", *dest, "error", err)
+		os.Exit(1)
+	}
+	logger.Info("composed", "dest", *dest, "sources", len(in.Sources))
+}
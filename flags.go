@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	s3cp "github.com/reedobrien/s3cp/lib"
+)
+
+// logFlags are the logging flags shared by the commands.
+type logFlags struct {
+	json  *bool
+	level *string
+}
+
+func addLogFlags(fs *flag.FlagSet) *logFlags {
+	return &logFlags{
+		json:  fs.Bool("logJSON", false, "Set to true to log in JSON."),
+		level: fs.String("logLevel", "info", "The log level: debug, info, warn or error."),
+	}
+}
+
+// logger returns a logger writing to stderr at the flagged level, as text or
+// JSON.
+func (f *logFlags) logger() (*slog.Logger, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(*f.level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q", *f.level)
+	}
+
+	opts := &slog.HandlerOptions{Level: l}
+	if *f.json {
+		return slog.New(slog.NewJSONHandler(os.Stderr, opts)), nil
+	}
+	return slog.New(slog.NewTextHandler(os.Stderr, opts)), nil
+}
+
+// clientFlags are the S3 client flags shared by the subcommands.
+type clientFlags struct {
+	metricsAddr *string
+	region      *string
+}
+
+func addClientFlags(fs *flag.FlagSet) *clientFlags {
+	return &clientFlags{
+		metricsAddr: fs.String("metricsAddr", "", "If set, serve Prometheus metrics on /metrics and expvar on /debug/vars at this address."),
+		region:      fs.String("region", "", "The region of the destination bucket. If empty it is discovered."),
+	}
+}
+
+// copier returns a Copier logging to logger whose default client is in the
+// flagged region, or the one sessionRegion picks. Its metrics are served if
+// metricsAddr is set.
+func (f *clientFlags) copier(logger *slog.Logger) (*s3cp.Copier, error) {
+	c, err := newCopier(*f.region, logger)
+	if err != nil {
+		return nil, err
+	}
+	if *f.metricsAddr != "" {
+		m, err := serveMetrics(*f.metricsAddr, logger)
+		if err != nil {
+			return nil, err
+		}
+		c.Metrics = m
+	}
+	return c, nil
+}
+
+// encryptionFlags are the server side encryption flags shared by the
+// commands.
+type encryptionFlags struct {
+	srcSSECustomerKey *string
+	sse               *string
+	sseBucketKey      *bool
+	sseCustomerKey    *string
+	sseKMSContext     *string
+	sseKMSKeyID       *string
+}
+
+func addEncryptionFlags(fs *flag.FlagSet) *encryptionFlags {
+	return &encryptionFlags{
+		srcSSECustomerKey: fs.String("srcSSECustomerKey", "", "The base64 encoded SSE-C key of the source object."),
+		sse:               fs.String("sse", "", "The destination server side encryption: AES256, aws:kms or SSE-C."),
+		sseBucketKey:      fs.Bool("sseBucketKey", false, "Set to true to use an S3 Bucket Key with aws:kms."),
+		sseCustomerKey:    fs.String("sseCustomerKey", "", "The base64 encoded SSE-C key for the destination object."),
+		sseKMSContext:     fs.String("sseKMSContext", "", "The aws:kms encryption context as comma separated key=value pairs."),
+		sseKMSKeyID:       fs.String("sseKMSKeyID", "", "The aws:kms key ID or ARN. Defaults to the AWS managed key."),
+	}
+}
+
+// encryption returns the destination and source encryption set by the
+// flags. Either is nil if no flags for it are set.
+func (f *encryptionFlags) encryption() (dst, src *s3cp.Encryption, err error) {
+	if *f.srcSSECustomerKey != "" {
+		key, err := base64.StdEncoding.DecodeString(*f.srcSSECustomerKey)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid srcSSECustomerKey: %s", err)
+		}
+		src = &s3cp.Encryption{Type: s3cp.SSEC, CustomerKey: key}
+	}
+
+	if *f.sse == "" && *f.sseKMSKeyID == "" && *f.sseKMSContext == "" && !*f.sseBucketKey && *f.sseCustomerKey == "" {
+		return nil, src, nil
+	}
+
+	dst = &s3cp.Encryption{
+		Type:      *f.sse,
+		KMSKeyID:  *f.sseKMSKeyID,
+		BucketKey: *f.sseBucketKey,
+	}
+
+	if *f.sseCustomerKey != "" {
+		dst.CustomerKey, err = base64.StdEncoding.DecodeString(*f.sseCustomerKey)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid sseCustomerKey: %s", err)
+		}
+	}
+
+	dst.KMSContext, err = parsePairs("sseKMSContext", *f.sseKMSContext)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return dst, src, dst.Validate()
+}
+
+// parsePairs parses the comma separated key=value pairs of the flag name.
+// It returns nil if s is empty.
+func parsePairs(name, s string) (map[string]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	m := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid %s pair %q", name, pair)
+		}
+		m[kv[0]] = kv[1]
+	}
+	return m, nil
+}
+
+// filterFlags are the bulk operations' Filter flags.
+type filterFlags struct {
+	exclude        stringsFlag
+	excludeRegexp  stringsFlag
+	include        stringsFlag
+	includeRegexp  stringsFlag
+	maxSize        *int64
+	minSize        *int64
+	modifiedAfter  *string
+	modifiedBefore *string
+	storageClasses *string
+	tags           *string
+}
+
+func addFilterFlags(fs *flag.FlagSet) *filterFlags {
+	f := &filterFlags{
+		maxSize:        fs.Int64("maxSize", 0, "If set, leave out objects larger than this many bytes."),
+		minSize:        fs.Int64("minSize", 0, "Leave out objects smaller than this many bytes."),
+		modifiedAfter:  fs.String("modifiedAfter", "", "If set, only objects modified after this RFC 3339 date."),
+		modifiedBefore: fs.String("modifiedBefore", "", "If set, only objects modified before this RFC 3339 date."),
+		storageClasses: fs.String("storageClasses", "", "If set, only objects in these comma separated storage classes."),
+		tags:           fs.String("withTags", "", "If set, only objects with these comma separated key=value tags. Costs a request per object."),
+	}
+	fs.Var(&f.exclude, "exclude", "Leave out keys matching this glob, relative to the prefix. May be repeated.")
+	fs.Var(&f.excludeRegexp, "excludeRegexp", "Leave out keys matching this regular expression, relative to the prefix. May be repeated.")
+	fs.Var(&f.include, "include", "Only keys matching this glob, relative to the prefix, e.g. '*.csv' or 'logs/**'. May be repeated.")
+	fs.Var(&f.includeRegexp, "includeRegexp", "Only keys matching this regular expression, relative to the prefix. May be repeated.")
+	return f
+}
+
+// filter returns the Filter set by the flags, or nil if none are set.
+func (f *filterFlags) filter() (*s3cp.Filter, error) {
+	if len(f.exclude)+len(f.excludeRegexp)+len(f.include)+len(f.includeRegexp) == 0 &&
+		*f.maxSize == 0 && *f.minSize == 0 && *f.modifiedAfter == "" && *f.modifiedBefore == "" &&
+		*f.storageClasses == "" && *f.tags == "" {
+		return nil, nil
+	}
+
+	filter := &s3cp.Filter{
+		Exclude:       f.exclude,
+		ExcludeRegexp: f.excludeRegexp,
+		Include:       f.include,
+		IncludeRegexp: f.includeRegexp,
+		MaxSize:       *f.maxSize,
+		MinSize:       *f.minSize,
+	}
+	if *f.storageClasses != "" {
+		filter.StorageClasses = strings.Split(*f.storageClasses, ",")
+	}
+
+	for _, t := range []struct {
+		name string
+		s    string
+		into *time.Time
+	}{
+		{"modifiedAfter", *f.modifiedAfter, &filter.ModifiedAfter},
+		{"modifiedBefore", *f.modifiedBefore, &filter.ModifiedBefore},
+	} {
+		if t.s == "" {
+			continue
+		}
+		v, err := time.Parse(time.RFC3339, t.s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %s", t.name, err)
+		}
+		*t.into = v
+	}
+
+	var err error
+	filter.Tags, err = parsePairs("withTags", *f.tags)
+	if err != nil {
+		return nil, err
+	}
+
+	return filter, filter.Validate()
+}
+
+// stringsFlag is a flag that may be repeated.
+type stringsFlag []string
+
+// newStringsFlag defines a stringsFlag on fs.
+func newStringsFlag(fs *flag.FlagSet, name, usage string) *stringsFlag {
+	s := new(stringsFlag)
+	fs.Var(s, name, usage)
+	return s
+}
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
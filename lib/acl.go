@@ -0,0 +1,158 @@
+package s3cp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// ACL describes the access control of a copy's destination. Only one of
+// Canned, the grants or FromSource may be set.
+type ACL struct {
+	// Canned is a canned ACL, e.g. s3.ObjectCannedACLBucketOwnerFullControl
+	// so a destination bucket's owner can read what we write.
+	Canned string
+
+	// Explicit grants, in the x-amz-grant-* header format, e.g.
+	// id="canonical-user-id", uri="group-uri" or emailAddress="address",
+	// comma separated.
+	GrantFullControl string
+	GrantRead        string
+	GrantReadACP     string
+	GrantWriteACP    string
+
+	// FromSource copies the source's grants onto the destination once the
+	// copy completes.
+	FromSource bool
+}
+
+// Validate checks only one kind of ACL is set.
+func (a *ACL) Validate() error {
+	n := 0
+	if a.Canned != "" {
+		n++
+	}
+	if a.GrantFullControl != "" || a.GrantRead != "" || a.GrantReadACP != "" || a.GrantWriteACP != "" {
+		n++
+	}
+	if a.FromSource {
+		n++
+	}
+	if n > 1 {
+		return errors.New("only one of a canned ACL, grants or the source ACL may be used")
+	}
+	return nil
+}
+
+// applyACL validates the CopyInput's ACL and sets the corresponding fields of
+// the CopyObjectInput.
+func (c *copier) applyACL() error {
+	a := c.in.ACL
+	if a == nil {
+		return nil
+	}
+	if err := a.Validate(); err != nil {
+		return fmt.Errorf("acl: %s", err)
+	}
+
+	if a.Canned != "" {
+		c.in.COI.ACL = aws.String(a.Canned)
+	}
+	setString(&c.in.COI.GrantFullControl, a.GrantFullControl)
+	setString(&c.in.COI.GrantRead, a.GrantRead)
+	setString(&c.in.COI.GrantReadACP, a.GrantReadACP)
+	setString(&c.in.COI.GrantWriteACP, a.GrantWriteACP)
+	return nil
+}
+
+// grantsACL returns an ACL setting grants with the x-amz-grant-* headers, so
+// a copy can write them along with the object. It returns false if any grant
+// can't be set that way, e.g. WRITE, which objects don't have.
+func grantsACL(grants []*s3.Grant) (*ACL, bool) {
+	headers := make(map[string][]string)
+	for _, g := range grants {
+		if g.Grantee == nil {
+			return nil, false
+		}
+		var grantee string
+		switch aws.StringValue(g.Grantee.Type) {
+		case s3.TypeCanonicalUser:
+			grantee = fmt.Sprintf("id=%q", aws.StringValue(g.Grantee.ID))
+		case s3.TypeGroup:
+			grantee = fmt.Sprintf("uri=%q", aws.StringValue(g.Grantee.URI))
+		case s3.TypeAmazonCustomerByEmail:
+			grantee = fmt.Sprintf("emailAddress=%q", aws.StringValue(g.Grantee.EmailAddress))
+		default:
+			return nil, false
+		}
+		switch p := aws.StringValue(g.Permission); p {
+		case s3.PermissionFullControl, s3.PermissionRead, s3.PermissionReadAcp, s3.PermissionWriteAcp:
+			headers[p] = append(headers[p], grantee)
+		default:
+			return nil, false
+		}
+	}
+	return &ACL{
+		GrantFullControl: strings.Join(headers[s3.PermissionFullControl], ", "),
+		GrantRead:        strings.Join(headers[s3.PermissionRead], ", "),
+		GrantReadACP:     strings.Join(headers[s3.PermissionReadAcp], ", "),
+		GrantWriteACP:    strings.Join(headers[s3.PermissionWriteAcp], ", "),
+	}, true
+}
+
+// copySourceACL puts the source's grants on the destination if the ACL asks
+// for it. The destination keeps its own owner, which may be another account.
+func (c *copier) copySourceACL() error {
+	if c.in.ACL == nil || !c.in.ACL.FromSource {
+		return nil
+	}
+
+	source, err := c.sourceLocation()
+	if err != nil {
+		return err
+	}
+	ctx, opts, done := c.startCall("GetObjectAcl")
+	src, err := c.cfg.SrcS3.GetObjectAclWithContext(ctx, &s3.GetObjectAclInput{
+		Bucket:              aws.String(source.Bucket),
+		ExpectedBucketOwner: c.in.COI.ExpectedSourceBucketOwner,
+		Key:                 aws.String(source.Key),
+		RequestPayer:        c.in.SourceRequestPayer,
+		VersionId:           optional(source.VersionID),
+	}, opts...)
+	done(err)
+	if err != nil {
+		return fmt.Errorf("error getting source acl: %s", err)
+	}
+
+	ctx, opts, done = c.startCall("GetObjectAcl")
+	dst, err := c.cfg.S3.GetObjectAclWithContext(ctx, &s3.GetObjectAclInput{
+		Bucket:              c.in.COI.Bucket,
+		ExpectedBucketOwner: c.in.COI.ExpectedBucketOwner,
+		Key:                 c.in.COI.Key,
+		RequestPayer:        c.in.COI.RequestPayer,
+	}, opts...)
+	done(err)
+	if err != nil {
+		return fmt.Errorf("error getting destination acl: %s", err)
+	}
+
+	ctx, opts, done = c.startCall("PutObjectAcl")
+	_, err = c.cfg.S3.PutObjectAclWithContext(ctx, &s3.PutObjectAclInput{
+		AccessControlPolicy: &s3.AccessControlPolicy{
+			Grants: src.Grants,
+			Owner:  dst.Owner,
+		},
+		Bucket:              c.in.COI.Bucket,
+		ExpectedBucketOwner: c.in.COI.ExpectedBucketOwner,
+		Key:                 c.in.COI.Key,
+		RequestPayer:        c.in.COI.RequestPayer,
+	}, opts...)
+	done(err)
+	if err != nil {
+		return fmt.Errorf("error putting destination acl: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package s3cp_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/reedobrien/checkers"
+	s3cp "github.com/reedobrien/s3cp/lib"
+	"github.com/reedobrien/s3cp/lib/dummy"
+)
+
+const partnerAccount = "444455556666"
+
+func TestACLValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		acl  s3cp.ACL
+		err  string
+	}{
+		{name: "none"},
+		{name: "canned", acl: s3cp.ACL{Canned: s3.ObjectCannedACLBucketOwnerFullControl}},
+		{name: "grants", acl: s3cp.ACL{GrantRead: `id="a"`, GrantReadACP: `id="b"`}},
+		{name: "source", acl: s3cp.ACL{FromSource: true}},
+		{
+			name: "canned and grants",
+			acl:  s3cp.ACL{Canned: s3.ObjectCannedACLPrivate, GrantRead: `id="a"`},
+			err:  "only one of a canned ACL, grants or the source ACL may be used",
+		},
+		{
+			name: "grants and source",
+			acl:  s3cp.ACL{FromSource: true, GrantWriteACP: `id="a"`},
+			err:  "only one of a canned ACL, grants or the source ACL may be used",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.acl.Validate()
+			if tc.err == "" {
+				checkers.OK(t, err)
+				return
+			}
+			checkers.Equals(t, err.Error(), tc.err)
+		})
+	}
+}
+
+func newPartnerFake() *dummy.Fake {
+	fake := dummy.NewFakeWith(dummy.Buckets{
+		"src":     {"key": {Data: thirtyBytes, Grants: []*s3.Grant{readGrant}}},
+		"partner": nil,
+	})
+	fake.SetBucketOwner("partner", partnerAccount)
+	return fake
+}
+
+// partnerInput copies src/key into the partner's bucket.
+var partnerInput = s3cp.CopyInput{
+	COI: s3.CopyObjectInput{
+		Bucket:                    aws.String("partner"),
+		CopySource:                aws.String("src/key"),
+		ExpectedBucketOwner:       aws.String(partnerAccount),
+		ExpectedSourceBucketOwner: aws.String(dummy.FakeAccountID),
+		Key:                       aws.String("key"),
+	},
+}
+
+func TestCopyACL(t *testing.T) {
+	fullControl := func(id *string) *s3.Grant {
+		return &s3.Grant{
+			Grantee:    &s3.Grantee{ID: id, Type: aws.String(s3.TypeCanonicalUser)},
+			Permission: aws.String(s3.PermissionFullControl),
+		}
+	}
+	for _, tc := range []struct {
+		name     string
+		acl      s3cp.ACL
+		partSize int64
+		canned   string
+		grants   []*s3.Grant
+		getACLs  int
+		putACLs  int
+	}{
+		{
+			name:     "canned multipart",
+			acl:      s3cp.ACL{Canned: s3.ObjectCannedACLBucketOwnerFullControl},
+			partSize: 10,
+			canned:   s3.ObjectCannedACLBucketOwnerFullControl,
+			grants:   []*s3.Grant{fullControl(dummy.FakeOwner.ID), fullControl(aws.String(partnerAccount))},
+		},
+		{
+			// Grant headers replace the writer's full control, as in S3.
+			name:   "explicit grants",
+			acl:    s3cp.ACL{GrantRead: `id="partner-id"`},
+			grants: []*s3.Grant{readGrant},
+		},
+		{
+			name:     "source multipart",
+			acl:      s3cp.ACL{FromSource: true},
+			partSize: 10,
+			grants:   []*s3.Grant{readGrant},
+			getACLs:  2,
+			putACLs:  1,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := newPartnerFake()
+			tut := s3cp.NewCopier(fake, func(c *s3cp.Copier) {
+				if tc.partSize != 0 {
+					c.PartSize = tc.partSize
+				}
+			})
+
+			in := partnerInput
+			in.ACL = &tc.acl
+			err := tut.Copy(in)
+			checkers.OK(t, err)
+
+			got := fake.Object("partner", "key")
+			checkers.Equals(t, got.ACL, tc.canned)
+			checkers.Equals(t, got.Grants, tc.grants)
+			checkers.Equals(t, fake.Calls("GetObjectAcl"), tc.getACLs)
+			checkers.Equals(t, fake.Calls("PutObjectAcl"), tc.putACLs)
+		})
+	}
+}
+
+func TestCopyUnexpectedBucketOwner(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		in   func(*s3cp.CopyInput)
+	}{
+		{"destination", func(in *s3cp.CopyInput) { in.COI.ExpectedBucketOwner = aws.String("999999999999") }},
+		{"source", func(in *s3cp.CopyInput) { in.COI.ExpectedSourceBucketOwner = aws.String("999999999999") }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := newPartnerFake()
+			tut := s3cp.NewCopier(fake, func(c *s3cp.Copier) { c.PartSize = 10 })
+
+			in := partnerInput
+			tc.in(&in)
+			err := tut.Copy(in)
+
+			checkers.Assert(t, err != nil, "expected an error")
+			checkers.Assert(t, strings.Contains(err.Error(), "AccessDenied"), "expected AccessDenied, got %s", err)
+			checkers.Assert(t, fake.Object("partner", "key") == nil, "expected nothing written")
+		})
+	}
+}
+
+func TestCopyExpectedOwnerOnEveryCall(t *testing.T) {
+	fake := newPartnerFake()
+	fake.EnableObjectLock("partner")
+	fake.RequireExpectedOwner = true
+	tut := s3cp.NewCopier(fake, func(c *s3cp.Copier) { c.PartSize = 10 })
+
+	in := partnerInput
+	in.ACL = &s3cp.ACL{FromSource: true}
+	in.Delete = true
+	in.ObjectLock = &s3cp.ObjectLock{FromSource: true, LegalHold: true}
+
+	err := tut.CopyWithContext(context.Background(), in)
+	checkers.OK(t, err)
+	checkers.Equals(t, fake.Object("partner", "key").Data, thirtyBytes)
+	checkers.Assert(t, fake.Object("src", "key") == nil, "expected the source to be deleted")
+}
@@ -0,0 +1,159 @@
+package s3cp
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// DefaultObjectConcurrency is the number of objects bulk operations work on
+// at once. Each object's copy has its own part Concurrency.
+const DefaultObjectConcurrency = 8
+
+// ObjectResult is the outcome of a bulk operation on one object.
+type ObjectResult struct {
+	Key string
+
+	// Skipped is true if the object needed no change.
+	Skipped bool
+
+	// Filtered is true if the object was not selected by the Filter.
+	Filtered bool
+
+	Err error
+}
+
+// Summary counts the outcomes of a bulk operation.
+type Summary struct {
+	Copied   int
+	Skipped  int
+	Filtered int
+	Failed   int
+}
+
+func (s *Summary) add(r ObjectResult) {
+	switch {
+	case r.Err != nil:
+		s.Failed++
+	case r.Filtered:
+		s.Filtered++
+	case r.Skipped:
+		s.Skipped++
+	default:
+		s.Copied++
+	}
+}
+
+// bulk runs an operation on the objects of a bulk job.
+type bulk struct {
+	mu       sync.Mutex
+	sum      Summary
+	progress func(ObjectResult)
+}
+
+// report adds r to the summary and passes it to progress.
+func (b *bulk) report(r ObjectResult) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.sum.add(r)
+	if b.progress != nil {
+		b.progress(r)
+	}
+}
+
+// run calls do with each object produce sends, n at a time, and returns
+// produce's error. produce may report the objects it leaves out.
+func (b *bulk) run(ctx aws.Context, n int, produce func(ctx aws.Context, out chan<- *s3.Object) error, do func(ctx aws.Context, o *s3.Object) ObjectResult) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	objects := make(chan *s3.Object)
+	listErr := make(chan error, 1)
+	go func() {
+		defer close(objects)
+		listErr <- produce(ctx, objects)
+	}()
+
+	if n <= 0 {
+		n = DefaultObjectConcurrency
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			for o := range objects {
+				b.report(do(ctx, o))
+			}
+		}()
+	}
+	wg.Wait()
+
+	return <-listErr
+}
+
+// produceObjects sends an object for each of keys or, if it is empty, the
+// objects listed under prefix that f selects, to out until done or ctx is
+// cancelled. The objects f leaves out are reported to b. owner, if set, is
+// the account expected to own the bucket and payer confirms we pay for
+// requests to a requester pays bucket.
+func (c Copier) produceObjects(ctx aws.Context, b *bulk, bucket, prefix, owner string, payer *string, keys []string, f *filter, out chan<- *s3.Object) error {
+	send := func(o *s3.Object) bool {
+		select {
+		case out <- o:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	if len(keys) > 0 {
+		for _, k := range keys {
+			if !send(&s3.Object{Key: aws.String(k)}) {
+				return ctx.Err()
+			}
+		}
+		return nil
+	}
+
+	return c.listObjects(ctx, bucket, prefix, owner, payer, func(o *s3.Object) bool {
+		if !f.listed(prefix, o) {
+			b.report(ObjectResult{Key: aws.StringValue(o.Key), Filtered: true})
+			return true
+		}
+		return send(o)
+	})
+}
+
+// listObjects calls fn with each object under prefix until fn returns false.
+// owner, if set, is the account expected to own the bucket and payer
+// confirms we pay for requests to a requester pays bucket.
+func (c Copier) listObjects(ctx aws.Context, bucket, prefix, owner string, payer *string, fn func(*s3.Object) bool) error {
+	return c.listObjectsAfter(ctx, bucket, prefix, "", owner, payer, fn)
+}
+
+// listObjectsAfter is listObjects starting after the key startAfter, if set.
+func (c Copier) listObjectsAfter(ctx aws.Context, bucket, prefix, startAfter, owner string, payer *string, fn func(*s3.Object) bool) error {
+	loi := &s3.ListObjectsV2Input{
+		Bucket:              aws.String(bucket),
+		ExpectedBucketOwner: optional(owner),
+		RequestPayer:        payer,
+		StartAfter:          optional(startAfter),
+	}
+	if prefix != "" {
+		loi.Prefix = aws.String(prefix)
+	}
+
+	return c.S3.ListObjectsV2PagesWithContext(ctx, loi, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		for _, o := range page.Contents {
+			if !fn(o) {
+				return false
+			}
+		}
+		return true
+	}, c.RequestOptions...)
+}
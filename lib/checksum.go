@@ -0,0 +1,127 @@
+package s3cp
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// applyChecksum sets the COI's ChecksumAlgorithm from the input's Checksum
+// and checks it is known.
+func (c *copier) applyChecksum() error {
+	if c.in.Checksum != "" {
+		c.in.COI.ChecksumAlgorithm = aws.String(strings.ToUpper(c.in.Checksum))
+	}
+	if alg := c.checksumAlgorithm(); alg != "" && newChecksumHash(alg) == nil {
+		return fmt.Errorf("invalid checksum algorithm %q, want CRC32, CRC32C, SHA1 or SHA256", alg)
+	}
+	return nil
+}
+
+// checksumAlgorithm returns the additional checksum algorithm of the
+// destination, or "".
+func (c *copier) checksumAlgorithm() string {
+	return aws.StringValue(c.in.COI.ChecksumAlgorithm)
+}
+
+func newChecksumHash(alg string) hash.Hash {
+	switch alg {
+	case s3.ChecksumAlgorithmCrc32:
+		return crc32.NewIEEE()
+	case s3.ChecksumAlgorithmCrc32c:
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	case s3.ChecksumAlgorithmSha1:
+		return sha1.New()
+	case s3.ChecksumAlgorithmSha256:
+		return sha256.New()
+	}
+	return nil
+}
+
+// checksumOf returns the checksum of algorithm alg among the checksum fields
+// of a response.
+func checksumOf(alg string, crc32, crc32c, sha1, sha256 *string) string {
+	switch alg {
+	case s3.ChecksumAlgorithmCrc32:
+		return aws.StringValue(crc32)
+	case s3.ChecksumAlgorithmCrc32c:
+		return aws.StringValue(crc32c)
+	case s3.ChecksumAlgorithmSha1:
+		return aws.StringValue(sha1)
+	case s3.ChecksumAlgorithmSha256:
+		return aws.StringValue(sha256)
+	}
+	return ""
+}
+
+// compositeChecksum returns the checksum S3 gives an object uploaded in parts
+// with the checksums sums: the checksum of the parts' decoded checksums,
+// followed by the part count.
+func compositeChecksum(alg string, sums []string) (string, error) {
+	h := newChecksumHash(alg)
+	for _, s := range sums {
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return "", fmt.Errorf("invalid %s checksum %q: %s", alg, s, err)
+		}
+		h.Write(b)
+	}
+	return fmt.Sprintf("%s-%d", base64.StdEncoding.EncodeToString(h.Sum(nil)), len(sums)), nil
+}
+
+// isComposite reports whether sum is the checksum of an object's parts
+// rather than of the whole object.
+func isComposite(sum string) bool {
+	return strings.Contains(sum, "-")
+}
+
+// verifyComposite checks sum, the checksum of the completed upload, is the
+// composite of the parts' checksums, and compares it with the source's.
+func (c *copier) verifyComposite(sum string) error {
+	alg := c.checksumAlgorithm()
+	sums := make([]string, len(c.parts))
+	for i, p := range c.parts {
+		sums[i] = checksumOf(alg, p.ChecksumCRC32, p.ChecksumCRC32C, p.ChecksumSHA1, p.ChecksumSHA256)
+		if sums[i] == "" {
+			return fmt.Errorf("part %d has no %s checksum", i+1, alg)
+		}
+	}
+	want, err := compositeChecksum(alg, sums)
+	if err != nil {
+		return err
+	}
+	if sum != want {
+		return fmt.Errorf("%s checksum %q of the copy isn't %q, that of its parts", alg, sum, want)
+	}
+	return c.verifySourceChecksum(sum)
+}
+
+// verifySourceChecksum compares sum, the destination's checksum, with the
+// source's if it was HEADed with one of the same algorithm and they are
+// comparable: both of the whole object, or both of the same parts.
+func (c *copier) verifySourceChecksum(sum string) error {
+	if c.sourceInfo == nil {
+		return nil
+	}
+	alg, info := c.checksumAlgorithm(), c.sourceInfo
+	src := checksumOf(alg, info.ChecksumCRC32, info.ChecksumCRC32C, info.ChecksumSHA1, info.ChecksumSHA256)
+	if src == "" {
+		return nil
+	}
+	if isComposite(src) != isComposite(sum) || (isComposite(src) && !c.preservedParts) {
+		c.logger().Debug("source checksum isn't comparable", c.logAttrs("source_checksum", src, "checksum", sum)...)
+		return nil
+	}
+	if src != sum {
+		return fmt.Errorf("%s checksum %q of the copy doesn't match the source's %q", alg, sum, src)
+	}
+	c.logger().Debug("checksum matches the source's", c.logAttrs("checksum", sum)...)
+	return nil
+}
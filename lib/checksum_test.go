@@ -0,0 +1,145 @@
+package s3cp_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/reedobrien/checkers"
+	s3cp "github.com/reedobrien/s3cp/lib"
+	"github.com/reedobrien/s3cp/lib/dummy"
+)
+
+func TestCopyChecksum(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		src      *dummy.Object
+		alg      string
+		preserve bool
+		partSize int64
+		suffix   string
+	}{
+		{
+			name:     "multipart",
+			src:      &dummy.Object{Data: bytes.Repeat([]byte("0123456789"), 10)},
+			alg:      "crc32c",
+			partSize: 10,
+			suffix:   "-10",
+		},
+		{
+			name: "single",
+			src:  &dummy.Object{Data: []byte("data"), ChecksumAlgorithm: s3.ChecksumAlgorithmSha256},
+			alg:  s3.ChecksumAlgorithmSha256,
+		},
+		{
+			name: "preserve parts",
+			src: &dummy.Object{
+				Data:              bytes.Repeat([]byte("x"), 11*mib),
+				PartSizes:         []int64{6 * mib, 5 * mib},
+				ChecksumAlgorithm: s3.ChecksumAlgorithmCrc32,
+			},
+			alg:      s3.ChecksumAlgorithmCrc32,
+			preserve: true,
+			suffix:   "-2",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := dummy.NewFakeWith(dummy.Buckets{"src": {"big": tc.src}, "dst": nil})
+			tut := s3cp.NewCopier(fake, func(c *s3cp.Copier) {
+				if tc.partSize != 0 {
+					c.PartSize = tc.partSize
+				}
+			})
+
+			in := partsInput
+			in.Checksum = tc.alg
+			in.PreserveParts = tc.preserve
+			checkers.OK(t, tut.Copy(in))
+
+			got, src := fake.Object("dst", "copy"), fake.Object("src", "big")
+			checkers.Equals(t, got.ChecksumAlgorithm, strings.ToUpper(tc.alg))
+			checkers.Assert(t, strings.HasSuffix(got.Checksum, tc.suffix), "got checksum %q, want a suffix of %q", got.Checksum, tc.suffix)
+			if tc.partSize == 0 {
+				// Copied whole or part for part, the source's checksum holds.
+				checkers.Equals(t, got.Checksum, src.Checksum)
+				checkers.Equals(t, got.ETag, src.ETag)
+			}
+		})
+	}
+}
+
+func TestComposeChecksum(t *testing.T) {
+	fake, locs, _ := newComposeFake(6*mib, 10)
+	tut := s3cp.NewCopier(fake)
+
+	err := tut.Compose(context.Background(), s3cp.ComposeInput{
+		Sources:     locs,
+		Destination: s3cp.Location{Bucket: "dst", Key: "all"},
+		Template:    s3cp.CopyInput{Checksum: s3.ChecksumAlgorithmSha1},
+	})
+	checkers.OK(t, err)
+	checkers.Assert(t, strings.HasSuffix(fake.Object("dst", "all").Checksum, "-2"), "expected a composite checksum")
+}
+
+func TestCopyChecksumErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		api  func(*dummy.Fake) s3cp.API
+		put  *dummy.Object
+		alg  string
+		err  string
+	}{
+		{
+			name: "algorithm",
+			alg:  "MD5",
+			err:  `invalid checksum algorithm "MD5", want CRC32, CRC32C, SHA1 or SHA256`,
+		},
+		{
+			name: "composite",
+			api:  func(f *dummy.Fake) s3cp.API { return &corruptingAPI{f} },
+			alg:  s3.ChecksumAlgorithmCrc32c,
+			err:  `CRC32C checksum "AAAAAA==-10" of the copy isn't `,
+		},
+		{
+			name: "source",
+			put:  &dummy.Object{Data: []byte("data"), ChecksumAlgorithm: s3.ChecksumAlgorithmSha256, Checksum: "bogus"},
+			alg:  s3.ChecksumAlgorithmSha256,
+			err:  `SHA256 checksum "Om6weQ85rIfJTzhWst0sXREOaBFgImGpqSPTuyOtyLc=" of the copy doesn't match the source's "bogus"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := newSplitFake(bytes.Repeat([]byte("0123456789"), 10))
+			if tc.put != nil {
+				fake.PutObject("src", "big", tc.put)
+			}
+			var api s3cp.API = fake
+			if tc.api != nil {
+				api = tc.api(fake)
+			}
+			tut := s3cp.NewCopier(api, func(c *s3cp.Copier) { c.PartSize = 10 })
+
+			in := partsInput
+			in.Checksum = tc.alg
+			err := tut.Copy(in)
+			checkers.Assert(t, err != nil, "expected an error")
+			checkers.Assert(t, strings.HasPrefix(err.Error(), tc.err), "got %q, want it to start with %q", err, tc.err)
+		})
+	}
+}
+
+// corruptingAPI reports a wrong checksum for completed uploads.
+type corruptingAPI struct {
+	*dummy.Fake
+}
+
+func (a *corruptingAPI) CompleteMultipartUploadWithContext(ctx aws.Context, in *s3.CompleteMultipartUploadInput, opts ...request.Option) (*s3.CompleteMultipartUploadOutput, error) {
+	out, err := a.Fake.CompleteMultipartUploadWithContext(ctx, in, opts...)
+	if err == nil && out.ChecksumCRC32C != nil {
+		out.ChecksumCRC32C = aws.String("AAAAAA==-10")
+	}
+	return out, err
+}
@@ -0,0 +1,112 @@
+package s3cp
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// ClientKey identifies the clients a ClientPool shares.
+type ClientKey struct {
+	Region   string
+	Endpoint string
+
+	// Credentials are compared by identity, so clients made from the same
+	// session share them and their cached credentials.
+	Credentials *credentials.Credentials
+}
+
+// ClientPool makes and caches an API for each ClientKey. It is safe for
+// concurrent use, so one pool can serve every copy of a bulk job.
+type ClientPool struct {
+	mu      sync.Mutex
+	clients map[ClientKey]API
+}
+
+// NewClientPool returns an empty ClientPool.
+func NewClientPool() *ClientPool {
+	return &ClientPool{clients: make(map[ClientKey]API)}
+}
+
+// Get returns the client for key, calling newAPI to make it if the pool has
+// none. A nil pool makes a client every time.
+func (p *ClientPool) Get(key ClientKey, newAPI func(ClientKey) (API, error)) (API, error) {
+	if p == nil {
+		return newAPI(key)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if api, ok := p.clients[key]; ok {
+		return api, nil
+	}
+	api, err := newAPI(key)
+	if err != nil {
+		return nil, err
+	}
+	p.clients[key] = api
+	return api, nil
+}
+
+// NewHTTPClient returns an HTTP client keeping enough idle connections to
+// each host for concurrency requests, so parts don't wait on new TLS
+// handshakes.
+func NewHTTPClient(concurrency int) *http.Client {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.MaxIdleConnsPerHost = concurrency
+	if tr.MaxIdleConns < concurrency {
+		tr.MaxIdleConns = concurrency
+	}
+	return &http.Client{Transport: tr}
+}
+
+// regionClient returns S3 if it is in region, otherwise the pooled client
+// for region, with S3's endpoint and credentials if it is an *s3.S3.
+func (c Copier) regionClient(region string) (API, error) {
+	if region == clientRegion(c.S3) {
+		return c.S3, nil
+	}
+
+	base := aws.NewConfig()
+	if s, ok := c.S3.(*s3.S3); ok {
+		base = s.Config.Copy()
+	}
+	key := ClientKey{
+		Region:      region,
+		Endpoint:    aws.StringValue(base.Endpoint),
+		Credentials: base.Credentials,
+	}
+
+	return c.Clients.Get(key, func(key ClientKey) (API, error) {
+		if c.MustSvcForRegion != nil {
+			return c.MustSvcForRegion(aws.String(key.Region)), nil
+		}
+
+		cfg := base.Copy().WithRegion(key.Region)
+		if cfg.HTTPClient == nil || cfg.HTTPClient == http.DefaultClient {
+			cfg.HTTPClient = NewHTTPClient(c.Concurrency)
+		}
+		sess, err := session.NewSession(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("error creating client for %s: %s", key.Region, err)
+		}
+		return s3.New(sess), nil
+	})
+}
+
+// clientRegion returns the region api is configured for, or "" if unknown.
+func clientRegion(api API) string {
+	switch a := api.(type) {
+	case *s3.S3:
+		return aws.StringValue(a.Config.Region)
+	case interface{ Region() string }:
+		return a.Region()
+	}
+	return ""
+}
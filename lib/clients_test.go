@@ -0,0 +1,119 @@
+package s3cp_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/reedobrien/checkers"
+	s3cp "github.com/reedobrien/s3cp/lib"
+	"github.com/reedobrien/s3cp/lib/dummy"
+)
+
+func TestClientPoolGet(t *testing.T) {
+	pool := s3cp.NewClientPool()
+	var made int
+	newAPI := func(key s3cp.ClientKey) (s3cp.API, error) {
+		made++
+		return dummy.NewS3API(key.Region), nil
+	}
+
+	west, err := pool.Get(s3cp.ClientKey{Region: "us-west-2"}, newAPI)
+	checkers.OK(t, err)
+	again, err := pool.Get(s3cp.ClientKey{Region: "us-west-2"}, newAPI)
+	checkers.OK(t, err)
+	checkers.Assert(t, west == again, "expected the cached client")
+
+	_, err = pool.Get(s3cp.ClientKey{Region: "us-west-2", Endpoint: "http://localhost:9000"}, newAPI)
+	checkers.OK(t, err)
+	checkers.Equals(t, made, 2)
+}
+
+func TestClientPoolGetError(t *testing.T) {
+	pool := s3cp.NewClientPool()
+	var made int
+	newAPI := func(key s3cp.ClientKey) (s3cp.API, error) {
+		made++
+		if made == 1 {
+			return nil, errors.New("no credentials")
+		}
+		return dummy.NewS3API(key.Region), nil
+	}
+
+	_, err := pool.Get(s3cp.ClientKey{Region: "us-west-2"}, newAPI)
+	checkers.Equals(t, err.Error(), "no credentials")
+
+	// Errors aren't cached.
+	api, err := pool.Get(s3cp.ClientKey{Region: "us-west-2"}, newAPI)
+	checkers.OK(t, err)
+	checkers.Assert(t, api != nil, "expected a client")
+	checkers.Equals(t, made, 2)
+}
+
+func TestClientPoolNil(t *testing.T) {
+	var pool *s3cp.ClientPool
+	var made int
+	newAPI := func(key s3cp.ClientKey) (s3cp.API, error) {
+		made++
+		return dummy.NewS3API(key.Region), nil
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err := pool.Get(s3cp.ClientKey{Region: "us-west-2"}, newAPI)
+		checkers.OK(t, err)
+	}
+	checkers.Equals(t, made, 2)
+}
+
+func TestNewHTTPClient(t *testing.T) {
+	tr := s3cp.NewHTTPClient(500).Transport.(*http.Transport)
+	checkers.Equals(t, tr.MaxIdleConnsPerHost, 500)
+	checkers.Equals(t, tr.MaxIdleConns, 500)
+
+	tr = s3cp.NewHTTPClient(10).Transport.(*http.Transport)
+	checkers.Equals(t, tr.MaxIdleConnsPerHost, 10)
+	checkers.Equals(t, tr.MaxIdleConns, http.DefaultTransport.(*http.Transport).MaxIdleConns)
+}
+
+func TestCopyConcurrentSharesClients(t *testing.T) {
+	fake := newRegionsFake()
+	keys := make([]string, 20)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%02d", i)
+		fake.PutObject("src", keys[i], &dummy.Object{Data: thirtyBytes})
+	}
+	rec := &regionRecorder{api: func(r string) s3cp.API { return regionalFake{fake, r} }}
+	tut := s3cp.NewCopier(regionalFake{fake, "us-east-1"},
+		func(c *s3cp.Copier) { c.PartSize = 10 },
+		func(c *s3cp.Copier) { c.MustSvcForRegion = rec.svcForRegion },
+	)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(keys))
+	for _, key := range keys {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			errs <- tut.Copy(s3cp.CopyInput{
+				COI: s3.CopyObjectInput{
+					Bucket:     aws.String("dst"),
+					CopySource: aws.String("src/" + key),
+					Key:        aws.String(key),
+				},
+			})
+		}(key)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		checkers.OK(t, err)
+	}
+	checkers.Equals(t, fake.Keys("dst"), keys)
+	checkers.Equals(t, rec.got(), []string{"eu-west-1", "us-west-2"})
+}
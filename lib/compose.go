@@ -0,0 +1,315 @@
+package s3cp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ComposeInput is a parameter container for Copier.Compose.
+type ComposeInput struct {
+	// Sources are the objects to concatenate, in order.
+	Sources []Location
+
+	// Destination is the object they are concatenated into.
+	Destination Location
+
+	// Template sets the composed object's settings, e.g. Encryption or the
+	// COI's ContentType; Delete isn't allowed. The COI's Bucket and Key come
+	// from the Destination, and SourceEncryption, if set, is used to read
+	// every source.
+	Template CopyInput
+}
+
+// Compose concatenates the sources into the destination with a multipart
+// upload. Ranges of sources of at least MinPartSize are copied server side
+// with UploadPartCopy. Smaller sources can't be parts but the last, so they
+// are downloaded and uploaded together with their neighbours, which takes
+// at most about 2*MinPartSize of memory per part in flight.
+// Each source is HEADed first, and every read of it must match the ETag seen,
+// so a source that changes during the compose fails it.
+func (c Copier) Compose(ctx aws.Context, in ComposeInput) error {
+	if len(in.Sources) == 0 {
+		return errors.New("compose requires at least one source")
+	}
+	if in.Destination.Bucket == "" || in.Destination.Key == "" {
+		return errors.New("compose requires a destination bucket and key")
+	}
+	if in.Template.Delete {
+		return errors.New("compose can't delete its sources")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ci := in.Template
+	ci.COI.Bucket = aws.String(in.Destination.Bucket)
+	ci.COI.Key = aws.String(in.Destination.Key)
+	ci.COI.CopySource = aws.String(in.Sources[0].CopySource())
+	impl := copier{in: ci, cfg: c, ctx: ctx, cancel: cancel}
+
+	var span trace.Span
+	impl.ctx, span = impl.startSpan("s3cp.Compose")
+
+	impl.cfg.RequestOptions = append(impl.cfg.RequestOptions, request.WithAppendUserAgent("s3manager"))
+
+	if s, ok := c.S3.(maxRetrier); ok {
+		impl.maxRetries = s.MaxRetries()
+	}
+
+	err := impl.compose(in.Sources)
+	endSpan(span, err)
+	return err
+}
+
+// composeSource is a HEADed source of a compose and the client to read it
+// with.
+type composeSource struct {
+	Location
+	api  API
+	size int64
+	etag *string
+}
+
+func (c *copier) compose(locs []Location) error {
+	c.applyRequestPayer()
+
+	if err := c.resolveRegions(locs[0]); err != nil {
+		return err
+	}
+	if err := c.applyEncryption(); err != nil {
+		return err
+	}
+	if err := c.applyChecksum(); err != nil {
+		return err
+	}
+	if err := c.applyACL(); err != nil {
+		return err
+	}
+
+	sources := make([]composeSource, len(locs))
+	sizes := make([]int64, len(locs))
+	var total int64
+	for i, loc := range locs {
+		api, err := c.sourceClient(loc)
+		if err != nil {
+			return err
+		}
+		info, err := c.headSource(api, loc)
+		if err != nil {
+			return fmt.Errorf("%s: %s", loc, err)
+		}
+		sources[i] = composeSource{Location: loc, api: api, size: aws.Int64Value(info.ContentLength), etag: info.ETag}
+		sizes[i] = sources[i].size
+		total += sources[i].size
+	}
+	c.contentLength = aws.Int64(total)
+
+	if err := c.applyObjectLock(); err != nil {
+		return err
+	}
+
+	layout := composeLayout(sizes, c.cfg.PartSize)
+	if len(layout) > MaxUploadParts {
+		return fmt.Errorf("compose needs %d parts, over the limit of %d; use a larger part size", len(layout), MaxUploadParts)
+	}
+
+	return c.multipart(len(layout), func() { c.produceComposeParts(sources, layout) })
+}
+
+// sourceClient returns the client for reading loc, which is in the bucket's
+// region if it can be discovered.
+func (c *copier) sourceClient(loc Location) (API, error) {
+	first, err := c.sourceLocation()
+	if err != nil {
+		return nil, err
+	}
+	if loc.Bucket == first.Bucket {
+		return c.cfg.SrcS3, nil
+	}
+	region := c.bucketRegion(loc.Bucket, c.in.COI.ExpectedSourceBucketOwner)
+	if region == "" {
+		return c.cfg.SrcS3, nil
+	}
+	return c.cfg.regionClient(region)
+}
+
+// byteRange is the bytes [start, end) of the source at index source.
+type byteRange struct {
+	source     int
+	start, end int64
+}
+
+// header returns r as the value of a Range header.
+func (r byteRange) header() string {
+	return fmt.Sprintf("bytes=%d-%d", r.start, r.end-1)
+}
+
+// composePart is a part of a compose. A part that is not uploaded is copied
+// from its only range.
+type composePart struct {
+	ranges []byteRange
+	upload bool
+}
+
+func (p composePart) size() int64 {
+	var n int64
+	for _, r := range p.ranges {
+		n += r.end - r.start
+	}
+	return n
+}
+
+// composeLayout splits sources of the given sizes into parts of about
+// partSize. Every part but the last is at least MinPartSize: sources under
+// it are merged with the start of the next source, or with the following
+// sources, into a part that is uploaded. The rest is copied.
+func composeLayout(sizes []int64, partSize int64) []composePart {
+	if partSize < MinPartSize {
+		partSize = MinPartSize
+	}
+	if partSize > MaxCopyObjectSize-MinPartSize {
+		partSize = MaxCopyObjectSize - MinPartSize
+	}
+
+	var (
+		parts    []composePart
+		pending  []byteRange
+		buffered int64
+	)
+	flush := func() {
+		if len(pending) > 0 {
+			parts = append(parts, composePart{ranges: pending, upload: true})
+		}
+		pending, buffered = nil, 0
+	}
+
+	for i, size := range sizes {
+		if size == 0 {
+			continue
+		}
+		var offset int64
+		if buffered > 0 {
+			need := MinPartSize - buffered
+			if size-need < MinPartSize {
+				// What would be left to copy is too small, so upload it
+				// all.
+				pending = append(pending, byteRange{i, 0, size})
+				buffered += size
+				if buffered >= MinPartSize {
+					flush()
+				}
+				continue
+			}
+			pending = append(pending, byteRange{i, 0, need})
+			flush()
+			offset = need
+		}
+
+		if size-offset < MinPartSize {
+			pending = append(pending, byteRange{i, offset, size})
+			buffered += size - offset
+			continue
+		}
+		for offset < size {
+			end := offset + partSize
+			if end > size || size-end < MinPartSize {
+				// Merge a short tail into the part before it.
+				end = size
+			}
+			parts = append(parts, composePart{ranges: []byteRange{{i, offset, end}}})
+			offset = end
+		}
+	}
+	flush()
+
+	if len(parts) == 0 {
+		// Every source is empty; upload one empty part.
+		parts = append(parts, composePart{upload: true})
+	}
+	return parts
+}
+
+// produceComposeParts sends the layout's parts to c.work, downloading the
+// parts to upload.
+func (c *copier) produceComposeParts(sources []composeSource, layout []composePart) {
+	defer close(c.work)
+
+	for i, p := range layout {
+		mci := multipartCopyInput{
+			PartNumber: int64(i + 1),
+			Size:       p.size(),
+			UploadID:   c.MultipartUploadID,
+		}
+		if c.ctx.Err() != nil {
+			c.notStarted(mci)
+			continue
+		}
+		if p.upload {
+			var err error
+			body := make([]byte, 0, mci.Size)
+			for _, r := range p.ranges {
+				var b []byte
+				if b, err = c.download(sources[r.source], r); err != nil {
+					break
+				}
+				body = append(body, b...)
+			}
+			if err != nil {
+				c.addPartErr(PartError{PartNumber: mci.PartNumber, Cancelled: c.ctx.Err() != nil && cancelled(err), Err: err})
+				c.fail(err)
+				continue
+			}
+			mci.Body = body
+		} else {
+			r := p.ranges[0]
+			src := sources[r.source]
+			mci.CopySource = aws.String(src.CopySource())
+			mci.CopySourceIfMatch = src.etag
+			mci.CopySourceRange = aws.String(r.header())
+		}
+
+		select {
+		case c.work <- mci:
+		case <-c.ctx.Done():
+			c.notStarted(mci)
+		}
+	}
+}
+
+// download reads the range r of src.
+func (c *copier) download(src composeSource, r byteRange) ([]byte, error) {
+	rng := r.header()
+	ctx, opts, done := c.startCall("GetObject", attrRange.String(rng))
+	out, err := src.api.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket:               aws.String(src.Bucket),
+		ExpectedBucketOwner:  c.in.COI.ExpectedSourceBucketOwner,
+		IfMatch:              src.etag,
+		Key:                  aws.String(src.Key),
+		Range:                aws.String(rng),
+		RequestPayer:         c.in.SourceRequestPayer,
+		SSECustomerAlgorithm: c.in.COI.CopySourceSSECustomerAlgorithm,
+		SSECustomerKey:       c.in.COI.CopySourceSSECustomerKey,
+		SSECustomerKeyMD5:    c.in.COI.CopySourceSSECustomerKeyMD5,
+		VersionId:            optional(src.VersionID),
+	}, opts...)
+	var b []byte
+	if err == nil {
+		b, err = io.ReadAll(out.Body)
+		out.Body.Close()
+	}
+	done(err)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s %s: %s", src.Location, rng, err)
+	}
+	if int64(len(b)) != r.end-r.start {
+		return nil, fmt.Errorf("error reading %s %s: got %d bytes", src.Location, rng, len(b))
+	}
+	return b, nil
+}
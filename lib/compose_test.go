@@ -0,0 +1,188 @@
+package s3cp_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/reedobrien/checkers"
+	s3cp "github.com/reedobrien/s3cp/lib"
+	"github.com/reedobrien/s3cp/lib/dummy"
+)
+
+const mib = 1024 * 1024
+
+// newComposeFake stores an object of each size, named s0, s1, ..., filled
+// with its index.
+func newComposeFake(sizes ...int) (*dummy.Fake, []s3cp.Location, []byte) {
+	var (
+		src  = make(map[string]*dummy.Object)
+		locs []s3cp.Location
+		want []byte
+	)
+	for i, size := range sizes {
+		data := bytes.Repeat([]byte{byte('a' + i)}, size)
+		key := fmt.Sprintf("s%d", i)
+		src[key] = &dummy.Object{Data: data}
+		locs = append(locs, s3cp.Location{Bucket: "src", Key: key})
+		want = append(want, data...)
+	}
+	fake := dummy.NewFakeWith(dummy.Buckets{"src": src, "dst": nil})
+	fake.MinPartSize = s3cp.MinPartSize
+	return fake, locs, want
+}
+
+func TestCompose(t *testing.T) {
+	for _, tc := range []struct {
+		name                  string
+		sizes                 []int
+		copies, uploads, gets int
+	}{
+		{
+			name:   "large",
+			sizes:  []int{12 * mib, 6 * mib},
+			copies: 3,
+		},
+		{
+			name:    "small",
+			sizes:   []int{100, 200, 300},
+			uploads: 1,
+			gets:    3,
+		},
+		{
+			name:   "small first",
+			sizes:  []int{1 * mib, 12 * mib},
+			copies: 1, uploads: 1, gets: 2,
+		},
+		{
+			name:   "small between",
+			sizes:  []int{12 * mib, 1 * mib, 2 * mib, 6 * mib, 100},
+			copies: 2, uploads: 2, gets: 4,
+		},
+		{
+			name:   "small last",
+			sizes:  []int{6 * mib, 10},
+			copies: 1, uploads: 1, gets: 1,
+		},
+		{
+			name:    "empty",
+			sizes:   []int{0, 0},
+			uploads: 1,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake, locs, want := newComposeFake(tc.sizes...)
+			tut := s3cp.NewCopier(fake, func(c *s3cp.Copier) { c.PartSize = s3cp.MinPartSize })
+
+			err := tut.Compose(context.Background(), s3cp.ComposeInput{
+				Sources:     locs,
+				Destination: s3cp.Location{Bucket: "dst", Key: "all"},
+			})
+			checkers.OK(t, err)
+			checkers.Assert(t, bytes.Equal(fake.Object("dst", "all").Data, want), "composed data differs")
+			checkers.Equals(t, fake.Calls("UploadPartCopy"), tc.copies)
+			checkers.Equals(t, fake.Calls("UploadPart"), tc.uploads)
+			checkers.Equals(t, fake.Calls("GetObject"), tc.gets)
+			checkers.Equals(t, fake.Calls("CopyObject"), 0)
+			checkers.Equals(t, fake.Uploads(), 0)
+		})
+	}
+}
+
+func TestComposeTemplate(t *testing.T) {
+	fake, locs, _ := newComposeFake(6*mib, 10)
+	tut := s3cp.NewCopier(fake)
+
+	err := tut.Compose(context.Background(), s3cp.ComposeInput{
+		Sources:     locs,
+		Destination: s3cp.Location{Bucket: "dst", Key: "all"},
+		Template: s3cp.CopyInput{
+			Encryption: &s3cp.Encryption{Type: s3cp.SSES3},
+		},
+	})
+	checkers.OK(t, err)
+	o := fake.Object("dst", "all")
+	checkers.Equals(t, o.ServerSideEncryption, s3cp.SSES3)
+	checkers.Equals(t, len(o.Data), 6*mib+10)
+}
+
+func TestComposeErrors(t *testing.T) {
+	dst := s3cp.Location{Bucket: "dst", Key: "all"}
+	for _, tc := range []struct {
+		name string
+		in   s3cp.ComposeInput
+		err  string
+	}{
+		{
+			name: "no sources",
+			in:   s3cp.ComposeInput{Destination: dst},
+			err:  "compose requires at least one source",
+		},
+		{
+			name: "no destination",
+			in:   s3cp.ComposeInput{Sources: []s3cp.Location{{Bucket: "src", Key: "s0"}}},
+			err:  "compose requires a destination bucket and key",
+		},
+		{
+			name: "delete",
+			in: s3cp.ComposeInput{
+				Sources:     []s3cp.Location{{Bucket: "src", Key: "s0"}},
+				Destination: dst,
+				Template:    s3cp.CopyInput{Delete: true},
+			},
+			err: "compose can't delete its sources",
+		},
+		{
+			name: "missing source",
+			in: s3cp.ComposeInput{
+				Sources:     []s3cp.Location{{Bucket: "src", Key: "s0"}, {Bucket: "src", Key: "nope"}},
+				Destination: dst,
+			},
+			err: "s3://src/nope: error getting object info: NotFound: NotFound",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake, _, _ := newComposeFake(10)
+			tut := s3cp.NewCopier(fake)
+
+			err := tut.Compose(context.Background(), tc.in)
+			checkers.Assert(t, err != nil, "expected an error")
+			checkers.Equals(t, strings.SplitN(err.Error(), "\n", 2)[0], tc.err)
+			checkers.Equals(t, fake.Calls("CreateMultipartUpload"), 0)
+		})
+	}
+}
+
+func TestComposeSourceChanged(t *testing.T) {
+	fake, locs, _ := newComposeFake(6*mib, 10)
+	tut := s3cp.NewCopier(&changingAPI{Fake: fake})
+
+	err := tut.Compose(context.Background(), s3cp.ComposeInput{
+		Sources:     locs,
+		Destination: s3cp.Location{Bucket: "dst", Key: "all"},
+	})
+	checkers.Assert(t, err != nil, "expected an error")
+	checkers.Assert(t, fake.Object("dst", "all") == nil, "expected no destination")
+	checkers.Equals(t, fake.Uploads(), 0)
+}
+
+// changingAPI rewrites src/s1 after it is first HEADed, so reading it
+// fails the ETag match.
+type changingAPI struct {
+	*dummy.Fake
+	changed bool
+}
+
+func (a *changingAPI) HeadObjectWithContext(ctx aws.Context, in *s3.HeadObjectInput, opts ...request.Option) (*s3.HeadObjectOutput, error) {
+	out, err := a.Fake.HeadObjectWithContext(ctx, in, opts...)
+	if aws.StringValue(in.Key) == "s1" && !a.changed {
+		a.changed = true
+		a.Fake.PutObject("src", "s1", &dummy.Object{Data: []byte("changed!!!")})
+	}
+	return out, err
+}
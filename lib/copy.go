@@ -4,11 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
-	"math"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
-	"strings"
+	"sort"
 	"sync"
 	"syscall"
 	"time"
@@ -16,14 +16,10 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/request"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func init() {
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
-}
-
 const (
 	// DefaultCopyPartSize declares the default size of chunks to get copied.
 	// It is currently set dumbly to 500MB. So that the maximum object size
@@ -36,13 +32,22 @@ const (
 
 	// DefaultCopyTimeout is the max time we expect the copy operation to
 	// take.  For a lambda < 5 minutes is best, but for a large copy it could
-	// take hours.  DefaultCopyTimeout = 260 * time.Second
+	// take hours.  DefaultCopyTimeout = 260 * time.Second. The handler
+	// package stops a lambda's copies before its deadline.
 	DefaultCopyTimeout = 18 * time.Hour
 
 	// MinCopyPartSize is the minimum allowed part size when doing multipart
 	// copies.
 	MinCopyPartSize = 1024 * 1024 * 25
 
+	// MinPartSize is the smallest part S3 accepts, except for the last part
+	// of an upload.
+	MinPartSize = 1024 * 1024 * 5
+
+	// MaxCopyObjectSize is the largest object CopyObject can copy. Larger
+	// objects must be copied in parts.
+	MaxCopyObjectSize = 1024 * 1024 * 1024 * 5
+
 	// MaxUploadParts is the maximum number of part allowed in a multipart
 	// upload.
 	// TODO(ro) 2018-01-30 Remove using s3manager constants.
@@ -54,10 +59,21 @@ type API interface {
 	CopyObjectWithContext(aws.Context, *s3.CopyObjectInput, ...request.Option) (*s3.CopyObjectOutput, error)
 	DeleteObjectWithContext(aws.Context, *s3.DeleteObjectInput, ...request.Option) (*s3.DeleteObjectOutput, error)
 	HeadObjectWithContext(aws.Context, *s3.HeadObjectInput, ...request.Option) (*s3.HeadObjectOutput, error)
-	// AbortMultipartUploadWithContext(aws.Context, *s3.AbortMultipartUploadInput, ...request.Option) (*s3.AbortMultipartUploadOutput, error)
+	AbortMultipartUploadWithContext(aws.Context, *s3.AbortMultipartUploadInput, ...request.Option) (*s3.AbortMultipartUploadOutput, error)
 	CreateMultipartUploadWithContext(aws.Context, *s3.CreateMultipartUploadInput, ...request.Option) (*s3.CreateMultipartUploadOutput, error)
 	CompleteMultipartUploadWithContext(aws.Context, *s3.CompleteMultipartUploadInput, ...request.Option) (*s3.CompleteMultipartUploadOutput, error)
 	UploadPartCopyWithContext(aws.Context, *s3.UploadPartCopyInput, ...request.Option) (*s3.UploadPartCopyOutput, error)
+	ListObjectsV2PagesWithContext(aws.Context, *s3.ListObjectsV2Input, func(*s3.ListObjectsV2Output, bool) bool, ...request.Option) error
+	GetObjectTaggingWithContext(aws.Context, *s3.GetObjectTaggingInput, ...request.Option) (*s3.GetObjectTaggingOutput, error)
+	GetObjectAclWithContext(aws.Context, *s3.GetObjectAclInput, ...request.Option) (*s3.GetObjectAclOutput, error)
+	PutObjectAclWithContext(aws.Context, *s3.PutObjectAclInput, ...request.Option) (*s3.PutObjectAclOutput, error)
+	GetObjectRetentionWithContext(aws.Context, *s3.GetObjectRetentionInput, ...request.Option) (*s3.GetObjectRetentionOutput, error)
+	GetObjectLegalHoldWithContext(aws.Context, *s3.GetObjectLegalHoldInput, ...request.Option) (*s3.GetObjectLegalHoldOutput, error)
+	GetObjectLockConfigurationWithContext(aws.Context, *s3.GetObjectLockConfigurationInput, ...request.Option) (*s3.GetObjectLockConfigurationOutput, error)
+	HeadBucketWithContext(aws.Context, *s3.HeadBucketInput, ...request.Option) (*s3.HeadBucketOutput, error)
+	GetBucketLocationWithContext(aws.Context, *s3.GetBucketLocationInput, ...request.Option) (*s3.GetBucketLocationOutput, error)
+	GetObjectWithContext(aws.Context, *s3.GetObjectInput, ...request.Option) (*s3.GetObjectOutput, error)
+	UploadPartWithContext(aws.Context, *s3.UploadPartInput, ...request.Option) (*s3.UploadPartOutput, error)
 }
 
 // CopyInput is a parameter container for Copier.Copy.
@@ -65,11 +81,14 @@ type CopyInput struct {
 	// If we should delete the source object on successful copy.
 	Delete bool
 
-	// The region of the destination bucket.
+	// The region of the destination bucket, which the Copier's S3 client is
+	// in. If nil it is discovered and, if S3 is in another region, a client
+	// for it is made with MustSvcForRegion.
 	Region *string
 
-	// The region of the source bucket. If nil the SourceRegion is considered
-	// to be the same as the destination bucket's region.
+	// The region of the source bucket. If nil it is discovered, and if it
+	// differs from the destination's a client for it is made with
+	// MustSvcForRegion.
 	SourceRegion *string
 
 	// The size of the source object. If provided we use this to calculate the
@@ -77,6 +96,49 @@ type CopyInput struct {
 	// the size.
 	Size int64
 
+	// PreserveParts copies the source in the parts it was uploaded in, so
+	// the destination's ETag is the source's as long as its encryption
+	// doesn't change the ETag either. If the source wasn't uploaded in
+	// parts, or its parts can't be found or copied, the PartSize is used.
+	// It is ignored with SourceRange.
+	PreserveParts bool
+
+	// Checksum, if set, is the additional checksum algorithm of the
+	// destination, e.g. s3.ChecksumAlgorithmCrc32c, overriding
+	// COI.ChecksumAlgorithm. Each part's checksum and the upload's
+	// composite checksum are verified, and the destination's checksum is
+	// compared with the source's if the source has one of the same kind. A
+	// mismatch fails the copy, though the destination has been written.
+	Checksum string
+
+	// SourceRange, if set, copies only that range of the source, with
+	// UploadPartCopy as CopyObject can't copy a range. Delete can't be set
+	// with it.
+	SourceRange *SourceRange
+
+	// Encryption sets the destination's server side encryption, overriding
+	// the corresponding COI fields. If nil the COI fields are used as is.
+	Encryption *Encryption
+
+	// SourceEncryption provides the source's SSE-C key, if it has one.
+	SourceEncryption *Encryption
+
+	// SourceRequestPayer confirms we pay for requests to a requester pays
+	// source, i.e. s3.RequestPayerRequester. COI.RequestPayer does the same
+	// for the destination.
+	SourceRequestPayer *string
+
+	// ObjectLock sets the destination's Object Lock settings, overriding
+	// the corresponding COI fields. If nil the COI fields are used as is.
+	ObjectLock *ObjectLock
+
+	// ACL sets the destination's access control, overriding the
+	// corresponding COI fields. If nil the COI fields are used as is.
+	//
+	// Set COI.ExpectedBucketOwner and COI.ExpectedSourceBucketOwner to have
+	// every call fail if a bucket is not owned by the expected account.
+	ACL *ACL
+
 	// COI is an embedded s3.CopyObjectInput struct.
 	COI s3.CopyObjectInput
 }
@@ -85,11 +147,13 @@ type CopyInput struct {
 // one s3 location to another.
 func NewCopier(api API, opts ...func(*Copier)) *Copier {
 	c := &Copier{
-		PartSize:         DefaultCopyPartSize,
-		Timeout:          DefaultCopyTimeout,
-		Concurrency:      DefaultCopyConcurrency,
-		S3:               api,
-		MustSvcForRegion: mustSvcForRegion,
+		PartSize:    DefaultCopyPartSize,
+		Timeout:     DefaultCopyTimeout,
+		Concurrency: DefaultCopyConcurrency,
+		S3:          api,
+		Clients:     NewClientPool(),
+		Logger:      slog.Default(),
+		regions:     newRegionCache(),
 	}
 
 	for _, opt := range opts {
@@ -99,14 +163,6 @@ func NewCopier(api API, opts ...func(*Copier)) *Copier {
 	return c
 }
 
-func mustSvcForRegion(r *string) API {
-	return s3.New(
-		session.Must(
-			session.NewSession(
-				&aws.Config{Region: r},
-			)))
-}
-
 // WithCopierRequestOptions appends to the Copier's API requst options.
 func WithCopierRequestOptions(opts ...request.Option) func(*Copier) {
 	return func(c *Copier) {
@@ -135,7 +191,23 @@ type Copier struct {
 	// up.
 	LeavePartsOnError bool
 
-	// MustSvcForRegion returns a new API for the provided region.
+	// Logger receives the copy's log events. If nil the slog default logger
+	// is used.
+	Logger Logger
+
+	// Metrics receives the copy's measurements. If nil nothing is recorded.
+	Metrics Metrics
+
+	// TracerProvider provides the tracer for the copy's spans. If nil the
+	// global TracerProvider is used.
+	TracerProvider trace.TracerProvider
+
+	// Clients caches the clients made for other regions. If nil a client is
+	// made for every copy that needs one.
+	Clients *ClientPool
+
+	// MustSvcForRegion, if set, makes the clients for other regions instead
+	// of a session sharing S3's endpoint and credentials.
 	MustSvcForRegion func(*string) API
 
 	// The s3 client ot use when copying.
@@ -146,6 +218,9 @@ type Copier struct {
 
 	// RequestOptions to be passed to the individual calls.
 	RequestOptions []request.Option
+
+	// regions caches the discovered bucket regions.
+	regions *regionCache
 }
 
 // Copy copies the source to the destination.
@@ -155,31 +230,41 @@ func (c Copier) Copy(i CopyInput, opts ...func(*Copier)) error {
 
 // CopyWithContext performs Copy with the given context.Context.
 func (c Copier) CopyWithContext(ctx aws.Context, input CopyInput, opts ...func(*Copier)) error {
-	ctx, cancel := context.WithCancel(ctx)
-	c.SrcS3 = c.S3
-	if input.SourceRegion != nil && *input.SourceRegion != "" {
-		c.SrcS3 = c.MustSvcForRegion(input.SourceRegion)
-	}
+	return c.copyWith(ctx, input, nil, opts...)
+}
 
+// copyWith copies input after prepare, if not nil, has set up the copier,
+// e.g. with a range of the source or its HEAD.
+func (c Copier) copyWith(ctx aws.Context, input CopyInput, prepare func(*copier), opts ...func(*Copier)) error {
+	ctx, cancel := context.WithCancel(ctx)
 	impl := copier{in: input, cfg: c, ctx: ctx, cancel: cancel}
+	if prepare != nil {
+		prepare(&impl)
+	}
 
 	for _, opt := range opts {
 		opt(&impl.cfg)
 	}
 
+	var span trace.Span
+	impl.ctx, span = impl.startSpan("s3cp.Copy")
+
 	impl.cfg.RequestOptions = append(impl.cfg.RequestOptions, request.WithAppendUserAgent("s3manager"))
 
 	if s, ok := c.S3.(maxRetrier); ok {
 		impl.maxRetries = s.MaxRetries()
 	}
 
-	return impl.copy()
+	err := impl.copy()
+	endSpan(span, err)
+	return err
 }
 
 // copier is the struct for the internal implementation of copy.
 type copier struct {
 	sync.Mutex
-	err error
+	err      error
+	partErrs []PartError
 
 	cfg    Copier
 	cancel context.CancelFunc
@@ -188,6 +273,10 @@ type copier struct {
 	ctx        aws.Context
 
 	contentLength     *int64
+	sourceInfo        *s3.HeadObjectOutput
+	sourceRange       *byteRange
+	preservedParts    bool
+	slots             chan struct{}
 	MultipartUploadID *string
 	in                CopyInput
 	parts             []*s3.CompletedPart
@@ -196,135 +285,382 @@ type copier struct {
 	wg                sync.WaitGroup
 }
 
-func (c *copier) copy() error {
+func (c *copier) copy() (err error) {
+	if c.in.SourceRange != nil && c.in.Delete {
+		return errors.New("can't delete the source of a range copy")
+	}
+	c.applyRequestPayer()
+
+	if err := c.canonicalCopySource(); err != nil {
+		return err
+	}
+	source, err := c.sourceLocation()
+	if err != nil {
+		return err
+	}
+	if err := c.resolveRegions(source); err != nil {
+		return err
+	}
+
+	if err := c.applyEncryption(); err != nil {
+		return err
+	}
+
+	if err := c.applyChecksum(); err != nil {
+		return err
+	}
+
+	if err := c.applyACL(); err != nil {
+		return err
+	}
+
 	c.getContentLength()
 	if err := c.getErr(); err != nil {
 		return err
 	}
 
+	if err := c.applyObjectLock(); err != nil {
+		return err
+	}
+
+	if c.in.SourceRange != nil {
+		rng, err := c.in.SourceRange.resolve(*c.contentLength)
+		if err != nil {
+			return err
+		}
+		c.sourceRange = &rng
+	}
+	if c.sourceRange != nil {
+		// CopyObject can't copy a range, so even a small one is a single
+		// part.
+		c.contentLength = aws.Int64(c.sourceRange.end - c.sourceRange.start)
+		if err = c.copySourceAttributes(); err != nil {
+			return err
+		}
+		return c.multipartSizes(c.partSizes())
+	}
+
 	// If there's a request to delete the source copy, do it on exit if there
-	// was no error copying.
+	// was no error copying. A failed delete is logged but the copy stands.
 	if c.in.Delete {
 		defer func() {
-			if c.getErr() != nil {
+			if err != nil {
 				return
 			}
 			c.deleteObject()
 		}()
 	}
 
-	if *c.contentLength < c.cfg.PartSize {
+	if c.in.PreserveParts {
+		if sizes := c.sourceParts(); sizes != nil {
+			if err = c.copySourceAttributes(); err != nil {
+				return err
+			}
+			c.preservedParts = true
+			return c.multipartSizes(sizes)
+		}
+	}
+
+	if *c.contentLength < c.cfg.PartSize && *c.contentLength <= MaxCopyObjectSize {
 		// It is smaller than part size so just copy.
-		return c.singlePartCopyObject()
+		if err = c.singlePartCopyObject(); err != nil {
+			return err
+		}
+		return c.copySourceACL()
 	}
 
+	if err = c.copySourceAttributes(); err != nil {
+		return err
+	}
+
+	return c.multipartSizes(c.partSizes())
+}
+
+// multipartSizes copies the source in consecutive parts of the given sizes.
+func (c *copier) multipartSizes(sizes []int64) error {
+	return c.multipart(len(sizes), func() { c.produceParts(sizes) })
+}
+
+// multipart starts a multipart upload of partCount parts, copies the parts
+// produce sends to c.work and completes the upload, or aborts it if any part
+// fails.
+func (c *copier) multipart(partCount int, produce func()) error {
 	err := c.startMultipart()
 	if err != nil {
 		return err
 	}
 
-	c.primeMultipart()
+	c.primeMultipart(partCount)
 
-	c.wg.Add(1)
-	go c.produceParts()
+	go produce()
 
+	c.wg.Add(c.cfg.Concurrency)
 	for i := 0; i < c.cfg.Concurrency; i++ {
 		go c.copyParts()
 	}
 
-	c.wg.Add(1)
-	go c.collect()
+	// Once every worker has returned nothing else can be sent, so closing
+	// results lets collect finish.
+	go func() {
+		c.wg.Wait()
+		close(c.results)
+	}()
 
 	c.wait()
-	return c.complete()
+	if err = c.getErr(); err != nil {
+		c.abort()
+		return c.multipartErr(err)
+	}
+
+	if err = c.complete(); err != nil {
+		return err
+	}
+	return c.copySourceACL()
 }
 
-func (c *copier) collect() {
-	var received int
-	defer c.wg.Done()
+// applyRequestPayer confirms we pay for the copy requests if the source is
+// requester pays. CopyObject and UploadPartCopy send one x-amz-request-payer
+// header for both buckets, so it has to be set on the CopyObjectInput.
+func (c *copier) applyRequestPayer() {
+	if c.in.COI.RequestPayer == nil {
+		c.in.COI.RequestPayer = c.in.SourceRequestPayer
+	}
+}
 
-	for {
-		select {
-		case r := <-c.results:
-			c.parts[r.PartNumber-1] = &s3.CompletedPart{
-				ETag:       r.CopyPartResult.ETag,
-				PartNumber: aws.Int64(r.PartNumber)}
-			received++
-		case <-time.After(time.Millisecond * 400):
-			if received == len(c.parts) {
-				close(c.results)
-				return
-			}
-			if c.getErr() != nil {
-				return
-			}
+// abort aborts the multipart upload unless the Copier is configured to leave
+// the parts on error. It ignores cancellation of c.ctx since c.ctx is usually
+// cancelled by the time we get here.
+func (c *copier) abort() {
+	if c.cfg.LeavePartsOnError || c.MultipartUploadID == nil {
+		return
+	}
+
+	ctx, opts, done := c.startCall("AbortMultipartUpload")
+	_, err := c.cfg.S3.AbortMultipartUploadWithContext(context.WithoutCancel(ctx), &s3.AbortMultipartUploadInput{
+		Bucket:              c.in.COI.Bucket,
+		ExpectedBucketOwner: c.in.COI.ExpectedBucketOwner,
+		Key:                 c.in.COI.Key,
+		RequestPayer:        c.in.COI.RequestPayer,
+		UploadId:            c.MultipartUploadID,
+	}, opts...)
+	done(err)
+	if err != nil {
+		c.logger().Error("failed to abort multipart upload", c.logAttrs(errAttrs(err)...)...)
+		return
+	}
+	c.metrics().IncUploadsAborted()
+	c.logger().Info("aborted multipart upload", c.logAttrs()...)
+}
+
+// multipartErr wraps err in a MultipartCopyError if any parts failed.
+func (c *copier) multipartErr(err error) error {
+	c.Lock()
+	defer c.Unlock()
+
+	if len(c.partErrs) == 0 {
+		return err
+	}
+
+	sort.Slice(c.partErrs, func(i, j int) bool {
+		return c.partErrs[i].PartNumber < c.partErrs[j].PartNumber
+	})
+
+	return &MultipartCopyError{
+		UploadID: aws.StringValue(c.MultipartUploadID),
+		Err:      err,
+		Parts:    c.partErrs,
+	}
+}
+
+// collect records the results of copied parts until results is closed.
+func (c *copier) collect() {
+	for r := range c.results {
+		part := &s3.CompletedPart{
+			ETag:       r.CopyPartResult.ETag,
+			PartNumber: aws.Int64(r.PartNumber)}
+		if c.checksumAlgorithm() != "" {
+			part.ChecksumCRC32 = r.CopyPartResult.ChecksumCRC32
+			part.ChecksumCRC32C = r.CopyPartResult.ChecksumCRC32C
+			part.ChecksumSHA1 = r.CopyPartResult.ChecksumSHA1
+			part.ChecksumSHA256 = r.CopyPartResult.ChecksumSHA256
 		}
+		c.parts[r.PartNumber-1] = part
 	}
 }
 
 func (c *copier) complete() error {
 	cmui := &s3.CompleteMultipartUploadInput{
-		Bucket:   c.in.COI.Bucket,
-		Key:      c.in.COI.Key,
-		UploadId: c.MultipartUploadID,
+		Bucket:              c.in.COI.Bucket,
+		ExpectedBucketOwner: c.in.COI.ExpectedBucketOwner,
+		Key:                 c.in.COI.Key,
+		RequestPayer:        c.in.COI.RequestPayer,
+		UploadId:            c.MultipartUploadID,
 		MultipartUpload: &s3.CompletedMultipartUpload{
 			Parts: c.parts,
 		},
 	}
-	_, err := c.cfg.S3.CompleteMultipartUploadWithContext(c.ctx, cmui)
+	ctx, opts, done := c.startCall("CompleteMultipartUpload")
+	out, err := c.cfg.S3.CompleteMultipartUploadWithContext(ctx, cmui, opts...)
+	done(err)
 	if err != nil {
-		log.Printf("failed to complete copy for %s: %s\n",
-			*c.in.COI.CopySource, err)
+		c.logger().Error("failed to complete multipart copy", c.logAttrs(errAttrs(err)...)...)
 		return err
 	}
 
+	if alg := c.checksumAlgorithm(); alg != "" {
+		sum := checksumOf(alg, out.ChecksumCRC32, out.ChecksumCRC32C, out.ChecksumSHA1, out.ChecksumSHA256)
+		if err := c.verifyComposite(sum); err != nil {
+			c.logger().Error("failed to verify checksum", c.logAttrs(errAttrs(err)...)...)
+			return err
+		}
+	}
+
+	c.logger().Debug("completed multipart copy", c.logAttrs("parts", len(c.parts))...)
 	return nil
 }
 
 func (c *copier) copyParts() {
-	var err error
-	var resp *s3.UploadPartCopyOutput
-	var copied int
+	defer c.wg.Done()
+
 	for mci := range c.work {
-		upci := mci.FromCopyPartInput(&c.in.COI)
-		for retry := 0; retry <= c.maxRetries; retry++ {
-			resp, err = c.cfg.S3.UploadPartCopyWithContext(c.ctx, upci)
-			if err != nil {
-				log.Printf("Error: %s\n Part: %d\n Input %#v\n",
-					err,
-					mci.PartNumber,
-					*upci)
+		if c.ctx.Err() != nil {
+			// Someone else failed, drain work so the producer can exit.
+			c.notStarted(mci)
+			continue
+		}
+
+		if c.slots != nil {
+			// Wait for a part of the budget shared with other copies.
+			select {
+			case c.slots <- struct{}{}:
+			case <-c.ctx.Done():
+				c.notStarted(mci)
 				continue
 			}
-			c.results <- copyPartResult{
-				PartNumber:     mci.PartNumber,
-				CopyPartResult: resp.CopyPartResult,
-			}
-			copied++
-			break
+		}
+		c.metrics().AddPartsInFlight(1)
+		resp, perr := c.copyPart(mci)
+		c.metrics().AddPartsInFlight(-1)
+		if c.slots != nil {
+			<-c.slots
+		}
+		if perr != nil {
+			c.metrics().IncPartsFailed()
+			c.addPartErr(*perr)
+			c.fail(perr.Err)
+			continue
 		}
 
-		if err != nil {
-			if copied != len(c.parts) {
-				c.setErr(err)
-			}
+		c.metrics().IncPartsSucceeded()
+		c.metrics().AddBytesCopied(mci.Size)
+
+		c.results <- copyPartResult{
+			PartNumber:     mci.PartNumber,
+			CopyPartResult: resp.CopyPartResult,
 		}
 	}
 }
 
-func (c *copier) deleteObject() {
-	if c.in.COI.CopySource == nil {
-		c.setErr(errors.New("delete requested but copy source is nil"))
-		return
+// copyPart copies a single part, or uploads it if it has a Body, retrying up
+// to maxRetries times.
+func (c *copier) copyPart(mci multipartCopyInput) (*s3.UploadPartCopyOutput, *PartError) {
+	op := "UploadPartCopy"
+	call := func(ctx aws.Context, opts []request.Option) (*s3.UploadPartCopyOutput, error) {
+		return c.cfg.S3.UploadPartCopyWithContext(ctx, mci.FromCopyPartInput(&c.in.COI), opts...)
 	}
-	source := strings.SplitN(*c.in.COI.CopySource, "/", 2)
-	_, err := c.cfg.SrcS3.DeleteObjectWithContext(c.ctx, &s3.DeleteObjectInput{
-		Bucket: aws.String(source[0]),
-		Key:    aws.String(source[1]),
+	if mci.Body != nil {
+		op = "UploadPart"
+		call = func(ctx aws.Context, opts []request.Option) (*s3.UploadPartCopyOutput, error) {
+			out, err := c.cfg.S3.UploadPartWithContext(ctx, mci.FromUploadPartInput(&c.in.COI), opts...)
+			if err != nil {
+				return nil, err
+			}
+			return &s3.UploadPartCopyOutput{CopyPartResult: &s3.CopyPartResult{
+				ETag:           out.ETag,
+				ChecksumCRC32:  out.ChecksumCRC32,
+				ChecksumCRC32C: out.ChecksumCRC32C,
+				ChecksumSHA1:   out.ChecksumSHA1,
+				ChecksumSHA256: out.ChecksumSHA256,
+			}}, nil
+		}
+	}
+
+	perr := PartError{
+		PartNumber: mci.PartNumber,
+		Range:      aws.StringValue(mci.CopySourceRange),
+	}
+
+	for retry := 0; retry <= c.maxRetries; retry++ {
+		if retry > 0 {
+			c.metrics().IncPartsRetried()
+		}
+		perr.Attempts++
+		ctx, opts, done := c.startCall(op,
+			attrPartNumber.Int64(mci.PartNumber),
+			attrRange.String(perr.Range),
+			attrRetry.Int(retry),
+		)
+		resp, err := call(ctx, opts)
+		done(err)
+		if err == nil {
+			return resp, nil
+		}
+
+		perr.Err = err
+		if aerr, ok := err.(awserr.Error); ok {
+			perr.Code = aerr.Code()
+		}
+		if c.ctx.Err() != nil {
+			perr.Cancelled = cancelled(err)
+			break
+		}
+		attrs := c.logAttrs("part", mci.PartNumber, "range", perr.Range, "attempt", perr.Attempts)
+		c.logger().Warn("failed to copy part", append(attrs, errAttrs(err)...)...)
+	}
+
+	return nil, &perr
+}
+
+func (c *copier) addPartErr(p PartError) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.partErrs = append(c.partErrs, p)
+}
+
+// notStarted records that the part mci was cancelled before it was started.
+func (c *copier) notStarted(mci multipartCopyInput) {
+	c.addPartErr(PartError{
+		PartNumber: mci.PartNumber,
+		Range:      aws.StringValue(mci.CopySourceRange),
+		Cancelled:  true,
+		Err:        c.ctx.Err(),
 	})
+}
+
+// deleteObject deletes the source of a move.
+func (c *copier) deleteObject() error {
+	source, err := c.sourceLocation()
 	if err != nil {
-		log.Printf("failed to delete %q: %q", *c.in.COI.CopySource, err)
+		err = fmt.Errorf("delete requested but %s", err)
+		c.setErr(err)
+		return err
 	}
+	ctx, opts, done := c.startCall("DeleteObject")
+	_, err = c.cfg.SrcS3.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket:              aws.String(source.Bucket),
+		ExpectedBucketOwner: c.in.COI.ExpectedSourceBucketOwner,
+		Key:                 aws.String(source.Key),
+		RequestPayer:        c.in.SourceRequestPayer,
+		VersionId:           optional(source.VersionID),
+	}, opts...)
+	done(err)
+	if err != nil {
+		c.logger().Error("failed to delete source", c.logAttrs(errAttrs(err)...)...)
+		return err
+	}
+	c.logger().Debug("deleted source", c.logAttrs()...)
+	return nil
 }
 
 func (c *copier) getContentLength() {
@@ -333,7 +669,7 @@ func (c *copier) getContentLength() {
 		return
 	}
 
-	info, err := c.objectInfo(c.in.COI.CopySource)
+	info, err := c.sourceHead()
 	if err != nil {
 		c.setErr(err)
 		return
@@ -341,125 +677,280 @@ func (c *copier) getContentLength() {
 	c.contentLength = info.ContentLength
 }
 
-func (c *copier) objectInfo(cs *string) (*s3.HeadObjectOutput, error) {
-	if cs == nil {
-		return nil, errors.New("got nil *string as CopySource")
+// sourceHead returns the source's HEAD, only making the request once.
+func (c *copier) sourceHead() (*s3.HeadObjectOutput, error) {
+	if c.sourceInfo != nil {
+		return c.sourceInfo, nil
 	}
-	source := strings.SplitN(*cs, "/", 2)
-	info, err := c.cfg.SrcS3.HeadObjectWithContext(c.ctx, &s3.HeadObjectInput{
-		Bucket: aws.String(source[0]),
-		Key:    aws.String(source[1]),
-	})
+
+	info, err := c.objectInfo()
+	if err != nil {
+		return nil, err
+	}
+	c.sourceInfo = info
+	return info, nil
+}
+
+// copySourceAttributes sets the source's content headers, metadata and tags
+// on the input unless the directives replace them. CopyObject copies them
+// server side, but a multipart upload starts empty and would drop them.
+func (c *copier) copySourceAttributes() error {
+	if aws.StringValue(c.in.COI.MetadataDirective) != s3.MetadataDirectiveReplace {
+		info, err := c.sourceHead()
+		if err != nil {
+			return err
+		}
+
+		c.in.COI.CacheControl = info.CacheControl
+		c.in.COI.ContentDisposition = info.ContentDisposition
+		c.in.COI.ContentEncoding = info.ContentEncoding
+		c.in.COI.ContentLanguage = info.ContentLanguage
+		c.in.COI.ContentType = info.ContentType
+		c.in.COI.Expires = nil
+		if info.Expires != nil {
+			if t, err := http.ParseTime(*info.Expires); err == nil {
+				c.in.COI.Expires = aws.Time(t)
+			}
+		}
+		c.in.COI.Metadata = info.Metadata
+	}
+
+	if aws.StringValue(c.in.COI.TaggingDirective) != s3.TaggingDirectiveReplace {
+		source, err := c.sourceLocation()
+		if err != nil {
+			return err
+		}
+		ctx, opts, done := c.startCall("GetObjectTagging")
+		tags, err := c.cfg.SrcS3.GetObjectTaggingWithContext(ctx, &s3.GetObjectTaggingInput{
+			Bucket:              aws.String(source.Bucket),
+			ExpectedBucketOwner: c.in.COI.ExpectedSourceBucketOwner,
+			Key:                 aws.String(source.Key),
+			RequestPayer:        c.in.SourceRequestPayer,
+			VersionId:           optional(source.VersionID),
+		}, opts...)
+		done(err)
+		if err != nil {
+			return fmt.Errorf("error getting object tags: %s", err)
+		}
+		c.in.COI.Tagging = nil
+		if len(tags.TagSet) > 0 {
+			c.in.COI.Tagging = aws.String(encodeTags(tags.TagSet))
+		}
+	}
+
+	return nil
+}
+
+// canonicalCopySource re-encodes the CopySource canonically, so CopyObject
+// and UploadPartCopy read the object every other source call names however
+// the caller encoded it.
+func (c *copier) canonicalCopySource() error {
+	source, err := c.sourceLocation()
+	if err != nil {
+		return err
+	}
+	c.in.COI.CopySource = aws.String(source.CopySource())
+	return nil
+}
+
+// sourceLocation returns the parsed CopySource.
+func (c *copier) sourceLocation() (Location, error) {
+	if c.in.COI.CopySource == nil {
+		return Location{}, errors.New("got nil *string as CopySource")
+	}
+	return ParseCopySource(*c.in.COI.CopySource)
+}
+
+func (c *copier) objectInfo() (*s3.HeadObjectOutput, error) {
+	source, err := c.sourceLocation()
+	if err != nil {
+		return nil, err
+	}
+	return c.headSource(c.cfg.SrcS3, source)
+}
+
+// headInput returns the input to HEAD the source object.
+func (c *copier) headInput(source Location) *s3.HeadObjectInput {
+	in := &s3.HeadObjectInput{
+		Bucket:               aws.String(source.Bucket),
+		ExpectedBucketOwner:  c.in.COI.ExpectedSourceBucketOwner,
+		Key:                  aws.String(source.Key),
+		RequestPayer:         c.in.SourceRequestPayer,
+		SSECustomerAlgorithm: c.in.COI.CopySourceSSECustomerAlgorithm,
+		SSECustomerKey:       c.in.COI.CopySourceSSECustomerKey,
+		SSECustomerKeyMD5:    c.in.COI.CopySourceSSECustomerKeyMD5,
+		VersionId:            optional(source.VersionID),
+	}
+	if c.checksumAlgorithm() != "" {
+		in.ChecksumMode = aws.String(s3.ChecksumModeEnabled)
+	}
+	return in
+}
+
+// headSource HEADs the source object with api.
+func (c *copier) headSource(api API, source Location) (*s3.HeadObjectOutput, error) {
+	ctx, opts, done := c.startCall("HeadObject")
+	info, err := api.HeadObjectWithContext(ctx, c.headInput(source), opts...)
+	done(err)
 	if err != nil {
 		return nil, fmt.Errorf("error getting object info: %s", err)
 	}
 	return info, nil
 }
 
-func (c *copier) produceParts() {
-	defer c.wg.Done()
-	var partNum int64
-	size := *c.contentLength
-
-	for size >= 0 {
-		offset := c.cfg.PartSize * partNum
-		endByte := offset + c.cfg.PartSize - 1
-		if endByte >= *c.contentLength {
-			endByte = *c.contentLength - 1
+// partSizes splits the copy into parts of PartSize.
+func (c *copier) partSizes() []int64 {
+	var sizes []int64
+	for left := *c.contentLength; left > 0; left -= c.cfg.PartSize {
+		size := c.cfg.PartSize
+		if left < size {
+			size = left
 		}
+		sizes = append(sizes, size)
+	}
+	return sizes
+}
+
+// produceParts sends a part of each size to c.work, in order from the start
+// of the source, or of its range.
+func (c *copier) produceParts(sizes []int64) {
+	defer close(c.work)
+
+	var offset int64
+	if c.sourceRange != nil {
+		offset = c.sourceRange.start
+	}
+	for i, size := range sizes {
 		mci := multipartCopyInput{
-			PartNumber:      partNum + 1,
-			CopySourceRange: aws.String(fmt.Sprintf("bytes=%d-%d", offset, endByte)),
+			PartNumber:      int64(i + 1),
+			CopySourceRange: aws.String(byteRange{start: offset, end: offset + size}.header()),
+			Size:            size,
 			UploadID:        c.MultipartUploadID,
 		}
-		c.work <- mci
-		partNum++
-		size -= c.cfg.PartSize
-		if size <= 0 {
-			break
+		offset += size
+		if c.ctx.Err() != nil {
+			c.notStarted(mci)
+			continue
+		}
+		select {
+		case c.work <- mci:
+		case <-c.ctx.Done():
+			c.notStarted(mci)
 		}
 	}
-	close(c.work)
 }
 
-func (c *copier) primeMultipart() {
-	partCount := int(math.Ceil(float64(*c.contentLength) / float64(c.cfg.PartSize)))
+func (c *copier) primeMultipart(partCount int) {
 	c.parts = make([]*s3.CompletedPart, partCount)
 	c.results = make(chan copyPartResult, c.cfg.Concurrency)
 	c.work = make(chan multipartCopyInput, c.cfg.Concurrency)
 }
 
 func (c *copier) singlePartCopyObject() error {
-	_, err := c.cfg.S3.CopyObjectWithContext(c.ctx, &c.in.COI)
+	ctx, opts, done := c.startCall("CopyObject", attrSize.Int64(*c.contentLength))
+	out, err := c.cfg.S3.CopyObjectWithContext(ctx, &c.in.COI, opts...)
+	done(err)
 	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			log.Printf("failed to copy %q to %q: %s", *c.in.COI.CopySource, *c.in.COI.Bucket+"/"+*c.in.COI.Key, aerr)
-		} else {
-			log.Printf("failed to copy %q to %q: %s", *c.in.COI.CopySource, *c.in.COI.Bucket+"/"+*c.in.COI.Key, err)
-		}
+		c.logger().Error("failed to copy", c.logAttrs(errAttrs(err)...)...)
 		return err
 	}
 
+	if alg := c.checksumAlgorithm(); alg != "" && out.CopyObjectResult != nil {
+		r := out.CopyObjectResult
+		if sum := checksumOf(alg, r.ChecksumCRC32, r.ChecksumCRC32C, r.ChecksumSHA1, r.ChecksumSHA256); sum != "" {
+			if err := c.verifySourceChecksum(sum); err != nil {
+				c.logger().Error("failed to verify checksum", c.logAttrs(errAttrs(err)...)...)
+				return err
+			}
+		}
+	}
+
+	c.metrics().AddBytesCopied(*c.contentLength)
+	c.logger().Debug("copied object", c.logAttrs()...)
 	return nil
 }
 
 func (c *copier) startMultipart() error {
 	cmui := &s3.CreateMultipartUploadInput{
-		ACL:                     c.in.COI.ACL,
-		Bucket:                  c.in.COI.Bucket,
-		CacheControl:            c.in.COI.CacheControl,
-		ContentDisposition:      c.in.COI.ContentDisposition,
-		ContentEncoding:         c.in.COI.ContentEncoding,
-		ContentLanguage:         c.in.COI.ContentLanguage,
-		ContentType:             c.in.COI.ContentType,
-		Expires:                 c.in.COI.Expires,
-		GrantFullControl:        c.in.COI.GrantFullControl,
-		GrantRead:               c.in.COI.GrantRead,
-		GrantReadACP:            c.in.COI.GrantReadACP,
-		GrantWriteACP:           c.in.COI.GrantWriteACP,
-		Key:                     c.in.COI.Key,
-		Metadata:                c.in.COI.Metadata,
-		RequestPayer:            c.in.COI.RequestPayer,
-		SSECustomerAlgorithm:    c.in.COI.CopySourceSSECustomerAlgorithm,
-		SSECustomerKey:          c.in.COI.SSECustomerKey,
-		SSECustomerKeyMD5:       c.in.COI.SSECustomerKeyMD5,
-		SSEKMSKeyId:             c.in.COI.SSEKMSKeyId,
-		ServerSideEncryption:    c.in.COI.ServerSideEncryption,
-		StorageClass:            c.in.COI.StorageClass,
-		Tagging:                 c.in.COI.Tagging,
-		WebsiteRedirectLocation: c.in.COI.WebsiteRedirectLocation,
-	}
-	resp, err := c.cfg.S3.CreateMultipartUploadWithContext(c.ctx, cmui)
+		ACL:                       c.in.COI.ACL,
+		Bucket:                    c.in.COI.Bucket,
+		BucketKeyEnabled:          c.in.COI.BucketKeyEnabled,
+		ChecksumAlgorithm:         c.in.COI.ChecksumAlgorithm,
+		CacheControl:              c.in.COI.CacheControl,
+		ContentDisposition:        c.in.COI.ContentDisposition,
+		ContentEncoding:           c.in.COI.ContentEncoding,
+		ContentLanguage:           c.in.COI.ContentLanguage,
+		ContentType:               c.in.COI.ContentType,
+		ExpectedBucketOwner:       c.in.COI.ExpectedBucketOwner,
+		Expires:                   c.in.COI.Expires,
+		GrantFullControl:          c.in.COI.GrantFullControl,
+		GrantRead:                 c.in.COI.GrantRead,
+		GrantReadACP:              c.in.COI.GrantReadACP,
+		GrantWriteACP:             c.in.COI.GrantWriteACP,
+		Key:                       c.in.COI.Key,
+		Metadata:                  c.in.COI.Metadata,
+		ObjectLockLegalHoldStatus: c.in.COI.ObjectLockLegalHoldStatus,
+		ObjectLockMode:            c.in.COI.ObjectLockMode,
+		ObjectLockRetainUntilDate: c.in.COI.ObjectLockRetainUntilDate,
+		RequestPayer:              c.in.COI.RequestPayer,
+		SSECustomerAlgorithm:      c.in.COI.SSECustomerAlgorithm,
+		SSECustomerKey:            c.in.COI.SSECustomerKey,
+		SSECustomerKeyMD5:         c.in.COI.SSECustomerKeyMD5,
+		SSEKMSEncryptionContext:   c.in.COI.SSEKMSEncryptionContext,
+		SSEKMSKeyId:               c.in.COI.SSEKMSKeyId,
+		ServerSideEncryption:      c.in.COI.ServerSideEncryption,
+		StorageClass:              c.in.COI.StorageClass,
+		Tagging:                   c.in.COI.Tagging,
+		WebsiteRedirectLocation:   c.in.COI.WebsiteRedirectLocation,
+	}
+	ctx, opts, done := c.startCall("CreateMultipartUpload", attrSize.Int64(*c.contentLength))
+	resp, err := c.cfg.S3.CreateMultipartUploadWithContext(ctx, cmui, opts...)
+	done(err)
 	if err != nil {
-		// TODO(ro) 2018-02-06 parse for awserr?
+		c.logger().Error("failed to start multipart copy", c.logAttrs(errAttrs(err)...)...)
 		c.setErr(err)
 		return err
 	}
 
 	c.MultipartUploadID = resp.UploadId
+	c.logger().Debug("started multipart copy", c.logAttrs("size", *c.contentLength)...)
 	return nil
 }
 
+// wait collects part results until all the workers are done. A signal or
+// the Copier's Timeout fails the copy, which cancels the outstanding work.
 func (c *copier) wait() {
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	timeout := time.NewTimer(c.cfg.Timeout)
+	defer timeout.Stop()
+
 	done := make(chan struct{})
 	go func() {
-		c.wg.Wait()
-		done <- struct{}{}
+		c.collect()
+		close(done)
 	}()
 
 	select {
 	case <-done:
 		return
 	case sig := <-sigs:
+		c.fail(fmt.Errorf("caught signal %s", sig))
+	case <-timeout.C:
+		c.fail(fmt.Errorf("copy timed out after %s", c.cfg.Timeout))
+	}
+
+	// The workers return promptly once the context is cancelled.
+	<-done
+}
+
+// fail records err, if it is the first error, and cancels the copy.
+func (c *copier) fail(err error) {
+	c.setErr(err)
+	if c.cancel != nil {
 		c.cancel()
-		log.Printf("Caught signal %s\n", sig)
-		os.Exit(0)
-	case <-time.After(c.cfg.Timeout):
-		c.cancel()
-		log.Printf("Copy timed out in %s seconds\n", c.cfg.Timeout)
-		os.Exit(1)
 	}
 }
 
@@ -470,11 +961,14 @@ func (c *copier) getErr() error {
 	return c.err
 }
 
+// setErr records e unless an error has already been recorded.
 func (c *copier) setErr(e error) {
 	c.Lock()
 	defer c.Unlock()
 
-	c.err = e
+	if c.err == nil {
+		c.err = e
+	}
 }
 
 // maxRetrier provices an interface to MaRetries. This was copied from aws sdk.
@@ -4,12 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/reedobrien/checkers"
 	"github.com/reedobrien/s3cp/lib/dummy"
@@ -47,6 +50,7 @@ func TestGetContentLengthLookup(t *testing.T) {
 				CopySource: aws.String("bucket/key")},
 		},
 		cfg: Copier{SrcS3: api},
+		ctx: context.Background(),
 	}
 
 	tut.getContentLength()
@@ -66,6 +70,7 @@ func TestGetContentLengthLookupError(t *testing.T) {
 				CopySource: aws.String("bucket/key")},
 		},
 		cfg: Copier{SrcS3: api},
+		ctx: context.Background(),
 	}
 
 	tut.getContentLength()
@@ -197,7 +202,11 @@ func TestMultipartCopyPrivate(t *testing.T) {
 		},
 	}
 
-	cp := NewCopier(api, func(c *Copier) { c.Concurrency = 1 })
+	cp := NewCopier(api,
+		func(c *Copier) { c.Concurrency = 1 },
+		func(c *Copier) { c.SrcS3 = api },
+		func(c *Copier) { c.MustSvcForRegion = func(*string) API { return api } },
+	)
 
 	tut := copier{
 		cfg: *cp,
@@ -224,9 +233,7 @@ func TestMultipartCopyPrivate(t *testing.T) {
 }
 
 func TestMultipartCopyPrivateError(t *testing.T) {
-	logging := make(chan string, 100)
-	l := dummy.NewLogOutput(logging)
-	defer l.Reset()
+	logger := dummy.NewLogger()
 
 	api := dummy.NewS3API("", func(d *dummy.S3API) {
 		d.Coo = &s3.CopyObjectOutput{
@@ -255,30 +262,157 @@ func TestMultipartCopyPrivateError(t *testing.T) {
 		},
 	}
 
-	cp := NewCopier(api, func(c *Copier) { c.Concurrency = 1 })
+	cp := NewCopier(api,
+		func(c *Copier) { c.Concurrency = 1 },
+		func(c *Copier) { c.Logger = logger },
+		func(c *Copier) { c.SrcS3 = api },
+		func(c *Copier) { c.MustSvcForRegion = func(*string) API { return api } },
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
 	tut := copier{
-		cfg: *cp,
-		ctx: context.Background(),
-		in:  in,
+		cfg:    *cp,
+		ctx:    ctx,
+		cancel: cancel,
+		in:     in,
 	}
 
 	err := tut.copy()
-	checkers.OK(t, err)
+	checkers.Equals(t, err.Error(), "multipart copy an-id failed for part(s) 1: upcBoomCode: upcBoomMsg\ncaused by: upcBboom")
 	checkers.Equals(t, api.CmpCalls, int64(1))
 
-	var out string
-	func() {
-		for {
-			select {
-			case s := <-logging:
-				out = out + s
-			case <-time.After(10 * time.Millisecond):
-				return
-			}
-		}
-	}()
-	checkers.Assert(t, strings.Contains(out, "Part: 1"), "missing part 1")
-	checkers.Assert(t, strings.Contains(out, "Part: 2"), "missing part 2")
-	checkers.Equals(t, api.UpcCalls, int64(2))
+	entries := logger.Entries()
+	checkers.Equals(t, entries[1].Msg, "failed to copy part")
+	checkers.Equals(t, entries[1].Attrs["part"], int64(1))
+	checkers.Equals(t, entries[1].Attrs["upload_id"], "an-id")
+	checkers.Equals(t, entries[1].Attrs["code"], "upcBoomCode")
+	checkers.Equals(t, api.UpcCalls, int64(1))
+	checkers.Equals(t, api.AmuCalls, int64(1))
+	checkers.Equals(t, api.CmpuCalls, int64(0))
+}
+
+func TestCopyPartAttempts(t *testing.T) {
+	api := dummy.NewS3API("", func(d *dummy.S3API) {
+		d.UpcErr = awserr.New("SlowDown", "slow down", nil)
+	})
+
+	tut := copier{
+		cfg:        Copier{S3: api},
+		ctx:        context.Background(),
+		maxRetries: 2,
+	}
+
+	resp, perr := tut.copyPart(multipartCopyInput{
+		PartNumber:      7,
+		CopySourceRange: aws.String("bytes=60-69"),
+	})
+
+	checkers.Assert(t, resp == nil, "expected nil response")
+	checkers.Equals(t, perr.PartNumber, int64(7))
+	checkers.Equals(t, perr.Range, "bytes=60-69")
+	checkers.Equals(t, perr.Code, "SlowDown")
+	checkers.Equals(t, perr.Attempts, 3)
+	checkers.Equals(t, api.UpcCalls, int64(3))
+}
+
+func TestCopyPartLogRedacted(t *testing.T) {
+	logger := dummy.NewLogger()
+	api := dummy.NewS3API("", func(d *dummy.S3API) {
+		d.UpcErr = errors.New("boom")
+	})
+
+	coi := newCOI()
+	coi.SSECustomerKey = aws.String("sekrit")
+	coi.CopySourceSSECustomerKey = aws.String("sekrit")
+
+	tut := copier{
+		cfg: Copier{S3: api, Logger: logger},
+		ctx: context.Background(),
+		in:  CopyInput{COI: *coi},
+	}
+
+	_, perr := tut.copyPart(multipartCopyInput{PartNumber: 1})
+	checkers.Assert(t, perr != nil, "expected part error")
+
+	entries := logger.Entries()
+	checkers.Equals(t, len(entries), 1)
+	checkers.Equals(t, entries[0].Level, "WARN")
+	checkers.Equals(t, entries[0].Attrs["attempt"], 1)
+	checkers.Assert(t, !strings.Contains(fmt.Sprint(entries), "sekrit"), "SSE-C key logged: %v", entries)
+}
+
+func TestRegionClient(t *testing.T) {
+	creds := credentials.NewStaticCredentials("id", "secret", "")
+	base := s3.New(session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Endpoint:    aws.String("http://localhost:9000"),
+		Credentials: creds,
+	})))
+	cfg := Copier{S3: base, Clients: NewClientPool(), Concurrency: 50}
+
+	same, err := cfg.regionClient("us-east-1")
+	checkers.OK(t, err)
+	checkers.Assert(t, same == API(base), "expected the configured client")
+
+	api, err := cfg.regionClient("eu-west-1")
+	checkers.OK(t, err)
+	west := api.(*s3.S3)
+	checkers.Equals(t, *west.Config.Region, "eu-west-1")
+	checkers.Equals(t, *west.Config.Endpoint, "http://localhost:9000")
+	checkers.Assert(t, west.Config.Credentials == creds, "expected shared credentials")
+	checkers.Equals(t, west.Config.HTTPClient.Transport.(*http.Transport).MaxIdleConnsPerHost, 50)
+
+	again, err := cfg.regionClient("eu-west-1")
+	checkers.OK(t, err)
+	checkers.Assert(t, again == api, "expected the pooled client")
+}
+
+func TestComposeLayout(t *testing.T) {
+	const m = MinPartSize
+	for _, tc := range []struct {
+		name  string
+		sizes []int64
+		want  []composePart
+	}{
+		{
+			name:  "tail merged",
+			sizes: []int64{2*m - 1},
+			want:  []composePart{{ranges: []byteRange{{0, 0, 2*m - 1}}}},
+		},
+		{
+			name:  "chunked",
+			sizes: []int64{3*m - 1},
+			want: []composePart{
+				{ranges: []byteRange{{0, 0, m}}},
+				{ranges: []byteRange{{0, m, 3*m - 1}}},
+			},
+		},
+		{
+			name:  "small borrows from next",
+			sizes: []int64{1, 2 * m},
+			want: []composePart{
+				{ranges: []byteRange{{0, 0, 1}, {1, 0, m - 1}}, upload: true},
+				{ranges: []byteRange{{1, m - 1, 2 * m}}},
+			},
+		},
+		{
+			name:  "small merged with short next",
+			sizes: []int64{1, m + 1, 10},
+			want: []composePart{
+				{ranges: []byteRange{{0, 0, 1}, {1, 0, m + 1}}, upload: true},
+				{ranges: []byteRange{{2, 0, 10}}, upload: true},
+			},
+		},
+		{
+			name:  "empty sources",
+			sizes: []int64{0, 0},
+			want:  []composePart{{upload: true}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			checkers.Equals(t, composeLayout(tc.sizes, m), tc.want)
+		})
+	}
 }
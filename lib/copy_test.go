@@ -1,8 +1,9 @@
 package s3cp_test
 
 import (
+	"context"
 	"errors"
-	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -126,9 +127,7 @@ func TestCopyDeleteCalled(t *testing.T) {
 }
 
 func TestCopyDeleteError(t *testing.T) {
-	logging := make(chan string, 10)
-	l := dummy.NewLogOutput(logging)
-	defer l.Reset()
+	logger := dummy.NewLogger()
 
 	api := dummy.NewS3API("", func(d *dummy.S3API) {
 		d.Coo = &s3.CopyObjectOutput{
@@ -160,17 +159,25 @@ func TestCopyDeleteError(t *testing.T) {
 			c.MustSvcForRegion = func(_ *string) s3cp.API {
 				return api2
 			}
+			c.Logger = logger
 		},
 	)
 
 	err := tut.Copy(in, func(c *s3cp.Copier) { c.Concurrency = 1 })
 	checkers.OK(t, err)
 	checkers.Equals(t, api2.DooCalls, int64(1))
-	checkers.Assert(t,
-		strings.HasSuffix(
-			<-logging,
-			"failed to delete \"bucket/key\": \"delete boom\"\n"),
-		"missing expected log message")
+
+	entries := logger.Entries()
+	checkers.Equals(t, entries[len(entries)-1], dummy.Entry{
+		Level: "ERROR",
+		Msg:   "failed to delete source",
+		Attrs: map[string]interface{}{
+			"source": "bucket/key",
+			"bucket": "",
+			"key":    "",
+			"error":  "delete boom",
+		},
+	})
 }
 
 func TestCopyDeleteMissingSourceError(t *testing.T) {
@@ -206,7 +213,7 @@ func TestCopyDeleteMissingSourceError(t *testing.T) {
 	)
 
 	err := tut.Copy(in, func(c *s3cp.Copier) { c.Concurrency = 1 })
-	checkers.OK(t, err)
+	checkers.Equals(t, err.Error(), "got nil *string as CopySource")
 	checkers.Equals(t, api2.DooCalls, int64(0))
 }
 
@@ -242,9 +249,7 @@ func TestSinglePartCopy(t *testing.T) {
 }
 
 func TestSinglePartCopyAWSErr(t *testing.T) {
-	logging := make(chan string, 100)
-	l := dummy.NewLogOutput(logging)
-	defer l.Reset()
+	logger := dummy.NewLogger()
 
 	api := dummy.NewS3API("", func(d *dummy.S3API) {
 		d.Coo = &s3.CopyObjectOutput{
@@ -272,19 +277,28 @@ func TestSinglePartCopyAWSErr(t *testing.T) {
 					d.Hoo = &s3.HeadObjectOutput{ContentLength: aws.Int64(int64(6))}
 				})
 			}
+			c.Logger = logger
 		},
 	)
 
 	err := tut.Copy(in, func(c *s3cp.Copier) { c.Concurrency = 1 })
 	checkers.Equals(t, err.Error(), "boomCode: boomMsg\ncaused by: boom")
 
-	checkers.Assert(t, strings.HasSuffix(<-logging, "failed to copy \"bucket/key\" to \"abucket/akey\": boomCode: boomMsg\ncaused by: boom\n"), "missing expected log message")
+	checkers.Equals(t, logger.Entries()[0], dummy.Entry{
+		Level: "ERROR",
+		Msg:   "failed to copy",
+		Attrs: map[string]interface{}{
+			"source": "bucket/key",
+			"bucket": "abucket",
+			"key":    "akey",
+			"error":  "boomCode: boomMsg\ncaused by: boom",
+			"code":   "boomCode",
+		},
+	})
 }
 
 func TestSinglePartCopyError(t *testing.T) {
-	logging := make(chan string, 100)
-	l := dummy.NewLogOutput(logging)
-	defer l.Reset()
+	logger := dummy.NewLogger()
 
 	api := dummy.NewS3API("", func(d *dummy.S3API) {
 		d.Coo = &s3.CopyObjectOutput{
@@ -312,12 +326,22 @@ func TestSinglePartCopyError(t *testing.T) {
 					d.Hoo = &s3.HeadObjectOutput{ContentLength: aws.Int64(int64(6))}
 				})
 			}
+			c.Logger = logger
 		},
 	)
 
 	err := tut.Copy(in, func(c *s3cp.Copier) { c.Concurrency = 1 })
 	checkers.Equals(t, err.Error(), "boom")
-	checkers.Assert(t, strings.HasSuffix(<-logging, "failed to copy \"bucket/key\" to \"abucket/akey\": boom\n"), "missing expected log message")
+	checkers.Equals(t, logger.Entries()[0], dummy.Entry{
+		Level: "ERROR",
+		Msg:   "failed to copy",
+		Attrs: map[string]interface{}{
+			"source": "bucket/key",
+			"bucket": "abucket",
+			"key":    "akey",
+			"error":  "boom",
+		},
+	})
 }
 
 func TestStartMultipartError(t *testing.T) {
@@ -434,3 +458,267 @@ func TestMultipartCopyLong(t *testing.T) {
 	err := tut.Copy(in, func(c *s3cp.Copier) { c.Concurrency = 1 })
 	checkers.OK(t, err)
 }
+
+// newFailingPartAPI returns an API whose UploadPartCopy fails for part
+// failPart and blocks every other part until the request is cancelled.
+func newFailingPartAPI(failPart int64) *dummy.S3API {
+	return dummy.NewS3API("", func(d *dummy.S3API) {
+		d.Cmp = &s3.CreateMultipartUploadOutput{
+			UploadId: aws.String("an-id"),
+		}
+		d.UpcFunc = func(ctx aws.Context, in *s3.UploadPartCopyInput) (*s3.UploadPartCopyOutput, error) {
+			if *in.PartNumber == failPart {
+				return nil, awserr.New("upcBoomCode", "upcBoomMsg", nil)
+			}
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+	})
+}
+
+// copyAsync runs the copy in a goroutine so a test can fail rather than hang
+// if the copy deadlocks.
+func copyAsync(ctx context.Context, t *testing.T, cp *s3cp.Copier, in s3cp.CopyInput) error {
+	errs := make(chan error, 1)
+	go func() { errs <- cp.CopyWithContext(ctx, in) }()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("copy did not return")
+	}
+	return nil
+}
+
+func TestMultipartCopyPartFailureCancels(t *testing.T) {
+	api := newFailingPartAPI(3)
+	in := s3cp.CopyInput{
+		Size: 80,
+		COI: s3.CopyObjectInput{
+			Bucket:     aws.String("abucket"),
+			CopySource: aws.String("bucket/key"),
+			Key:        aws.String("akey"),
+		},
+	}
+
+	tut := s3cp.NewCopier(api,
+		func(c *s3cp.Copier) { c.PartSize = 10 },
+		func(c *s3cp.Copier) { c.Concurrency = 4 },
+	)
+
+	err := copyAsync(context.Background(), t, tut, in)
+	mce, ok := err.(*s3cp.MultipartCopyError)
+	checkers.Assert(t, ok, "got %T, want *s3cp.MultipartCopyError", err)
+	checkers.Equals(t, mce.UploadID, "an-id")
+	checkers.Equals(t, mce.Err.Error(), "upcBoomCode: upcBoomMsg")
+	checkers.Equals(t, api.AmuCalls, int64(1))
+	checkers.Equals(t, api.CmpuCalls, int64(0))
+	checkers.Assert(t, api.UpcCalls < 8, "expected remaining parts to be skipped")
+}
+
+func TestMultipartCopyPartFailureLeavePartsOnError(t *testing.T) {
+	api := newFailingPartAPI(1)
+	in := s3cp.CopyInput{
+		Size: 80,
+		COI: s3.CopyObjectInput{
+			Bucket:     aws.String("abucket"),
+			CopySource: aws.String("bucket/key"),
+			Key:        aws.String("akey"),
+		},
+	}
+
+	tut := s3cp.NewCopier(api,
+		func(c *s3cp.Copier) { c.PartSize = 10 },
+		func(c *s3cp.Copier) { c.Concurrency = 2 },
+		func(c *s3cp.Copier) { c.LeavePartsOnError = true },
+	)
+
+	err := copyAsync(context.Background(), t, tut, in)
+	mce, ok := err.(*s3cp.MultipartCopyError)
+	checkers.Assert(t, ok, "got %T, want *s3cp.MultipartCopyError", err)
+	checkers.Equals(t, mce.Err.Error(), "upcBoomCode: upcBoomMsg")
+	checkers.Equals(t, mce.Parts[0].PartNumber, int64(1))
+	checkers.Equals(t, api.AmuCalls, int64(0))
+	checkers.Equals(t, api.CmpuCalls, int64(0))
+}
+
+func TestMultipartCopyContextCancelled(t *testing.T) {
+	api := newFailingPartAPI(0)
+	in := s3cp.CopyInput{
+		Size: 80,
+		COI: s3.CopyObjectInput{
+			Bucket:     aws.String("abucket"),
+			CopySource: aws.String("bucket/key"),
+			Key:        aws.String("akey"),
+		},
+	}
+
+	tut := s3cp.NewCopier(api,
+		func(c *s3cp.Copier) { c.PartSize = 10 },
+		func(c *s3cp.Copier) { c.Concurrency = 3 },
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	err := copyAsync(ctx, t, tut, in)
+	mce, ok := err.(*s3cp.MultipartCopyError)
+	checkers.Assert(t, ok, "got %T, want *s3cp.MultipartCopyError", err)
+	checkers.Equals(t, mce.Err, context.Canceled)
+	// The 3 parts in flight are cancelled and the other 5 never start.
+	checkers.Equals(t, len(mce.Parts), 8)
+	var unstarted int
+	for _, p := range mce.Parts {
+		checkers.Assert(t, p.Cancelled, "expected part %d to be cancelled", p.PartNumber)
+		if p.Attempts == 0 {
+			unstarted++
+		}
+	}
+	checkers.Equals(t, unstarted, 5)
+	checkers.Equals(t, mce.Parts[7].Range, "bytes=70-79")
+	checkers.Equals(t, mce.Error(), "multipart copy an-id was cancelled: context canceled")
+	checkers.Equals(t, api.AmuCalls, int64(1))
+	checkers.Equals(t, api.CmpuCalls, int64(0))
+}
+
+func TestMultipartCopyTimeout(t *testing.T) {
+	api := newFailingPartAPI(0)
+	in := s3cp.CopyInput{
+		Size: 80,
+		COI: s3.CopyObjectInput{
+			Bucket:     aws.String("abucket"),
+			CopySource: aws.String("bucket/key"),
+			Key:        aws.String("akey"),
+		},
+	}
+
+	tut := s3cp.NewCopier(api,
+		func(c *s3cp.Copier) { c.PartSize = 10 },
+		func(c *s3cp.Copier) { c.Concurrency = 3 },
+		func(c *s3cp.Copier) { c.Timeout = 10 * time.Millisecond },
+	)
+
+	err := copyAsync(context.Background(), t, tut, in)
+	mce, ok := err.(*s3cp.MultipartCopyError)
+	checkers.Assert(t, ok, "got %T, want *s3cp.MultipartCopyError", err)
+	checkers.Equals(t, mce.Err.Error(), "copy timed out after 10ms")
+	checkers.Equals(t, api.AmuCalls, int64(1))
+}
+
+func TestMultipartCopyErrorAggregatesParts(t *testing.T) {
+	var started sync.WaitGroup
+	started.Add(4)
+	api := dummy.NewS3API("", func(d *dummy.S3API) {
+		d.Cmp = &s3.CreateMultipartUploadOutput{
+			UploadId: aws.String("an-id"),
+		}
+		d.UpcFunc = func(ctx aws.Context, in *s3.UploadPartCopyInput) (*s3.UploadPartCopyOutput, error) {
+			// Wait until every part is in flight so both failures land.
+			started.Done()
+			started.Wait()
+			switch *in.PartNumber {
+			case 2, 3:
+				return nil, awserr.New("InternalError", "boom", nil)
+			}
+			return &s3.UploadPartCopyOutput{
+				CopyPartResult: &s3.CopyPartResult{ETag: aws.String("etag")},
+			}, nil
+		}
+	})
+	in := s3cp.CopyInput{
+		Size: 40,
+		COI: s3.CopyObjectInput{
+			Bucket:     aws.String("abucket"),
+			CopySource: aws.String("bucket/key"),
+			Key:        aws.String("akey"),
+		},
+	}
+
+	tut := s3cp.NewCopier(api,
+		func(c *s3cp.Copier) { c.PartSize = 10 },
+		func(c *s3cp.Copier) { c.Concurrency = 4 },
+	)
+
+	err := copyAsync(context.Background(), t, tut, in)
+	mce, ok := err.(*s3cp.MultipartCopyError)
+	checkers.Assert(t, ok, "got %T, want *s3cp.MultipartCopyError", err)
+	checkers.Equals(t, len(mce.Parts), 2)
+	checkers.Equals(t, mce.Parts[0].PartNumber, int64(2))
+	checkers.Equals(t, mce.Parts[0].Range, "bytes=10-19")
+	checkers.Equals(t, mce.Parts[0].Code, "InternalError")
+	checkers.Equals(t, mce.Parts[0].Attempts, 1)
+	checkers.Equals(t, mce.Parts[1].PartNumber, int64(3))
+	checkers.Equals(t, mce.Parts[1].Range, "bytes=20-29")
+	checkers.Equals(t, mce.Error(), "multipart copy an-id failed for part(s) 2, 3: InternalError: boom")
+}
+
+func TestMultipartCopyMetrics(t *testing.T) {
+	api := dummy.NewS3API("", func(d *dummy.S3API) {
+		d.Cmp = &s3.CreateMultipartUploadOutput{
+			UploadId: aws.String("an-id"),
+		}
+		d.Upc = &s3.UploadPartCopyOutput{
+			CopyPartResult: &s3.CopyPartResult{
+				ETag: aws.String("someetag"),
+			},
+		}
+	})
+	in := s3cp.CopyInput{
+		Size: 25,
+		COI: s3.CopyObjectInput{
+			Bucket:     aws.String("abucket"),
+			CopySource: aws.String("bucket/key"),
+			Key:        aws.String("akey"),
+		},
+	}
+	m := dummy.NewMetrics()
+
+	tut := s3cp.NewCopier(api,
+		func(c *s3cp.Copier) { c.PartSize = 10 },
+		func(c *s3cp.Copier) { c.Concurrency = 2 },
+		func(c *s3cp.Copier) { c.Metrics = m },
+	)
+
+	err := copyAsync(context.Background(), t, tut, in)
+	checkers.OK(t, err)
+	checkers.Equals(t, m.BytesCopied, int64(25))
+	checkers.Equals(t, m.PartsSucceeded, int64(3))
+	checkers.Equals(t, m.PartsFailed, int64(0))
+	checkers.Equals(t, m.PartsInFlight, int64(0))
+	checkers.Equals(t, m.Calls, map[string]int{
+		"HeadBucket":              2,
+		"HeadObject":              1,
+		"GetObjectTagging":        1,
+		"CreateMultipartUpload":   1,
+		"UploadPartCopy":          3,
+		"CompleteMultipartUpload": 1,
+	})
+}
+
+func TestMultipartCopyMetricsFailure(t *testing.T) {
+	api := newFailingPartAPI(1)
+	in := s3cp.CopyInput{
+		Size: 40,
+		COI: s3.CopyObjectInput{
+			Bucket:     aws.String("abucket"),
+			CopySource: aws.String("bucket/key"),
+			Key:        aws.String("akey"),
+		},
+	}
+	m := dummy.NewMetrics()
+
+	tut := s3cp.NewCopier(api,
+		func(c *s3cp.Copier) { c.PartSize = 10 },
+		func(c *s3cp.Copier) { c.Concurrency = 1 },
+		func(c *s3cp.Copier) { c.Metrics = m },
+	)
+
+	err := copyAsync(context.Background(), t, tut, in)
+	checkers.Assert(t, err != nil, "expected an error")
+	checkers.Equals(t, m.BytesCopied, int64(0))
+	checkers.Equals(t, m.PartsFailed, int64(1))
+	checkers.Equals(t, m.PartsInFlight, int64(0))
+	checkers.Equals(t, m.UploadsAborted, int64(1))
+	checkers.Equals(t, m.Calls["AbortMultipartUpload"], 1)
+}
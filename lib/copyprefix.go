@@ -0,0 +1,169 @@
+package s3cp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// CopyPrefixInput is a parameter container for Copier.CopyPrefix.
+type CopyPrefixInput struct {
+	// Source is the bucket and key prefix of the objects to copy.
+	Source Location
+
+	// Destination is the bucket and key prefix they are copied to. Each
+	// key has the Source prefix replaced with the Destination's.
+	Destination Location
+
+	// Filter, if set, selects the objects to copy.
+	Filter *Filter
+
+	// Mapper, if set, maps each key with the Source prefix removed to the
+	// key given the Destination prefix. Objects mapped to a key another
+	// object already maps to fail.
+	Mapper *KeyMapper
+
+	// Template is the CopyInput each listed object is copied with, e.g.
+	// with Delete set to move the prefix. The object's listed size and keys
+	// are filled in, and CopySourceIfMatch is set to its listed ETag.
+	Template CopyInput
+
+	// How many objects to copy at once. Defaults to
+	// DefaultObjectConcurrency.
+	Concurrency int
+}
+
+// CopyPrefix copies every object under the source prefix that the Filter
+// selects. The source is listed with a client in its region, and the
+// listing gives each object's size, so none are HEADed.
+// progress, if not nil, is called with each object's result, including
+// those filtered out; calls are serialized. An error is returned if the
+// input is invalid, the listing fails or any object fails.
+func (c Copier) CopyPrefix(ctx aws.Context, in CopyPrefixInput, progress func(ObjectResult)) (Summary, error) {
+	var sum Summary
+
+	if err := in.validate(); err != nil {
+		return sum, err
+	}
+	f, err := in.Filter.compile()
+	if err != nil {
+		return sum, err
+	}
+	m, err := in.Mapper.compile()
+	if err != nil {
+		return sum, err
+	}
+	lister, err := c.sourceLister(ctx, in.Source, in.Template)
+	if err != nil {
+		return sum, err
+	}
+
+	src, owner, payer := in.Source, in.Template.COI.ExpectedSourceBucketOwner, in.Template.SourceRequestPayer
+	dsts := newDestinations(in, m)
+	b := &bulk{progress: progress}
+	err = b.run(ctx, in.Concurrency, func(ctx aws.Context, out chan<- *s3.Object) error {
+		return lister.produceObjects(ctx, b, src.Bucket, src.Key, aws.StringValue(owner), payer, nil, f, out)
+	}, func(ctx aws.Context, o *s3.Object) ObjectResult {
+		return c.copyListed(ctx, in, lister, f, dsts, o)
+	})
+	sum = b.sum
+
+	if err != nil {
+		return sum, fmt.Errorf("error listing %s: %s", src, err)
+	}
+	if sum.Failed > 0 {
+		return sum, fmt.Errorf("%d of %d objects failed", sum.Failed, sum.Copied+sum.Failed)
+	}
+	return sum, nil
+}
+
+// copyListed copies the object o, listed by lister, to the key dsts gives it
+// if it has the tags f requires.
+func (c Copier) copyListed(ctx aws.Context, in CopyPrefixInput, lister Copier, f *filter, dsts *destinations, o *s3.Object) ObjectResult {
+	key := aws.StringValue(o.Key)
+	r := ObjectResult{Key: key}
+
+	tagged, err := lister.sourceTagged(ctx, in, f, key)
+	if err != nil || !tagged {
+		r.Err, r.Filtered = err, err == nil
+		return r
+	}
+
+	dst, err := dsts.claim(o)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+
+	ci := in.Template
+	ci.Size = aws.Int64Value(o.Size)
+	ci.COI.Bucket = aws.String(in.Destination.Bucket)
+	ci.COI.Key = aws.String(dst)
+	ci.COI.CopySource = aws.String(Location{Bucket: in.Source.Bucket, Key: key}.CopySource())
+	// The listed size is only right for the listed object, so a key
+	// replaced since fails rather than being copied short.
+	ci.COI.CopySourceIfMatch = o.ETag
+
+	r.Err = c.CopyWithContext(ctx, ci)
+	return r
+}
+
+// sourceTagged reports whether the source object key has the tags f
+// requires, fetching them only if f checks tags. c must be a sourceLister.
+func (c Copier) sourceTagged(ctx aws.Context, in CopyPrefixInput, f *filter, key string) (bool, error) {
+	if !f.needsTags() {
+		return true, nil
+	}
+
+	tags, err := c.S3.GetObjectTaggingWithContext(ctx, &s3.GetObjectTaggingInput{
+		Bucket:              aws.String(in.Source.Bucket),
+		ExpectedBucketOwner: in.Template.COI.ExpectedSourceBucketOwner,
+		Key:                 aws.String(key),
+		RequestPayer:        in.Template.SourceRequestPayer,
+	}, c.RequestOptions...)
+	if err != nil {
+		return false, err
+	}
+	return f.tagged(tags.TagSet), nil
+}
+
+// sourceLister returns a Copier whose S3 is a client in the region of the
+// source bucket, for listing or reading it. template gives its SourceRegion
+// and expected owner.
+func (c Copier) sourceLister(ctx aws.Context, source Location, template CopyInput) (Copier, error) {
+	region := aws.StringValue(template.SourceRegion)
+	if region == "" {
+		impl := copier{in: template, cfg: c, ctx: ctx}
+		region = impl.bucketRegion(source.Bucket, template.COI.ExpectedSourceBucketOwner)
+	}
+	if region == "" {
+		return c, nil
+	}
+
+	api, err := c.regionClient(region)
+	if err != nil {
+		return c, err
+	}
+	c.S3 = api
+	return c, nil
+}
+
+func (in *CopyPrefixInput) validate() error {
+	src, dst := in.Source, in.Destination
+	if src.Bucket == "" || dst.Bucket == "" {
+		return errors.New("a prefix copy requires source and destination buckets")
+	}
+	if src.VersionID != "" || dst.VersionID != "" {
+		return errors.New("a prefix copy can't have a version ID")
+	}
+	// Copying a prefix onto itself leaves nothing to copy but the same
+	// objects, and a listing in progress can return the new copies under
+	// it. Keys mapped under the source are refused by destinations.claim.
+	if src.Bucket == dst.Bucket && strings.HasPrefix(dst.Key, src.Key) {
+		return fmt.Errorf("destination %s is under source %s", dst, src)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package s3cp_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/reedobrien/checkers"
+	s3cp "github.com/reedobrien/s3cp/lib"
+	"github.com/reedobrien/s3cp/lib/dummy"
+)
+
+// pathologicalKeys are keys a naive CopySource gets wrong.
+var pathologicalKeys = []string{
+	"with space",
+	"plus+sign",
+	"100%",
+	"50%25 encoded",
+	"what?",
+	"what?versionId=not-a-version",
+	"#hash",
+	"semi;colon,comma",
+	"amp&eq=sign",
+	`quote"apostrophe'`,
+	"back\\slash",
+	"tab\tnewline\n",
+	"~tilde_under-dash.dot",
+	"a//double/slash",
+	"./dot/../dotdot",
+	"trailing/",
+	"/leading",
+	"ünïcödé",
+	"ключ/日本語",
+	"emoji 🎉",
+	"arn:aws:s3:us-west-2:123456789012:accesspoint/not-an-ap",
+}
+
+// keyData is distinct, multipart sized data for key.
+func keyData(key string) []byte {
+	return bytes.Repeat([]byte(key+"|"), 30/(len(key)+1)+2)
+}
+
+func TestCopyPathologicalKeys(t *testing.T) {
+	for _, size := range []struct {
+		name     string
+		partSize int64
+	}{
+		{"single part", s3cp.DefaultCopyPartSize},
+		{"multipart", 10},
+	} {
+		for _, key := range pathologicalKeys {
+			t.Run(size.name+"/"+key, func(t *testing.T) {
+				fake := dummy.NewFake()
+				fake.PutObject("src", key, &dummy.Object{Data: keyData(key)})
+				// Decoys sit at the keys a mangled CopySource would name.
+				for _, decoy := range []string{
+					strings.ReplaceAll(key, "+", " "),
+					strings.SplitN(key, "?", 2)[0],
+					strings.SplitN(key, "#", 2)[0],
+				} {
+					if decoy != key && decoy != "" {
+						fake.PutObject("src", decoy, &dummy.Object{Data: []byte("decoy")})
+					}
+				}
+				fake.CreateBucket("dst")
+				tut := s3cp.NewCopier(fake, func(c *s3cp.Copier) { c.PartSize = size.partSize })
+
+				err := tut.Copy(s3cp.CopyInput{
+					Delete: true,
+					COI: s3.CopyObjectInput{
+						Bucket:     aws.String("dst"),
+						CopySource: aws.String(s3cp.Location{Bucket: "src", Key: key}.CopySource()),
+						Key:        aws.String(key),
+					},
+				})
+				checkers.OK(t, err)
+
+				checkers.Equals(t, fake.Keys("dst"), []string{key})
+				checkers.Equals(t, fake.Object("dst", key).Data, keyData(key))
+				checkers.Assert(t, fake.Object("src", key) == nil, "expected the source to be deleted")
+			})
+		}
+	}
+}
+
+func TestCopyCanonicalizesCopySource(t *testing.T) {
+	fake := dummy.NewFake()
+	fake.PutObject("src", "a+b c", &dummy.Object{Data: thirtyBytes})
+	fake.PutObject("src", "a b c", &dummy.Object{Data: []byte("decoy")})
+	fake.CreateBucket("dst")
+	tut := s3cp.NewCopier(fake, func(c *s3cp.Copier) { c.PartSize = 10 })
+
+	// url.PathEscape leaves the + a strict decoder takes for a space.
+	err := tut.Copy(s3cp.CopyInput{
+		COI: s3.CopyObjectInput{
+			Bucket:     aws.String("dst"),
+			CopySource: aws.String("src/a+b%20c"),
+			Key:        aws.String("key"),
+		},
+	})
+	checkers.OK(t, err)
+	checkers.Equals(t, fake.Object("dst", "key").Data, thirtyBytes)
+}
+
+func TestRewritePathologicalKeys(t *testing.T) {
+	for _, key := range pathologicalKeys {
+		t.Run(key, func(t *testing.T) {
+			fake := dummy.NewFake()
+			fake.PutObject("bucket", key, &dummy.Object{Data: keyData(key)})
+			tut := s3cp.NewCopier(fake, func(c *s3cp.Copier) { c.PartSize = 10 })
+
+			err := tut.Rewrite(context.Background(), s3cp.RewriteInput{
+				Bucket:       "bucket",
+				Key:          key,
+				StorageClass: s3.StorageClassStandardIa,
+			})
+			checkers.OK(t, err)
+
+			checkers.Equals(t, fake.Keys("bucket"), []string{key})
+			got := fake.Object("bucket", key)
+			checkers.Equals(t, got.Data, keyData(key))
+			checkers.Equals(t, got.StorageClass, s3.StorageClassStandardIa)
+		})
+	}
+}
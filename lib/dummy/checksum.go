@@ -0,0 +1,83 @@
+package dummy
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"hash/crc32"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func checksumHash(alg string) hash.Hash {
+	switch alg {
+	case s3.ChecksumAlgorithmCrc32:
+		return crc32.NewIEEE()
+	case s3.ChecksumAlgorithmCrc32c:
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	case s3.ChecksumAlgorithmSha1:
+		return sha1.New()
+	case s3.ChecksumAlgorithmSha256:
+		return sha256.New()
+	}
+	return nil
+}
+
+// checksum returns the base64 checksum of b with algorithm alg, or "" if
+// alg is unknown.
+func checksum(alg string, b []byte) string {
+	h := checksumHash(alg)
+	if h == nil {
+		return ""
+	}
+	h.Write(b)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// compositeChecksum returns the checksum of an object uploaded in parts.
+func compositeChecksum(alg string, parts [][]byte) string {
+	var sums []byte
+	for _, b := range parts {
+		raw, _ := base64.StdEncoding.DecodeString(checksum(alg, b))
+		sums = append(sums, raw...)
+	}
+	return fmt.Sprintf("%s-%d", checksum(alg, sums), len(parts))
+}
+
+// checksumFields returns sum in the response field of its algorithm, in the
+// order CRC32, CRC32C, SHA1 and SHA256.
+func checksumFields(alg, sum string) (*string, *string, *string, *string) {
+	var fields [4]*string
+	if sum == "" {
+		return nil, nil, nil, nil
+	}
+	switch alg {
+	case s3.ChecksumAlgorithmCrc32:
+		fields[0] = aws.String(sum)
+	case s3.ChecksumAlgorithmCrc32c:
+		fields[1] = aws.String(sum)
+	case s3.ChecksumAlgorithmSha1:
+		fields[2] = aws.String(sum)
+	case s3.ChecksumAlgorithmSha256:
+		fields[3] = aws.String(sum)
+	}
+	return fields[0], fields[1], fields[2], fields[3]
+}
+
+// partChecksum returns the checksum of alg a completed part was sent with.
+func partChecksum(alg string, p *s3.CompletedPart) string {
+	switch alg {
+	case s3.ChecksumAlgorithmCrc32:
+		return aws.StringValue(p.ChecksumCRC32)
+	case s3.ChecksumAlgorithmCrc32c:
+		return aws.StringValue(p.ChecksumCRC32C)
+	case s3.ChecksumAlgorithmSha1:
+		return aws.StringValue(p.ChecksumSHA1)
+	case s3.ChecksumAlgorithmSha256:
+		return aws.StringValue(p.ChecksumSHA256)
+	}
+	return ""
+}
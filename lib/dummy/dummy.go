@@ -1,6 +1,7 @@
 package dummy
 
 import (
+	"sync"
 	"sync/atomic"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -24,8 +25,12 @@ func NewS3API(region string, opts ...func(*S3API)) *S3API {
 
 // S3API is the API struct.
 type S3API struct {
+	sync.Mutex
 	region *string
 
+	Amu       *s3.AbortMultipartUploadOutput
+	AmuCalls  int64
+	AmuErr    error
 	Cmp       *s3.CreateMultipartUploadOutput
 	CmpCalls  int64
 	CmpErr    error
@@ -42,10 +47,56 @@ type S3API struct {
 	Cmpu      *s3.CompleteMultipartUploadOutput
 	CmpuErr   error
 	CmpuCalls int64
+	Lov       []*s3.ListObjectsV2Output
+	LovErr    error
+	Got       *s3.GetObjectTaggingOutput
+	GotErr    error
+	Goa       *s3.GetObjectAclOutput
+	GoaErr    error
+	Poa       *s3.PutObjectAclOutput
+	PoaErr    error
+	PoaCalls  int64
+	Golh      *s3.GetObjectLegalHoldOutput
+	GolhErr   error
+	Golc      *s3.GetObjectLockConfigurationOutput
+	GolcErr   error
+	Gor       *s3.GetObjectRetentionOutput
+	GorErr    error
+	Hbo       *s3.HeadBucketOutput
+	HboErr    error
+	HboCalls  int64
+	Gbl       *s3.GetBucketLocationOutput
+	GblErr    error
+	Goo       *s3.GetObjectOutput
+	GooErr    error
+	Upo       *s3.UploadPartOutput
+	UpoErr    error
+	UpoCalls  int64
+
+	// The inputs of the most recent calls, and of every UploadPartCopy call.
+	CooInput  *s3.CopyObjectInput
+	CmpInput  *s3.CreateMultipartUploadInput
+	HooInput  *s3.HeadObjectInput
+	UpcInputs []*s3.UploadPartCopyInput
+
+	// UpcFunc, if set, is called instead of returning Upc and UpcErr.
+	UpcFunc func(aws.Context, *s3.UploadPartCopyInput) (*s3.UploadPartCopyOutput, error)
+}
+
+// AbortMultipartUploadWithContext is a mock method.
+func (d *S3API) AbortMultipartUploadWithContext(_ aws.Context, in *s3.AbortMultipartUploadInput, opts ...request.Option) (*s3.AbortMultipartUploadOutput, error) {
+	_ = atomic.AddInt64(&d.AmuCalls, 1)
+	if d.AmuErr != nil {
+		return nil, d.AmuErr
+	}
+	return d.Amu, nil
 }
 
 // CopyObjectWithContext is a mock method.
 func (d *S3API) CopyObjectWithContext(_ aws.Context, in *s3.CopyObjectInput, opts ...request.Option) (*s3.CopyObjectOutput, error) {
+	d.Lock()
+	d.CooInput = in
+	d.Unlock()
 	if d.CooErr != nil {
 		return nil, d.CooErr
 	}
@@ -55,6 +106,9 @@ func (d *S3API) CopyObjectWithContext(_ aws.Context, in *s3.CopyObjectInput, opt
 // CreateMultipartUploadWithContext is a mock method.
 func (d *S3API) CreateMultipartUploadWithContext(_ aws.Context, in *s3.CreateMultipartUploadInput, ops ...request.Option) (*s3.CreateMultipartUploadOutput, error) {
 	_ = atomic.AddInt64(&d.CmpCalls, 1)
+	d.Lock()
+	d.CmpInput = in
+	d.Unlock()
 	if d.CmpErr != nil {
 		return nil, d.CmpErr
 	}
@@ -63,9 +117,15 @@ func (d *S3API) CreateMultipartUploadWithContext(_ aws.Context, in *s3.CreateMul
 
 // HeadObjectWithContext is a mock method.
 func (d *S3API) HeadObjectWithContext(ctx aws.Context, in *s3.HeadObjectInput, opts ...request.Option) (*s3.HeadObjectOutput, error) {
+	d.Lock()
+	d.HooInput = in
+	d.Unlock()
 	if d.HooErr != nil {
 		return nil, d.HooErr
 	}
+	if d.Hoo == nil {
+		return &s3.HeadObjectOutput{}, nil
+	}
 	return d.Hoo, nil
 }
 
@@ -81,6 +141,12 @@ func (d *S3API) DeleteObjectWithContext(ctx aws.Context, in *s3.DeleteObjectInpu
 // UploadPartCopyWithContext is a mock method.
 func (d *S3API) UploadPartCopyWithContext(ctx aws.Context, in *s3.UploadPartCopyInput, opts ...request.Option) (*s3.UploadPartCopyOutput, error) {
 	_ = atomic.AddInt64(&d.UpcCalls, 1)
+	d.Lock()
+	d.UpcInputs = append(d.UpcInputs, in)
+	d.Unlock()
+	if d.UpcFunc != nil {
+		return d.UpcFunc(ctx, in)
+	}
 	if d.UpcErr != nil {
 		return nil, d.UpcErr
 	}
@@ -97,6 +163,110 @@ func (d *S3API) CompleteMultipartUploadWithContext(ctx aws.Context, in *s3.Compl
 
 }
 
+// ListObjectsV2PagesWithContext is a mock method. It calls fn with each of
+// Lov in turn.
+func (d *S3API) ListObjectsV2PagesWithContext(ctx aws.Context, in *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool, opts ...request.Option) error {
+	if d.LovErr != nil {
+		return d.LovErr
+	}
+	for i, page := range d.Lov {
+		if !fn(page, i == len(d.Lov)-1) {
+			break
+		}
+	}
+	return nil
+}
+
+// GetObjectTaggingWithContext is a mock method.
+func (d *S3API) GetObjectTaggingWithContext(ctx aws.Context, in *s3.GetObjectTaggingInput, opts ...request.Option) (*s3.GetObjectTaggingOutput, error) {
+	if d.GotErr != nil {
+		return nil, d.GotErr
+	}
+	if d.Got == nil {
+		return &s3.GetObjectTaggingOutput{}, nil
+	}
+	return d.Got, nil
+}
+
+// GetObjectAclWithContext is a mock method.
+func (d *S3API) GetObjectAclWithContext(ctx aws.Context, in *s3.GetObjectAclInput, opts ...request.Option) (*s3.GetObjectAclOutput, error) {
+	if d.GoaErr != nil {
+		return nil, d.GoaErr
+	}
+	return d.Goa, nil
+}
+
+// PutObjectAclWithContext is a mock method.
+func (d *S3API) PutObjectAclWithContext(ctx aws.Context, in *s3.PutObjectAclInput, opts ...request.Option) (*s3.PutObjectAclOutput, error) {
+	_ = atomic.AddInt64(&d.PoaCalls, 1)
+	if d.PoaErr != nil {
+		return nil, d.PoaErr
+	}
+	return d.Poa, nil
+}
+
+// GetObjectLegalHoldWithContext is a mock method.
+func (d *S3API) GetObjectLegalHoldWithContext(ctx aws.Context, in *s3.GetObjectLegalHoldInput, opts ...request.Option) (*s3.GetObjectLegalHoldOutput, error) {
+	if d.GolhErr != nil {
+		return nil, d.GolhErr
+	}
+	return d.Golh, nil
+}
+
+// GetObjectLockConfigurationWithContext is a mock method.
+func (d *S3API) GetObjectLockConfigurationWithContext(ctx aws.Context, in *s3.GetObjectLockConfigurationInput, opts ...request.Option) (*s3.GetObjectLockConfigurationOutput, error) {
+	if d.GolcErr != nil {
+		return nil, d.GolcErr
+	}
+	return d.Golc, nil
+}
+
+// GetObjectRetentionWithContext is a mock method.
+func (d *S3API) GetObjectRetentionWithContext(ctx aws.Context, in *s3.GetObjectRetentionInput, opts ...request.Option) (*s3.GetObjectRetentionOutput, error) {
+	if d.GorErr != nil {
+		return nil, d.GorErr
+	}
+	return d.Gor, nil
+}
+
+// HeadBucketWithContext is a mock method. It returns an empty output if Hbo
+// is nil.
+func (d *S3API) HeadBucketWithContext(ctx aws.Context, in *s3.HeadBucketInput, opts ...request.Option) (*s3.HeadBucketOutput, error) {
+	_ = atomic.AddInt64(&d.HboCalls, 1)
+	if d.HboErr != nil {
+		return nil, d.HboErr
+	}
+	if d.Hbo == nil {
+		return &s3.HeadBucketOutput{}, nil
+	}
+	return d.Hbo, nil
+}
+
+// GetBucketLocationWithContext is a mock method.
+func (d *S3API) GetBucketLocationWithContext(ctx aws.Context, in *s3.GetBucketLocationInput, opts ...request.Option) (*s3.GetBucketLocationOutput, error) {
+	if d.GblErr != nil {
+		return nil, d.GblErr
+	}
+	return d.Gbl, nil
+}
+
+// GetObjectWithContext is a mock method.
+func (d *S3API) GetObjectWithContext(ctx aws.Context, in *s3.GetObjectInput, opts ...request.Option) (*s3.GetObjectOutput, error) {
+	if d.GooErr != nil {
+		return nil, d.GooErr
+	}
+	return d.Goo, nil
+}
+
+// UploadPartWithContext is a mock method.
+func (d *S3API) UploadPartWithContext(ctx aws.Context, in *s3.UploadPartInput, opts ...request.Option) (*s3.UploadPartOutput, error) {
+	_ = atomic.AddInt64(&d.UpoCalls, 1)
+	if d.UpoErr != nil {
+		return nil, d.UpoErr
+	}
+	return d.Upo, nil
+}
+
 // Region is a mock method.
 func (d *S3API) Region() string {
 	if d.region == nil {
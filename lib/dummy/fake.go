@@ -0,0 +1,1222 @@
+package dummy
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// FakeOwner is the owner of every object in a Fake.
+var FakeOwner = &s3.Owner{ID: aws.String("fake-owner-id")}
+
+// FakeAccountID owns the buckets in a Fake unless SetBucketOwner says
+// otherwise.
+const FakeAccountID = "111122223333"
+
+// NewFake returns an empty Fake.
+func NewFake() *Fake {
+	return &Fake{
+		buckets: make(map[string]map[string]*Object),
+		uploads: make(map[string]*fakeUpload),
+		calls:   make(map[string]int),
+		locking: make(map[string]bool),
+		owners:  make(map[string]string),
+
+		requesterPays: make(map[string]bool),
+		regions:       make(map[string]string),
+	}
+}
+
+// Buckets holds objects by bucket and key, for seeding a Fake with
+// NewFakeWith. A bucket without objects is created empty.
+type Buckets map[string]map[string]*Object
+
+// NewFakeWith returns a Fake holding buckets, stored with PutObject.
+func NewFakeWith(buckets Buckets) *Fake {
+	f := NewFake()
+	for bucket, objects := range buckets {
+		f.CreateBucket(bucket)
+		for key, o := range objects {
+			f.PutObject(bucket, key, o)
+		}
+	}
+	return f
+}
+
+// Fake is a goroutine safe, in-memory S3 implementing the s3cp API. Unlike
+// S3API it keeps state, objects, multipart uploads, tags and ACLs, so tests
+// can assert on what ends up in the buckets rather than on canned calls.
+type Fake struct {
+	sync.Mutex
+
+	// MinPartSize, if set, is enforced on all but the last part when a
+	// multipart upload is completed, as S3 does with 5MB.
+	MinPartSize int64
+
+	// RequireExpectedOwner, if set, denies calls that don't name the
+	// expected owner of every bucket they touch.
+	RequireExpectedOwner bool
+
+	buckets map[string]map[string]*Object
+	uploads map[string]*fakeUpload
+	calls   map[string]int
+	locking map[string]bool
+	owners  map[string]string
+	next    int
+
+	requesterPays map[string]bool
+	regions       map[string]string
+}
+
+// Object is an object stored in a Fake.
+type Object struct {
+	Data         []byte
+	ETag         string
+	LastModified time.Time
+
+	// PartSizes are the sizes of the parts of an object made by a multipart
+	// upload, and nil otherwise.
+	PartSizes []int64
+
+	// ChecksumAlgorithm, if set, is the object's additional checksum
+	// algorithm and Checksum its base64 checksum, which is the composite of
+	// its parts' if it has PartSizes.
+	ChecksumAlgorithm string
+	Checksum          string
+
+	CacheControl            string
+	ContentDisposition      string
+	ContentEncoding         string
+	ContentLanguage         string
+	ContentType             string
+	Expires                 *time.Time
+	Metadata                map[string]string
+	StorageClass            string
+	WebsiteRedirectLocation string
+
+	ServerSideEncryption    string
+	SSEKMSKeyID             string
+	SSEKMSEncryptionContext string
+	BucketKeyEnabled        bool
+	SSECustomerKeyMD5       string
+
+	Tags   map[string]string
+	ACL    string
+	Grants []*s3.Grant
+
+	ObjectLockMode            string
+	ObjectLockRetainUntilDate *time.Time
+	ObjectLockLegalHold       string
+}
+
+func (o *Object) clone() *Object {
+	c := *o
+	c.Data = append([]byte(nil), o.Data...)
+	c.PartSizes = append([]int64(nil), o.PartSizes...)
+	c.Metadata = cloneMap(o.Metadata)
+	c.Tags = cloneMap(o.Tags)
+	c.Grants = append([]*s3.Grant(nil), o.Grants...)
+	return &c
+}
+
+type fakeUpload struct {
+	bucket, key string
+	obj         *Object
+	parts       map[int64][]byte
+}
+
+// CreateBucket creates an empty bucket.
+func (f *Fake) CreateBucket(bucket string) {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.buckets[bucket] == nil {
+		f.buckets[bucket] = make(map[string]*Object)
+	}
+}
+
+// EnableObjectLock creates bucket, if needed, with Object Lock enabled.
+func (f *Fake) EnableObjectLock(bucket string) {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.buckets[bucket] == nil {
+		f.buckets[bucket] = make(map[string]*Object)
+	}
+	f.locking[bucket] = true
+}
+
+// SetBucketOwner makes account the owner of bucket, for checking the
+// ExpectedBucketOwner of calls.
+func (f *Fake) SetBucketOwner(bucket, account string) {
+	f.Lock()
+	defer f.Unlock()
+
+	f.owners[bucket] = account
+}
+
+// SetBucketRegion creates bucket, if needed, in region. Buckets are in no
+// region unless it is set.
+func (f *Fake) SetBucketRegion(bucket, region string) {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.buckets[bucket] == nil {
+		f.buckets[bucket] = make(map[string]*Object)
+	}
+	f.regions[bucket] = region
+}
+
+// EnableRequesterPays creates bucket, if needed, and denies calls to it that
+// don't confirm the requester pays.
+func (f *Fake) EnableRequesterPays(bucket string) {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.buckets[bucket] == nil {
+		f.buckets[bucket] = make(map[string]*Object)
+	}
+	f.requesterPays[bucket] = true
+}
+
+// PutObject stores o at bucket/key, creating the bucket if needed. The ETag,
+// LastModified, StorageClass and Grants are filled in if unset. The ETag is
+// that of a multipart upload if PartSizes is set.
+func (f *Fake) PutObject(bucket, key string, o *Object) {
+	f.Lock()
+	defer f.Unlock()
+
+	o = o.clone()
+	var parts [][]byte
+	data := o.Data
+	for _, n := range o.PartSizes {
+		parts = append(parts, data[:n])
+		data = data[n:]
+	}
+	if o.ETag == "" && o.PartSizes != nil {
+		o.ETag = multipartETag(parts)
+	}
+	if o.Checksum == "" && o.ChecksumAlgorithm != "" {
+		o.Checksum = checksum(o.ChecksumAlgorithm, o.Data)
+		if o.PartSizes != nil {
+			o.Checksum = compositeChecksum(o.ChecksumAlgorithm, parts)
+		}
+	}
+	if o.ETag == "" {
+		o.ETag = etag(o.Data)
+	}
+	if o.LastModified.IsZero() {
+		o.LastModified = time.Now().UTC()
+	}
+	f.store(bucket, key, o)
+}
+
+// Object returns a copy of the object at bucket/key, or nil.
+func (f *Fake) Object(bucket, key string) *Object {
+	f.Lock()
+	defer f.Unlock()
+
+	o := f.buckets[bucket][key]
+	if o == nil {
+		return nil
+	}
+	return o.clone()
+}
+
+// Keys returns the sorted keys in bucket.
+func (f *Fake) Keys(bucket string) []string {
+	f.Lock()
+	defer f.Unlock()
+
+	return f.sortedKeys(bucket)
+}
+
+// Calls returns how many times the operation op was called.
+func (f *Fake) Calls(op string) int {
+	f.Lock()
+	defer f.Unlock()
+
+	return f.calls[op]
+}
+
+// Uploads returns the number of multipart uploads in progress.
+func (f *Fake) Uploads() int {
+	f.Lock()
+	defer f.Unlock()
+
+	return len(f.uploads)
+}
+
+// AbortMultipartUploadWithContext is a fake method.
+func (f *Fake) AbortMultipartUploadWithContext(_ aws.Context, in *s3.AbortMultipartUploadInput, _ ...request.Option) (*s3.AbortMultipartUploadOutput, error) {
+	f.Lock()
+	defer f.Unlock()
+	f.calls["AbortMultipartUpload"]++
+
+	if err := f.checkAccess(in.Bucket, in.ExpectedBucketOwner, in.RequestPayer); err != nil {
+		return nil, err
+	}
+
+	if _, ok := f.uploads[aws.StringValue(in.UploadId)]; !ok {
+		return nil, fakeErr("NoSuchUpload", http.StatusNotFound)
+	}
+	delete(f.uploads, aws.StringValue(in.UploadId))
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+// CompleteMultipartUploadWithContext is a fake method.
+func (f *Fake) CompleteMultipartUploadWithContext(_ aws.Context, in *s3.CompleteMultipartUploadInput, _ ...request.Option) (*s3.CompleteMultipartUploadOutput, error) {
+	f.Lock()
+	defer f.Unlock()
+	f.calls["CompleteMultipartUpload"]++
+
+	if err := f.checkAccess(in.Bucket, in.ExpectedBucketOwner, in.RequestPayer); err != nil {
+		return nil, err
+	}
+
+	up, ok := f.uploads[aws.StringValue(in.UploadId)]
+	if !ok {
+		return nil, fakeErr("NoSuchUpload", http.StatusNotFound)
+	}
+	if in.MultipartUpload == nil || len(in.MultipartUpload.Parts) == 0 {
+		return nil, fakeErr("MalformedXML", http.StatusBadRequest)
+	}
+
+	var (
+		data  []byte
+		bufs  [][]byte
+		sizes []int64
+		last  int64
+	)
+	parts := in.MultipartUpload.Parts
+	for i, p := range parts {
+		if p == nil {
+			return nil, fakeErr("InvalidPart", http.StatusBadRequest)
+		}
+		n := aws.Int64Value(p.PartNumber)
+		if n <= last {
+			return nil, fakeErr("InvalidPartOrder", http.StatusBadRequest)
+		}
+		last = n
+
+		b, ok := up.parts[n]
+		if !ok || aws.StringValue(p.ETag) != etag(b) {
+			return nil, fakeErr("InvalidPart", http.StatusBadRequest)
+		}
+		if alg := up.obj.ChecksumAlgorithm; alg != "" && partChecksum(alg, p) != checksum(alg, b) {
+			return nil, fakeErr("InvalidPart", http.StatusBadRequest)
+		}
+		if i < len(parts)-1 && int64(len(b)) < f.MinPartSize {
+			return nil, fakeErr("EntityTooSmall", http.StatusBadRequest)
+		}
+
+		bufs = append(bufs, b)
+		sizes = append(sizes, int64(len(b)))
+		data = append(data, b...)
+	}
+
+	o := up.obj
+	o.Data = data
+	o.ETag = multipartETag(bufs)
+	o.PartSizes = sizes
+	if o.ChecksumAlgorithm != "" {
+		o.Checksum = compositeChecksum(o.ChecksumAlgorithm, bufs)
+	}
+	o.LastModified = time.Now().UTC()
+	f.store(up.bucket, up.key, o)
+	delete(f.uploads, aws.StringValue(in.UploadId))
+
+	out := &s3.CompleteMultipartUploadOutput{
+		Bucket: aws.String(up.bucket),
+		ETag:   aws.String(o.ETag),
+		Key:    aws.String(up.key),
+	}
+	out.ChecksumCRC32, out.ChecksumCRC32C, out.ChecksumSHA1, out.ChecksumSHA256 = checksumFields(o.ChecksumAlgorithm, o.Checksum)
+	return out, nil
+}
+
+// CopyObjectWithContext is a fake method.
+func (f *Fake) CopyObjectWithContext(_ aws.Context, in *s3.CopyObjectInput, _ ...request.Option) (*s3.CopyObjectOutput, error) {
+	f.Lock()
+	defer f.Unlock()
+	f.calls["CopyObject"]++
+
+	if err := f.checkAccess(in.Bucket, in.ExpectedBucketOwner, in.RequestPayer); err != nil {
+		return nil, err
+	}
+
+	sb, sk, src, err := f.copySource(in.CopySource, in.CopySourceIfMatch, in.CopySourceSSECustomerKeyMD5)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.checkAccess(&sb, in.ExpectedSourceBucketOwner, in.RequestPayer); err != nil {
+		return nil, err
+	}
+	bucket, key := aws.StringValue(in.Bucket), aws.StringValue(in.Key)
+	if f.buckets[bucket] == nil {
+		return nil, fakeErr("NoSuchBucket", http.StatusNotFound)
+	}
+
+	replaceMeta := aws.StringValue(in.MetadataDirective) == s3.MetadataDirectiveReplace
+	if sb == bucket && sk == key && !replaceMeta &&
+		in.StorageClass == nil &&
+		in.WebsiteRedirectLocation == nil &&
+		in.ServerSideEncryption == nil &&
+		in.SSECustomerKeyMD5 == nil &&
+		in.ChecksumAlgorithm == nil {
+		return nil, fakeErr("InvalidRequest", http.StatusBadRequest)
+	}
+
+	o := &Object{
+		Data:    append([]byte(nil), src.Data...),
+		ETag:    src.ETag,
+		ACL:     aws.StringValue(in.ACL),
+		Expires: in.Expires,
+		Grants:  f.grants(bucket, in.ACL, in.GrantFullControl, in.GrantRead, in.GrantReadACP, in.GrantWriteACP),
+	}
+	if src.PartSizes != nil {
+		// A copy is a single part.
+		o.ETag = etag(o.Data)
+	}
+	o.ChecksumAlgorithm = src.ChecksumAlgorithm
+	if in.ChecksumAlgorithm != nil {
+		o.ChecksumAlgorithm = *in.ChecksumAlgorithm
+	}
+	o.Checksum = checksum(o.ChecksumAlgorithm, o.Data)
+	if replaceMeta {
+		o.CacheControl = aws.StringValue(in.CacheControl)
+		o.ContentDisposition = aws.StringValue(in.ContentDisposition)
+		o.ContentEncoding = aws.StringValue(in.ContentEncoding)
+		o.ContentLanguage = aws.StringValue(in.ContentLanguage)
+		o.ContentType = aws.StringValue(in.ContentType)
+		o.Metadata = fromPtrMap(in.Metadata)
+	} else {
+		o.CacheControl = src.CacheControl
+		o.ContentDisposition = src.ContentDisposition
+		o.ContentEncoding = src.ContentEncoding
+		o.ContentLanguage = src.ContentLanguage
+		o.ContentType = src.ContentType
+		o.Expires = src.Expires
+		o.Metadata = cloneMap(src.Metadata)
+	}
+
+	if aws.StringValue(in.TaggingDirective) == s3.TaggingDirectiveReplace {
+		o.Tags, err = parseTags(aws.StringValue(in.Tagging))
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		o.Tags = cloneMap(src.Tags)
+	}
+
+	o.StorageClass = aws.StringValue(in.StorageClass)
+	o.WebsiteRedirectLocation = aws.StringValue(in.WebsiteRedirectLocation)
+	setSSE(o, in.ServerSideEncryption, in.SSEKMSKeyId, in.SSEKMSEncryptionContext, in.BucketKeyEnabled, in.SSECustomerKeyMD5)
+	if err := f.setObjectLock(bucket, o, in.ObjectLockMode, in.ObjectLockRetainUntilDate, in.ObjectLockLegalHoldStatus); err != nil {
+		return nil, err
+	}
+	o.LastModified = time.Now().UTC()
+	f.store(bucket, key, o)
+
+	r := &s3.CopyObjectResult{
+		ETag:         aws.String(o.ETag),
+		LastModified: aws.Time(o.LastModified),
+	}
+	r.ChecksumCRC32, r.ChecksumCRC32C, r.ChecksumSHA1, r.ChecksumSHA256 = checksumFields(o.ChecksumAlgorithm, o.Checksum)
+	return &s3.CopyObjectOutput{CopyObjectResult: r}, nil
+}
+
+// CreateMultipartUploadWithContext is a fake method.
+func (f *Fake) CreateMultipartUploadWithContext(_ aws.Context, in *s3.CreateMultipartUploadInput, _ ...request.Option) (*s3.CreateMultipartUploadOutput, error) {
+	f.Lock()
+	defer f.Unlock()
+	f.calls["CreateMultipartUpload"]++
+
+	if err := f.checkAccess(in.Bucket, in.ExpectedBucketOwner, in.RequestPayer); err != nil {
+		return nil, err
+	}
+
+	bucket, key := aws.StringValue(in.Bucket), aws.StringValue(in.Key)
+	if f.buckets[bucket] == nil {
+		return nil, fakeErr("NoSuchBucket", http.StatusNotFound)
+	}
+
+	tags, err := parseTags(aws.StringValue(in.Tagging))
+	if err != nil {
+		return nil, err
+	}
+
+	o := &Object{
+		ACL:                     aws.StringValue(in.ACL),
+		CacheControl:            aws.StringValue(in.CacheControl),
+		ContentDisposition:      aws.StringValue(in.ContentDisposition),
+		ContentEncoding:         aws.StringValue(in.ContentEncoding),
+		ContentLanguage:         aws.StringValue(in.ContentLanguage),
+		ContentType:             aws.StringValue(in.ContentType),
+		Expires:                 in.Expires,
+		Grants:                  f.grants(bucket, in.ACL, in.GrantFullControl, in.GrantRead, in.GrantReadACP, in.GrantWriteACP),
+		Metadata:                fromPtrMap(in.Metadata),
+		StorageClass:            aws.StringValue(in.StorageClass),
+		Tags:                    tags,
+		WebsiteRedirectLocation: aws.StringValue(in.WebsiteRedirectLocation),
+		ChecksumAlgorithm:       aws.StringValue(in.ChecksumAlgorithm),
+	}
+	setSSE(o, in.ServerSideEncryption, in.SSEKMSKeyId, in.SSEKMSEncryptionContext, in.BucketKeyEnabled, in.SSECustomerKeyMD5)
+	if err := f.setObjectLock(bucket, o, in.ObjectLockMode, in.ObjectLockRetainUntilDate, in.ObjectLockLegalHoldStatus); err != nil {
+		return nil, err
+	}
+
+	f.next++
+	id := fmt.Sprintf("upload-%d", f.next)
+	f.uploads[id] = &fakeUpload{
+		bucket: bucket,
+		key:    key,
+		obj:    o,
+		parts:  make(map[int64][]byte),
+	}
+
+	return &s3.CreateMultipartUploadOutput{
+		ChecksumAlgorithm: in.ChecksumAlgorithm,
+		Bucket:            in.Bucket,
+		Key:               in.Key,
+		UploadId:          aws.String(id),
+	}, nil
+}
+
+// DeleteObjectWithContext is a fake method.
+func (f *Fake) DeleteObjectWithContext(_ aws.Context, in *s3.DeleteObjectInput, _ ...request.Option) (*s3.DeleteObjectOutput, error) {
+	f.Lock()
+	defer f.Unlock()
+	f.calls["DeleteObject"]++
+
+	if err := f.checkAccess(in.Bucket, in.ExpectedBucketOwner, in.RequestPayer); err != nil {
+		return nil, err
+	}
+
+	objs := f.buckets[aws.StringValue(in.Bucket)]
+	if objs == nil {
+		return nil, fakeErr("NoSuchBucket", http.StatusNotFound)
+	}
+	delete(objs, aws.StringValue(in.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+// GetObjectAclWithContext is a fake method.
+func (f *Fake) GetObjectAclWithContext(_ aws.Context, in *s3.GetObjectAclInput, _ ...request.Option) (*s3.GetObjectAclOutput, error) {
+	f.Lock()
+	defer f.Unlock()
+	f.calls["GetObjectAcl"]++
+
+	if err := f.checkAccess(in.Bucket, in.ExpectedBucketOwner, in.RequestPayer); err != nil {
+		return nil, err
+	}
+
+	o, err := f.lookup(in.Bucket, in.Key)
+	if err != nil {
+		return nil, err
+	}
+	return &s3.GetObjectAclOutput{
+		Grants: append([]*s3.Grant(nil), o.Grants...),
+		Owner:  FakeOwner,
+	}, nil
+}
+
+// GetObjectWithContext is a fake method. It supports a single bytes=a-b
+// Range.
+func (f *Fake) GetObjectWithContext(_ aws.Context, in *s3.GetObjectInput, _ ...request.Option) (*s3.GetObjectOutput, error) {
+	f.Lock()
+	defer f.Unlock()
+	f.calls["GetObject"]++
+
+	if err := f.checkAccess(in.Bucket, in.ExpectedBucketOwner, in.RequestPayer); err != nil {
+		return nil, err
+	}
+
+	o, err := f.lookup(in.Bucket, in.Key)
+	if err != nil {
+		return nil, err
+	}
+	if in.IfMatch != nil && *in.IfMatch != o.ETag {
+		return nil, fakeErr("PreconditionFailed", http.StatusPreconditionFailed)
+	}
+	if o.SSECustomerKeyMD5 != aws.StringValue(in.SSECustomerKeyMD5) {
+		return nil, fakeErr("InvalidRequest", http.StatusBadRequest)
+	}
+
+	data := o.Data
+	if in.Range != nil {
+		var first, last int64
+		_, err := fmt.Sscanf(*in.Range, "bytes=%d-%d", &first, &last)
+		if err != nil || first > last || first >= int64(len(data)) {
+			return nil, fakeErr("InvalidRange", http.StatusRequestedRangeNotSatisfiable)
+		}
+		if last >= int64(len(data)) {
+			last = int64(len(data)) - 1
+		}
+		data = data[first : last+1]
+	}
+
+	return &s3.GetObjectOutput{
+		Body:          io.NopCloser(bytes.NewReader(append([]byte(nil), data...))),
+		ContentLength: aws.Int64(int64(len(data))),
+		ContentType:   optional(o.ContentType),
+		ETag:          aws.String(o.ETag),
+		LastModified:  aws.Time(o.LastModified),
+	}, nil
+}
+
+// GetObjectTaggingWithContext is a fake method.
+func (f *Fake) GetObjectTaggingWithContext(_ aws.Context, in *s3.GetObjectTaggingInput, _ ...request.Option) (*s3.GetObjectTaggingOutput, error) {
+	f.Lock()
+	defer f.Unlock()
+	f.calls["GetObjectTagging"]++
+
+	if err := f.checkAccess(in.Bucket, in.ExpectedBucketOwner, in.RequestPayer); err != nil {
+		return nil, err
+	}
+
+	o, err := f.lookup(in.Bucket, in.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(o.Tags))
+	for k := range o.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	out := &s3.GetObjectTaggingOutput{TagSet: []*s3.Tag{}}
+	for _, k := range keys {
+		out.TagSet = append(out.TagSet, &s3.Tag{Key: aws.String(k), Value: aws.String(o.Tags[k])})
+	}
+	return out, nil
+}
+
+// HeadObjectWithContext is a fake method.
+func (f *Fake) HeadObjectWithContext(_ aws.Context, in *s3.HeadObjectInput, _ ...request.Option) (*s3.HeadObjectOutput, error) {
+	f.Lock()
+	defer f.Unlock()
+	f.calls["HeadObject"]++
+
+	if err := f.checkAccess(in.Bucket, in.ExpectedBucketOwner, in.RequestPayer); err != nil {
+		return nil, err
+	}
+
+	o, err := f.lookup(in.Bucket, in.Key)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "NoSuchKey" {
+			// HEAD responses have no body so the code is just NotFound.
+			return nil, fakeErr("NotFound", http.StatusNotFound)
+		}
+		return nil, err
+	}
+	if o.SSECustomerKeyMD5 != aws.StringValue(in.SSECustomerKeyMD5) {
+		return nil, fakeErr("BadRequest", http.StatusBadRequest)
+	}
+
+	size := int64(len(o.Data))
+	if n := aws.Int64Value(in.PartNumber); n > 0 {
+		// Part 1 of an object not uploaded in parts is all of it.
+		switch {
+		case o.PartSizes != nil && n <= int64(len(o.PartSizes)):
+			size = o.PartSizes[n-1]
+		case n > 1 || o.PartSizes != nil:
+			return nil, fakeErr("RequestedRangeNotSatisfiable", http.StatusRequestedRangeNotSatisfiable)
+		}
+	}
+
+	out := &s3.HeadObjectOutput{
+		ContentLength: aws.Int64(size),
+		ETag:          aws.String(o.ETag),
+		LastModified:  aws.Time(o.LastModified),
+		Metadata:      toPtrMap(o.Metadata),
+	}
+	if o.Expires != nil {
+		out.Expires = aws.String(o.Expires.Format(http.TimeFormat))
+	}
+	if o.StorageClass != "" && o.StorageClass != s3.StorageClassStandard {
+		out.StorageClass = aws.String(o.StorageClass)
+	}
+	if o.SSECustomerKeyMD5 != "" {
+		out.SSECustomerAlgorithm = aws.String("AES256")
+	}
+	if o.BucketKeyEnabled {
+		out.BucketKeyEnabled = aws.Bool(true)
+	}
+	out.CacheControl = optional(o.CacheControl)
+	out.ContentDisposition = optional(o.ContentDisposition)
+	out.ContentEncoding = optional(o.ContentEncoding)
+	out.ContentLanguage = optional(o.ContentLanguage)
+	out.ContentType = optional(o.ContentType)
+	out.SSECustomerKeyMD5 = optional(o.SSECustomerKeyMD5)
+	out.SSEKMSKeyId = optional(o.SSEKMSKeyID)
+	out.ServerSideEncryption = optional(o.ServerSideEncryption)
+	out.WebsiteRedirectLocation = optional(o.WebsiteRedirectLocation)
+	out.ObjectLockMode = optional(o.ObjectLockMode)
+	out.ObjectLockRetainUntilDate = o.ObjectLockRetainUntilDate
+	out.ObjectLockLegalHoldStatus = optional(o.ObjectLockLegalHold)
+	if in.PartNumber != nil && o.PartSizes != nil {
+		out.PartsCount = aws.Int64(int64(len(o.PartSizes)))
+	}
+	if aws.StringValue(in.ChecksumMode) == s3.ChecksumModeEnabled && in.PartNumber == nil {
+		out.ChecksumCRC32, out.ChecksumCRC32C, out.ChecksumSHA1, out.ChecksumSHA256 = checksumFields(o.ChecksumAlgorithm, o.Checksum)
+	}
+	return out, nil
+}
+
+// GetObjectLegalHoldWithContext is a fake method.
+func (f *Fake) GetObjectLegalHoldWithContext(_ aws.Context, in *s3.GetObjectLegalHoldInput, _ ...request.Option) (*s3.GetObjectLegalHoldOutput, error) {
+	f.Lock()
+	defer f.Unlock()
+	f.calls["GetObjectLegalHold"]++
+
+	if err := f.checkAccess(in.Bucket, in.ExpectedBucketOwner, in.RequestPayer); err != nil {
+		return nil, err
+	}
+
+	o, err := f.lookup(in.Bucket, in.Key)
+	if err != nil {
+		return nil, err
+	}
+	if !f.locking[aws.StringValue(in.Bucket)] {
+		return nil, errNoObjectLock
+	}
+	if o.ObjectLockLegalHold == "" {
+		return nil, fakeErr("NoSuchObjectLockConfiguration", http.StatusNotFound)
+	}
+	return &s3.GetObjectLegalHoldOutput{
+		LegalHold: &s3.ObjectLockLegalHold{Status: aws.String(o.ObjectLockLegalHold)},
+	}, nil
+}
+
+// HeadBucketWithContext is a fake method. It returns the bucket's region, if
+// it has one.
+func (f *Fake) HeadBucketWithContext(_ aws.Context, in *s3.HeadBucketInput, _ ...request.Option) (*s3.HeadBucketOutput, error) {
+	f.Lock()
+	defer f.Unlock()
+	f.calls["HeadBucket"]++
+
+	if err := f.checkAccess(in.Bucket, in.ExpectedBucketOwner, nil); err != nil {
+		return nil, err
+	}
+
+	bucket := aws.StringValue(in.Bucket)
+	if f.buckets[bucket] == nil {
+		return nil, fakeErr("NotFound", http.StatusNotFound)
+	}
+	out := &s3.HeadBucketOutput{}
+	if r := f.regions[bucket]; r != "" {
+		out.BucketRegion = aws.String(r)
+	}
+	return out, nil
+}
+
+// GetBucketLocationWithContext is a fake method. Like S3 it returns an empty
+// LocationConstraint for us-east-1.
+func (f *Fake) GetBucketLocationWithContext(_ aws.Context, in *s3.GetBucketLocationInput, _ ...request.Option) (*s3.GetBucketLocationOutput, error) {
+	f.Lock()
+	defer f.Unlock()
+	f.calls["GetBucketLocation"]++
+
+	if err := f.checkAccess(in.Bucket, in.ExpectedBucketOwner, nil); err != nil {
+		return nil, err
+	}
+
+	bucket := aws.StringValue(in.Bucket)
+	if f.buckets[bucket] == nil {
+		return nil, fakeErr("NoSuchBucket", http.StatusNotFound)
+	}
+	out := &s3.GetBucketLocationOutput{}
+	if r := f.regions[bucket]; r != "" && r != "us-east-1" {
+		out.LocationConstraint = aws.String(r)
+	}
+	return out, nil
+}
+
+// GetObjectLockConfigurationWithContext is a fake method.
+func (f *Fake) GetObjectLockConfigurationWithContext(_ aws.Context, in *s3.GetObjectLockConfigurationInput, _ ...request.Option) (*s3.GetObjectLockConfigurationOutput, error) {
+	f.Lock()
+	defer f.Unlock()
+	f.calls["GetObjectLockConfiguration"]++
+
+	if err := f.checkAccess(in.Bucket, in.ExpectedBucketOwner, nil); err != nil {
+		return nil, err
+	}
+
+	bucket := aws.StringValue(in.Bucket)
+	if f.buckets[bucket] == nil {
+		return nil, fakeErr("NoSuchBucket", http.StatusNotFound)
+	}
+	if !f.locking[bucket] {
+		return nil, fakeErr("ObjectLockConfigurationNotFoundError", http.StatusNotFound)
+	}
+	return &s3.GetObjectLockConfigurationOutput{
+		ObjectLockConfiguration: &s3.ObjectLockConfiguration{
+			ObjectLockEnabled: aws.String(s3.ObjectLockEnabledEnabled),
+		},
+	}, nil
+}
+
+// GetObjectRetentionWithContext is a fake method.
+func (f *Fake) GetObjectRetentionWithContext(_ aws.Context, in *s3.GetObjectRetentionInput, _ ...request.Option) (*s3.GetObjectRetentionOutput, error) {
+	f.Lock()
+	defer f.Unlock()
+	f.calls["GetObjectRetention"]++
+
+	if err := f.checkAccess(in.Bucket, in.ExpectedBucketOwner, in.RequestPayer); err != nil {
+		return nil, err
+	}
+
+	o, err := f.lookup(in.Bucket, in.Key)
+	if err != nil {
+		return nil, err
+	}
+	if !f.locking[aws.StringValue(in.Bucket)] {
+		return nil, errNoObjectLock
+	}
+	if o.ObjectLockMode == "" {
+		return nil, fakeErr("NoSuchObjectLockConfiguration", http.StatusNotFound)
+	}
+	return &s3.GetObjectRetentionOutput{
+		Retention: &s3.ObjectLockRetention{
+			Mode:            aws.String(o.ObjectLockMode),
+			RetainUntilDate: o.ObjectLockRetainUntilDate,
+		},
+	}, nil
+}
+
+// ListObjectsV2PagesWithContext is a fake method. Pages hold MaxKeys keys,
+// 1000 by default.
+func (f *Fake) ListObjectsV2PagesWithContext(_ aws.Context, in *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool, _ ...request.Option) error {
+	f.Lock()
+	bucket := aws.StringValue(in.Bucket)
+	if f.buckets[bucket] == nil {
+		f.Unlock()
+		return fakeErr("NoSuchBucket", http.StatusNotFound)
+	}
+	if err := f.checkAccess(in.Bucket, in.ExpectedBucketOwner, in.RequestPayer); err != nil {
+		f.Unlock()
+		return err
+	}
+
+	prefix := aws.StringValue(in.Prefix)
+	after := aws.StringValue(in.StartAfter)
+	var objs []*s3.Object
+	for _, k := range f.sortedKeys(bucket) {
+		if !strings.HasPrefix(k, prefix) || k <= after {
+			continue
+		}
+		o := f.buckets[bucket][k]
+		sc := o.StorageClass
+		if sc == "" {
+			sc = s3.StorageClassStandard
+		}
+		objs = append(objs, &s3.Object{
+			ETag:         aws.String(o.ETag),
+			Key:          aws.String(k),
+			LastModified: aws.Time(o.LastModified),
+			Size:         aws.Int64(int64(len(o.Data))),
+			StorageClass: aws.String(sc),
+		})
+	}
+	f.Unlock()
+
+	max := int(aws.Int64Value(in.MaxKeys))
+	if max <= 0 {
+		max = 1000
+	}
+
+	for {
+		f.Lock()
+		f.calls["ListObjectsV2"]++
+		f.Unlock()
+
+		n := max
+		if n > len(objs) {
+			n = len(objs)
+		}
+		page := &s3.ListObjectsV2Output{
+			Contents:    objs[:n],
+			IsTruncated: aws.Bool(n < len(objs)),
+			KeyCount:    aws.Int64(int64(n)),
+			Name:        in.Bucket,
+			Prefix:      in.Prefix,
+		}
+		objs = objs[n:]
+		last := len(objs) == 0
+		if !fn(page, last) || last {
+			return nil
+		}
+	}
+}
+
+// PutObjectAclWithContext is a fake method.
+func (f *Fake) PutObjectAclWithContext(_ aws.Context, in *s3.PutObjectAclInput, _ ...request.Option) (*s3.PutObjectAclOutput, error) {
+	f.Lock()
+	defer f.Unlock()
+	f.calls["PutObjectAcl"]++
+
+	if err := f.checkAccess(in.Bucket, in.ExpectedBucketOwner, in.RequestPayer); err != nil {
+		return nil, err
+	}
+
+	o, err := f.lookup(in.Bucket, in.Key)
+	if err != nil {
+		return nil, err
+	}
+	if in.AccessControlPolicy != nil {
+		o.ACL = ""
+		o.Grants = append([]*s3.Grant(nil), in.AccessControlPolicy.Grants...)
+	} else {
+		o.ACL = aws.StringValue(in.ACL)
+		o.Grants = []*s3.Grant{ownerGrant()}
+	}
+	return &s3.PutObjectAclOutput{}, nil
+}
+
+// UploadPartWithContext is a fake method.
+func (f *Fake) UploadPartWithContext(_ aws.Context, in *s3.UploadPartInput, _ ...request.Option) (*s3.UploadPartOutput, error) {
+	var data []byte
+	if in.Body != nil {
+		b, err := io.ReadAll(in.Body)
+		if err != nil {
+			return nil, err
+		}
+		data = b
+	}
+
+	f.Lock()
+	defer f.Unlock()
+	f.calls["UploadPart"]++
+
+	if err := f.checkAccess(in.Bucket, in.ExpectedBucketOwner, in.RequestPayer); err != nil {
+		return nil, err
+	}
+
+	up, ok := f.uploads[aws.StringValue(in.UploadId)]
+	if !ok {
+		return nil, fakeErr("NoSuchUpload", http.StatusNotFound)
+	}
+	if in.ContentLength != nil && *in.ContentLength != int64(len(data)) {
+		return nil, fakeErr("IncompleteBody", http.StatusBadRequest)
+	}
+	alg := up.obj.ChecksumAlgorithm
+	if in.ChecksumAlgorithm != nil && *in.ChecksumAlgorithm != alg {
+		return nil, fakeErr("InvalidRequest", http.StatusBadRequest)
+	}
+
+	up.parts[aws.Int64Value(in.PartNumber)] = data
+	out := &s3.UploadPartOutput{ETag: aws.String(etag(data))}
+	out.ChecksumCRC32, out.ChecksumCRC32C, out.ChecksumSHA1, out.ChecksumSHA256 = checksumFields(alg, checksum(alg, data))
+	return out, nil
+}
+
+// UploadPartCopyWithContext is a fake method.
+func (f *Fake) UploadPartCopyWithContext(_ aws.Context, in *s3.UploadPartCopyInput, _ ...request.Option) (*s3.UploadPartCopyOutput, error) {
+	f.Lock()
+	defer f.Unlock()
+	f.calls["UploadPartCopy"]++
+
+	if err := f.checkAccess(in.Bucket, in.ExpectedBucketOwner, in.RequestPayer); err != nil {
+		return nil, err
+	}
+
+	up, ok := f.uploads[aws.StringValue(in.UploadId)]
+	if !ok {
+		return nil, fakeErr("NoSuchUpload", http.StatusNotFound)
+	}
+
+	sb, _, src, err := f.copySource(in.CopySource, in.CopySourceIfMatch, in.CopySourceSSECustomerKeyMD5)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.checkAccess(&sb, in.ExpectedSourceBucketOwner, in.RequestPayer); err != nil {
+		return nil, err
+	}
+
+	data := src.Data
+	if in.CopySourceRange != nil {
+		var first, last int64
+		_, err := fmt.Sscanf(*in.CopySourceRange, "bytes=%d-%d", &first, &last)
+		if err != nil || first > last || last >= int64(len(data)) {
+			return nil, fakeErr("InvalidRange", http.StatusRequestedRangeNotSatisfiable)
+		}
+		data = data[first : last+1]
+	}
+
+	up.parts[aws.Int64Value(in.PartNumber)] = append([]byte(nil), data...)
+	r := &s3.CopyPartResult{
+		ETag:         aws.String(etag(data)),
+		LastModified: aws.Time(time.Now().UTC()),
+	}
+	alg := up.obj.ChecksumAlgorithm
+	r.ChecksumCRC32, r.ChecksumCRC32C, r.ChecksumSHA1, r.ChecksumSHA256 = checksumFields(alg, checksum(alg, data))
+	return &s3.UploadPartCopyOutput{CopyPartResult: r}, nil
+}
+
+// copySource looks up the object named by a CopySource header and checks
+// the copy source conditions. The header is decoded strictly, a + is a space
+// as in a query string, so only a properly encoded key finds its object.
+func (f *Fake) copySource(cs, ifMatch, sseMD5 *string) (string, string, *Object, error) {
+	path := strings.TrimPrefix(aws.StringValue(cs), "/")
+	if i := strings.Index(path, "?"); i >= 0 {
+		if !strings.HasPrefix(path[i:], "?versionId=") {
+			return "", "", nil, fakeErr("InvalidArgument", http.StatusBadRequest)
+		}
+		path = path[:i]
+	}
+	path, err := url.QueryUnescape(path)
+	if err != nil {
+		return "", "", nil, fakeErr("InvalidArgument", http.StatusBadRequest)
+	}
+	parts := strings.SplitN(path, "/", 2)
+	if len(parts) != 2 {
+		return "", "", nil, fakeErr("InvalidArgument", http.StatusBadRequest)
+	}
+
+	o, err := f.lookup(&parts[0], &parts[1])
+	if err != nil {
+		return "", "", nil, err
+	}
+	if ifMatch != nil && *ifMatch != o.ETag {
+		return "", "", nil, fakeErr("PreconditionFailed", http.StatusPreconditionFailed)
+	}
+	if o.SSECustomerKeyMD5 != aws.StringValue(sseMD5) {
+		return "", "", nil, fakeErr("InvalidRequest", http.StatusBadRequest)
+	}
+	return parts[0], parts[1], o, nil
+}
+
+func (f *Fake) lookup(bucket, key *string) (*Object, error) {
+	objs := f.buckets[aws.StringValue(bucket)]
+	if objs == nil {
+		return nil, fakeErr("NoSuchBucket", http.StatusNotFound)
+	}
+	o := objs[aws.StringValue(key)]
+	if o == nil {
+		return nil, fakeErr("NoSuchKey", http.StatusNotFound)
+	}
+	return o, nil
+}
+
+func (f *Fake) store(bucket, key string, o *Object) {
+	if f.buckets[bucket] == nil {
+		f.buckets[bucket] = make(map[string]*Object)
+	}
+	if o.StorageClass == "" {
+		o.StorageClass = s3.StorageClassStandard
+	}
+	if o.Grants == nil {
+		o.Grants = []*s3.Grant{ownerGrant()}
+	}
+	f.buckets[bucket][key] = o
+}
+
+func (f *Fake) sortedKeys(bucket string) []string {
+	keys := make([]string, 0, len(f.buckets[bucket]))
+	for k := range f.buckets[bucket] {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// checkAccess returns AccessDenied if bucket is requester pays and payer
+// doesn't confirm it, if expected is set and isn't the owner of bucket, or if
+// it isn't set and RequireExpectedOwner is.
+func (f *Fake) checkAccess(bucket, expected, payer *string) error {
+	if f.requesterPays[aws.StringValue(bucket)] && aws.StringValue(payer) != s3.RequestPayerRequester {
+		return fakeErr("AccessDenied", http.StatusForbidden)
+	}
+	if expected == nil {
+		if f.RequireExpectedOwner {
+			return fakeErr("AccessDenied", http.StatusForbidden)
+		}
+		return nil
+	}
+	owner := f.owners[aws.StringValue(bucket)]
+	if owner == "" {
+		owner = FakeAccountID
+	}
+	if *expected != owner {
+		return fakeErr("AccessDenied", http.StatusForbidden)
+	}
+	return nil
+}
+
+// grants returns the grants of an object written to bucket with the canned
+// acl or grant headers. Without grant headers the writer has full control;
+// with them it has only what they grant.
+func (f *Fake) grants(bucket string, acl, full, read, readACP, writeACP *string) []*s3.Grant {
+	var grants []*s3.Grant
+	if full == nil && read == nil && readACP == nil && writeACP == nil {
+		grants = append(grants, ownerGrant())
+	}
+	if aws.StringValue(acl) == s3.ObjectCannedACLBucketOwnerFullControl {
+		owner := f.owners[bucket]
+		if owner == "" {
+			owner = FakeAccountID
+		}
+		grants = append(grants, &s3.Grant{
+			Grantee:    &s3.Grantee{ID: aws.String(owner), Type: aws.String(s3.TypeCanonicalUser)},
+			Permission: aws.String(s3.PermissionFullControl),
+		})
+	}
+
+	for _, h := range []struct {
+		header     *string
+		permission string
+	}{
+		{full, s3.PermissionFullControl},
+		{read, s3.PermissionRead},
+		{readACP, s3.PermissionReadAcp},
+		{writeACP, s3.PermissionWriteAcp},
+	} {
+		if h.header == nil {
+			continue
+		}
+		for _, g := range strings.Split(*h.header, ",") {
+			kv := strings.SplitN(strings.TrimSpace(g), "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			grantee := &s3.Grantee{}
+			v := strings.Trim(kv[1], `"`)
+			switch kv[0] {
+			case "id":
+				grantee.ID, grantee.Type = aws.String(v), aws.String(s3.TypeCanonicalUser)
+			case "uri":
+				grantee.URI, grantee.Type = aws.String(v), aws.String(s3.TypeGroup)
+			case "emailAddress":
+				grantee.EmailAddress, grantee.Type = aws.String(v), aws.String(s3.TypeAmazonCustomerByEmail)
+			}
+			grants = append(grants, &s3.Grant{Grantee: grantee, Permission: aws.String(h.permission)})
+		}
+	}
+	return grants
+}
+
+// setObjectLock sets the lock settings on o, which is being written to
+// bucket. S3 refuses them if the bucket doesn't have Object Lock enabled.
+func (f *Fake) setObjectLock(bucket string, o *Object, mode *string, until *time.Time, hold *string) error {
+	if (mode != nil || until != nil || hold != nil) && !f.locking[bucket] {
+		return fakeErr("InvalidRequest", http.StatusBadRequest)
+	}
+	if (mode == nil) != (until == nil) {
+		return fakeErr("InvalidArgument", http.StatusBadRequest)
+	}
+	o.ObjectLockMode = aws.StringValue(mode)
+	o.ObjectLockRetainUntilDate = until
+	o.ObjectLockLegalHold = aws.StringValue(hold)
+	return nil
+}
+
+func setSSE(o *Object, sse, kmsKey, kmsContext *string, bucketKey *bool, customerMD5 *string) {
+	o.ServerSideEncryption = aws.StringValue(sse)
+	o.SSEKMSKeyID = aws.StringValue(kmsKey)
+	o.SSEKMSEncryptionContext = aws.StringValue(kmsContext)
+	o.BucketKeyEnabled = aws.BoolValue(bucketKey)
+	o.SSECustomerKeyMD5 = aws.StringValue(customerMD5)
+}
+
+func ownerGrant() *s3.Grant {
+	return &s3.Grant{
+		Grantee: &s3.Grantee{
+			ID:   FakeOwner.ID,
+			Type: aws.String(s3.TypeCanonicalUser),
+		},
+		Permission: aws.String(s3.PermissionFullControl),
+	}
+}
+
+func parseTags(tagging string) (map[string]string, error) {
+	vals, err := url.ParseQuery(tagging)
+	if err != nil {
+		return nil, fakeErr("InvalidArgument", http.StatusBadRequest)
+	}
+	tags := make(map[string]string, len(vals))
+	for k, v := range vals {
+		tags[k] = v[0]
+	}
+	return tags, nil
+}
+
+func etag(b []byte) string {
+	sum := md5.Sum(b)
+	return strconv.Quote(hex.EncodeToString(sum[:]))
+}
+
+// multipartETag is the ETag of an object uploaded in parts: the MD5 of the
+// parts' MD5s and the part count.
+func multipartETag(parts [][]byte) string {
+	var sums []byte
+	for _, b := range parts {
+		sum := md5.Sum(b)
+		sums = append(sums, sum[:]...)
+	}
+	sum := md5.Sum(sums)
+	return fmt.Sprintf(`"%s-%d"`, hex.EncodeToString(sum[:]), len(parts))
+}
+
+// errNoObjectLock is S3's error reading an object's lock settings in a bucket
+// without Object Lock.
+var errNoObjectLock = awserr.NewRequestFailure(awserr.New("InvalidRequest", "Bucket is missing Object Lock Configuration", nil), http.StatusBadRequest, "fake-request-id")
+
+func fakeErr(code string, status int) error {
+	return awserr.NewRequestFailure(awserr.New(code, code, nil), status, "fake-request-id")
+}
+
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return aws.String(s)
+}
+
+func cloneMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+func fromPtrMap(m map[string]*string) map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[strings.ToLower(k)] = aws.StringValue(v)
+	}
+	return c
+}
+
+func toPtrMap(m map[string]string) map[string]*string {
+	if len(m) == 0 {
+		return nil
+	}
+	c := make(map[string]*string, len(m))
+	for k, v := range m {
+		c[k] = aws.String(v)
+	}
+	return c
+}
@@ -1,8 +1,10 @@
 package dummy
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"sync"
 )
 
 // NewLogOutput returns a constructed SafeLogOuput and sets log output to it.
@@ -33,3 +35,54 @@ func (s *SafeLogOutput) Reset() {
 	log.SetOutput(os.Stderr)
 	close(s.out)
 }
+
+// NewLogger returns a Logger that records every entry.
+func NewLogger() *Logger {
+	return &Logger{}
+}
+
+// Entry is a recorded log entry.
+type Entry struct {
+	Level string
+	Msg   string
+	Attrs map[string]interface{}
+}
+
+// Logger is a goroutine safe structured logger that records entries for
+// inspection.
+type Logger struct {
+	sync.Mutex
+	entries []Entry
+}
+
+// Debug records a DEBUG entry.
+func (l *Logger) Debug(msg string, args ...interface{}) { l.record("DEBUG", msg, args) }
+
+// Info records an INFO entry.
+func (l *Logger) Info(msg string, args ...interface{}) { l.record("INFO", msg, args) }
+
+// Warn records a WARN entry.
+func (l *Logger) Warn(msg string, args ...interface{}) { l.record("WARN", msg, args) }
+
+// Error records an ERROR entry.
+func (l *Logger) Error(msg string, args ...interface{}) { l.record("ERROR", msg, args) }
+
+// Entries returns the entries recorded so far.
+func (l *Logger) Entries() []Entry {
+	l.Lock()
+	defer l.Unlock()
+
+	return append([]Entry(nil), l.entries...)
+}
+
+func (l *Logger) record(level, msg string, args []interface{}) {
+	e := Entry{Level: level, Msg: msg, Attrs: make(map[string]interface{})}
+	for i := 0; i+1 < len(args); i += 2 {
+		e.Attrs[fmt.Sprint(args[i])] = args[i+1]
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	l.entries = append(l.entries, e)
+}
@@ -0,0 +1,76 @@
+package dummy
+
+import (
+	"sync"
+	"time"
+)
+
+// NewMetrics returns a Metrics that records every measurement.
+func NewMetrics() *Metrics {
+	return &Metrics{Calls: make(map[string]int)}
+}
+
+// Metrics is a goroutine safe s3cp.Metrics that records measurements for
+// inspection. Lock it before reading the fields while a copy is running.
+type Metrics struct {
+	sync.Mutex
+
+	BytesCopied    int64
+	PartsSucceeded int64
+	PartsFailed    int64
+	PartsRetried   int64
+	PartsInFlight  int64
+	UploadsAborted int64
+
+	// Calls counts the observed calls by operation.
+	Calls map[string]int
+}
+
+// AddBytesCopied records n bytes copied.
+func (m *Metrics) AddBytesCopied(n int64) {
+	m.Lock()
+	defer m.Unlock()
+	m.BytesCopied += n
+}
+
+// IncPartsSucceeded records a successful part.
+func (m *Metrics) IncPartsSucceeded() {
+	m.Lock()
+	defer m.Unlock()
+	m.PartsSucceeded++
+}
+
+// IncPartsFailed records a failed part.
+func (m *Metrics) IncPartsFailed() {
+	m.Lock()
+	defer m.Unlock()
+	m.PartsFailed++
+}
+
+// IncPartsRetried records a part retry.
+func (m *Metrics) IncPartsRetried() {
+	m.Lock()
+	defer m.Unlock()
+	m.PartsRetried++
+}
+
+// AddPartsInFlight adjusts the in flight part count.
+func (m *Metrics) AddPartsInFlight(delta int64) {
+	m.Lock()
+	defer m.Unlock()
+	m.PartsInFlight += delta
+}
+
+// IncUploadsAborted records an aborted upload.
+func (m *Metrics) IncUploadsAborted() {
+	m.Lock()
+	defer m.Unlock()
+	m.UploadsAborted++
+}
+
+// ObserveCall records a call to op.
+func (m *Metrics) ObserveCall(op string, _ time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+	m.Calls[op]++
+}
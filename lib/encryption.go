@@ -0,0 +1,126 @@
+package s3cp
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Server side encryption types for Encryption.Type.
+const (
+	// SSES3 encrypts with S3 managed keys.
+	SSES3 = s3.ServerSideEncryptionAes256
+
+	// SSEKMS encrypts with a KMS key.
+	SSEKMS = s3.ServerSideEncryptionAwsKms
+
+	// SSEC encrypts with a customer provided key.
+	SSEC = "SSE-C"
+)
+
+// sseCustomerAlgorithm is the only algorithm S3 supports for SSE-C.
+const sseCustomerAlgorithm = "AES256"
+
+// Encryption describes the server side encryption of one side of a copy.
+type Encryption struct {
+	// Type is SSES3, SSEKMS or SSEC. If empty the bucket's default
+	// encryption applies.
+	Type string
+
+	// KMSKeyID is the SSE-KMS key ID or ARN. If empty the AWS managed key
+	// is used.
+	KMSKeyID string
+
+	// KMSContext is the SSE-KMS encryption context.
+	KMSContext map[string]string
+
+	// BucketKey enables an S3 Bucket Key for SSE-KMS.
+	BucketKey bool
+
+	// CustomerKey is the 256 bit SSE-C key. Its MD5 is computed for you.
+	CustomerKey []byte
+}
+
+// Validate checks the settings are consistent with the Type.
+func (e *Encryption) Validate() error {
+	if e.Type != SSEKMS && (e.KMSKeyID != "" || len(e.KMSContext) > 0 || e.BucketKey) {
+		return fmt.Errorf("KMS settings require encryption type %s, got %q", SSEKMS, e.Type)
+	}
+	if e.Type != SSEC && len(e.CustomerKey) > 0 {
+		return fmt.Errorf("a customer key requires encryption type %s, got %q", SSEC, e.Type)
+	}
+
+	switch e.Type {
+	case "", SSES3, SSEKMS:
+	case SSEC:
+		if len(e.CustomerKey) != 32 {
+			return fmt.Errorf("SSE-C key must be 32 bytes, got %d", len(e.CustomerKey))
+		}
+	default:
+		return fmt.Errorf("unknown encryption type %q", e.Type)
+	}
+
+	return nil
+}
+
+// customerKey returns the SSE-C algorithm, key and key MD5 headers.
+func (e *Encryption) customerKey() (alg, key, md5sum *string) {
+	sum := md5.Sum(e.CustomerKey)
+	return aws.String(sseCustomerAlgorithm),
+		aws.String(string(e.CustomerKey)),
+		aws.String(base64.StdEncoding.EncodeToString(sum[:]))
+}
+
+// applyEncryption validates the CopyInput's Encryption and SourceEncryption
+// and sets the corresponding fields of the CopyObjectInput, which the
+// multipart calls are built from.
+func (c *copier) applyEncryption() error {
+	if src := c.in.SourceEncryption; src != nil {
+		if err := src.Validate(); err != nil {
+			return fmt.Errorf("source encryption: %s", err)
+		}
+		if src.Type == SSEC {
+			c.in.COI.CopySourceSSECustomerAlgorithm,
+				c.in.COI.CopySourceSSECustomerKey,
+				c.in.COI.CopySourceSSECustomerKeyMD5 = src.customerKey()
+		}
+	}
+
+	dst := c.in.Encryption
+	if dst == nil {
+		return nil
+	}
+	if err := dst.Validate(); err != nil {
+		return fmt.Errorf("destination encryption: %s", err)
+	}
+
+	switch dst.Type {
+	case SSES3:
+		c.in.COI.ServerSideEncryption = aws.String(SSES3)
+	case SSEKMS:
+		c.in.COI.ServerSideEncryption = aws.String(SSEKMS)
+		if dst.KMSKeyID != "" {
+			c.in.COI.SSEKMSKeyId = aws.String(dst.KMSKeyID)
+		}
+		if len(dst.KMSContext) > 0 {
+			ctx, err := json.Marshal(dst.KMSContext)
+			if err != nil {
+				return err
+			}
+			c.in.COI.SSEKMSEncryptionContext = aws.String(base64.StdEncoding.EncodeToString(ctx))
+		}
+		if dst.BucketKey {
+			c.in.COI.BucketKeyEnabled = aws.Bool(true)
+		}
+	case SSEC:
+		c.in.COI.SSECustomerAlgorithm,
+			c.in.COI.SSECustomerKey,
+			c.in.COI.SSECustomerKeyMD5 = dst.customerKey()
+	}
+
+	return nil
+}
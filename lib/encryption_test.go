@@ -0,0 +1,175 @@
+package s3cp_test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/reedobrien/checkers"
+	s3cp "github.com/reedobrien/s3cp/lib"
+	"github.com/reedobrien/s3cp/lib/dummy"
+)
+
+var (
+	srcKey = bytes.Repeat([]byte("s"), 32)
+	dstKey = bytes.Repeat([]byte("d"), 32)
+)
+
+func keyMD5(k []byte) string {
+	sum := md5.Sum(k)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func TestEncryptionValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		enc  s3cp.Encryption
+		err  string
+	}{
+		{name: "none"},
+		{name: "sse-s3", enc: s3cp.Encryption{Type: s3cp.SSES3}},
+		{name: "sse-kms", enc: s3cp.Encryption{
+			Type:       s3cp.SSEKMS,
+			KMSKeyID:   "key",
+			KMSContext: map[string]string{"a": "b"},
+			BucketKey:  true,
+		}},
+		{name: "sse-c", enc: s3cp.Encryption{Type: s3cp.SSEC, CustomerKey: dstKey}},
+		{
+			name: "kms settings without kms",
+			enc:  s3cp.Encryption{Type: s3cp.SSES3, KMSKeyID: "key"},
+			err:  `KMS settings require encryption type aws:kms, got "AES256"`,
+		},
+		{
+			name: "customer key without sse-c",
+			enc:  s3cp.Encryption{CustomerKey: dstKey},
+			err:  `a customer key requires encryption type SSE-C, got ""`,
+		},
+		{
+			name: "short customer key",
+			enc:  s3cp.Encryption{Type: s3cp.SSEC, CustomerKey: []byte("short")},
+			err:  "SSE-C key must be 32 bytes, got 5",
+		},
+		{
+			name: "unknown type",
+			enc:  s3cp.Encryption{Type: "rot13"},
+			err:  `unknown encryption type "rot13"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.enc.Validate()
+			if tc.err == "" {
+				checkers.OK(t, err)
+				return
+			}
+			checkers.Assert(t, err != nil, "expected error %q", tc.err)
+			checkers.Equals(t, err.Error(), tc.err)
+		})
+	}
+}
+
+func TestCopyEncryptionMultipart(t *testing.T) {
+	api := dummy.NewS3API("", func(d *dummy.S3API) {
+		d.Hoo = &s3.HeadObjectOutput{ContentLength: aws.Int64(20)}
+		d.Cmp = &s3.CreateMultipartUploadOutput{UploadId: aws.String("an-id")}
+		d.Upc = &s3.UploadPartCopyOutput{
+			CopyPartResult: &s3.CopyPartResult{ETag: aws.String("someetag")},
+		}
+	})
+	in := s3cp.CopyInput{
+		Encryption: &s3cp.Encryption{
+			Type:       s3cp.SSEKMS,
+			KMSKeyID:   "arn:aws:kms:us-east-1:123456789012:key/abc",
+			KMSContext: map[string]string{"team": "data"},
+			BucketKey:  true,
+		},
+		SourceEncryption: &s3cp.Encryption{Type: s3cp.SSEC, CustomerKey: srcKey},
+		COI: s3.CopyObjectInput{
+			Bucket:     aws.String("abucket"),
+			CopySource: aws.String("bucket/key"),
+			Key:        aws.String("akey"),
+		},
+	}
+
+	tut := s3cp.NewCopier(api, func(c *s3cp.Copier) { c.PartSize = 10 })
+
+	err := tut.Copy(in)
+	checkers.OK(t, err)
+
+	checkers.Equals(t, *api.HooInput.SSECustomerAlgorithm, "AES256")
+	checkers.Equals(t, *api.HooInput.SSECustomerKey, string(srcKey))
+	checkers.Equals(t, *api.HooInput.SSECustomerKeyMD5, keyMD5(srcKey))
+
+	cmp := api.CmpInput
+	checkers.Equals(t, *cmp.ServerSideEncryption, "aws:kms")
+	checkers.Equals(t, *cmp.SSEKMSKeyId, "arn:aws:kms:us-east-1:123456789012:key/abc")
+	checkers.Equals(t, *cmp.SSEKMSEncryptionContext,
+		base64.StdEncoding.EncodeToString([]byte(`{"team":"data"}`)))
+	checkers.Equals(t, *cmp.BucketKeyEnabled, true)
+	checkers.Assert(t, cmp.SSECustomerAlgorithm == nil,
+		"source SSE-C algorithm leaked to destination: %q", aws.StringValue(cmp.SSECustomerAlgorithm))
+
+	checkers.Equals(t, len(api.UpcInputs), 2)
+	for _, upc := range api.UpcInputs {
+		checkers.Equals(t, *upc.CopySourceSSECustomerAlgorithm, "AES256")
+		checkers.Equals(t, *upc.CopySourceSSECustomerKey, string(srcKey))
+		checkers.Equals(t, *upc.CopySourceSSECustomerKeyMD5, keyMD5(srcKey))
+		checkers.Assert(t, upc.SSECustomerKey == nil, "unexpected destination SSE-C key")
+	}
+}
+
+func TestCopyEncryptionSSEC(t *testing.T) {
+	api := dummy.NewS3API("", func(d *dummy.S3API) {
+		d.Coo = &s3.CopyObjectOutput{}
+		d.Cmp = &s3.CreateMultipartUploadOutput{UploadId: aws.String("an-id")}
+		d.Upc = &s3.UploadPartCopyOutput{
+			CopyPartResult: &s3.CopyPartResult{ETag: aws.String("someetag")},
+		}
+	})
+	in := s3cp.CopyInput{
+		Encryption: &s3cp.Encryption{Type: s3cp.SSEC, CustomerKey: dstKey},
+		COI: s3.CopyObjectInput{
+			Bucket:     aws.String("abucket"),
+			CopySource: aws.String("bucket/key"),
+			Key:        aws.String("akey"),
+		},
+	}
+
+	// Single part.
+	in.Size = 5
+	tut := s3cp.NewCopier(api, func(c *s3cp.Copier) { c.PartSize = 10 })
+	checkers.OK(t, tut.Copy(in))
+	checkers.Equals(t, *api.CooInput.SSECustomerAlgorithm, "AES256")
+	checkers.Equals(t, *api.CooInput.SSECustomerKey, string(dstKey))
+	checkers.Equals(t, *api.CooInput.SSECustomerKeyMD5, keyMD5(dstKey))
+	checkers.Assert(t, api.CooInput.CopySourceSSECustomerKey == nil, "unexpected source SSE-C key")
+
+	// Multipart.
+	in.Size = 15
+	checkers.OK(t, tut.Copy(in))
+	checkers.Equals(t, *api.CmpInput.SSECustomerAlgorithm, "AES256")
+	checkers.Equals(t, *api.CmpInput.SSECustomerKeyMD5, keyMD5(dstKey))
+	for _, upc := range api.UpcInputs {
+		checkers.Equals(t, *upc.SSECustomerKey, string(dstKey))
+		checkers.Equals(t, *upc.SSECustomerKeyMD5, keyMD5(dstKey))
+	}
+}
+
+func TestCopyEncryptionInvalid(t *testing.T) {
+	api := dummy.NewS3API("")
+	in := s3cp.CopyInput{
+		Size:       5,
+		Encryption: &s3cp.Encryption{Type: s3cp.SSEC},
+		COI: s3.CopyObjectInput{
+			CopySource: aws.String("bucket/key"),
+		},
+	}
+
+	err := s3cp.NewCopier(api).Copy(in)
+	checkers.Equals(t, err.Error(), "destination encryption: SSE-C key must be 32 bytes, got 0")
+	checkers.Assert(t, api.CooInput == nil, "copy should not be attempted")
+}
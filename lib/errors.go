@@ -0,0 +1,98 @@
+package s3cp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// PartError describes a single part of a multipart copy that failed or was
+// cancelled.
+type PartError struct {
+	// The part number in the multipart upload.
+	PartNumber int64
+
+	// The CopySourceRange of the part, e.g. bytes=0-524287999.
+	Range string
+
+	// The code of the last awserr.Error returned for the part, if any.
+	Code string
+
+	// How many times UploadPartCopy was called for the part. It is 0 if the
+	// part was never started.
+	Attempts int
+
+	// Cancelled is set if the part was stopped or never started because
+	// the copy was cancelled, by another part failing, its context or its
+	// timeout, rather than failing itself.
+	Cancelled bool
+
+	// The last error returned for the part, or the context's error if it
+	// was never started.
+	Err error
+}
+
+func (p PartError) Error() string {
+	if p.Cancelled && p.Attempts == 0 {
+		return fmt.Sprintf("part %d (%s) was not started: %s", p.PartNumber, p.Range, p.Err)
+	}
+	return fmt.Sprintf("part %d (%s) failed after %d attempt(s): %s",
+		p.PartNumber, p.Range, p.Attempts, p.Err)
+}
+
+// MultipartCopyError is returned when parts of a multipart copy fail. It
+// records every part that didn't complete, those that failed and those that
+// were cancelled or never started, so callers can retry just those ranges or
+// report exactly which part of the object broke.
+type MultipartCopyError struct {
+	// The multipart upload ID. The upload is aborted unless
+	// Copier.LeavePartsOnError is set.
+	UploadID string
+
+	// The first error, which caused the copy to be cancelled.
+	Err error
+
+	// The parts that didn't complete, ordered by part number. Those
+	// stopped by the first error are marked Cancelled.
+	Parts []PartError
+}
+
+// Error lists the parts that failed, not those cancelled.
+func (m *MultipartCopyError) Error() string {
+	var nums []string
+	for _, p := range m.Parts {
+		if !p.Cancelled {
+			nums = append(nums, fmt.Sprint(p.PartNumber))
+		}
+	}
+	if len(nums) == 0 {
+		return fmt.Sprintf("multipart copy %s was cancelled: %s", m.UploadID, m.Err)
+	}
+	return fmt.Sprintf("multipart copy %s failed for part(s) %s: %s",
+		m.UploadID, strings.Join(nums, ", "), m.Err)
+}
+
+// Unwrap returns the first error.
+func (m *MultipartCopyError) Unwrap() error {
+	return m.Err
+}
+
+// cancelled reports whether err is from a call stopped by its context.
+func cancelled(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var aerr awserr.Error
+	return errors.As(err, &aerr) && aerr.Code() == request.CanceledErrorCode
+}
+
+// PreconditionFailed reports whether err is S3's PreconditionFailed, e.g.
+// because the source no longer has the ETag of CopySourceIfMatch.
+func PreconditionFailed(err error) bool {
+	var aerr awserr.Error
+	return errors.As(err, &aerr) && aerr.Code() == "PreconditionFailed"
+}
@@ -0,0 +1,205 @@
+package s3cp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Event is an S3 event notification message.
+type Event struct {
+	Records []EventRecord `json:"Records"`
+}
+
+// EventRecord is a record of an S3 event notification. Only the fields a
+// copy needs are decoded.
+type EventRecord struct {
+	EventName string    `json:"eventName"`
+	EventTime time.Time `json:"eventTime"`
+	S3        EventS3   `json:"s3"`
+}
+
+// EventS3 is the bucket and object of an EventRecord.
+type EventS3 struct {
+	Bucket EventBucket `json:"bucket"`
+	Object EventObject `json:"object"`
+}
+
+// EventBucket is the bucket of an EventRecord.
+type EventBucket struct {
+	Name string `json:"name"`
+}
+
+// EventObject is the object of an EventRecord. Its Key is URL encoded.
+type EventObject struct {
+	Key       string `json:"key"`
+	Size      int64  `json:"size"`
+	ETag      string `json:"eTag"`
+	VersionID string `json:"versionId"`
+	Sequencer string `json:"sequencer"`
+}
+
+// IfMatch returns o's ETag quoted, as CopySourceIfMatch takes it, or nil if
+// the record has none. Event notifications leave the quotes off.
+func (o EventObject) IfMatch() *string {
+	switch {
+	case o.ETag == "":
+		return nil
+	case strings.HasPrefix(o.ETag, `"`):
+		return &o.ETag
+	}
+	etag := `"` + o.ETag + `"`
+	return &etag
+}
+
+// Created reports whether r is for a created object, e.g. by a put or copy.
+func (r EventRecord) Created() bool {
+	return strings.HasPrefix(r.EventName, "ObjectCreated:")
+}
+
+// Location returns the object r is for, with its key decoded.
+func (r EventRecord) Location() (Location, error) {
+	key, err := url.QueryUnescape(r.S3.Object.Key)
+	if err != nil {
+		return Location{}, fmt.Errorf("invalid key %q: %s", r.S3.Object.Key, err)
+	}
+	if r.S3.Bucket.Name == "" || key == "" {
+		return Location{}, errors.New("event record has no bucket or key")
+	}
+	return Location{Bucket: r.S3.Bucket.Name, Key: key, VersionID: r.S3.Object.VersionID}, nil
+}
+
+// ParseEvent returns the records of the event notification message data. It
+// may be an S3 event notification, one delivered by SNS, to a Lambda function
+// or over HTTP, or an EventBridge event from S3. A test event, which S3 sends
+// when notifications are set up, has none.
+func ParseEvent(data []byte) ([]EventRecord, error) {
+	var e eventEnvelope
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, fmt.Errorf("invalid event: %s", err)
+	}
+
+	switch {
+	case e.Type == "SubscriptionConfirmation":
+		return nil, fmt.Errorf("SNS subscription to %s must be confirmed at %s", e.TopicArn, e.SubscribeURL)
+	case e.Type == "Notification":
+		return ParseEvent([]byte(e.Message))
+	case e.Source == "aws.s3":
+		return e.eventBridgeRecords(), nil
+	}
+
+	var records []EventRecord
+	for _, r := range e.Records {
+		if r.Sns == nil {
+			records = append(records, r.EventRecord)
+			continue
+		}
+		inner, err := ParseEvent([]byte(r.Sns.Message))
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, inner...)
+	}
+	return records, nil
+}
+
+// eventEnvelope decodes any of the forms of event ParseEvent takes.
+type eventEnvelope struct {
+	// Records of an S3 event notification, or of SNS messages each holding
+	// one.
+	Records []struct {
+		EventRecord
+		Sns *struct {
+			Message string `json:"Message"`
+		} `json:"Sns"`
+	} `json:"Records"`
+
+	// An SNS message delivered over HTTP.
+	Type         string `json:"Type"`
+	Message      string `json:"Message"`
+	TopicArn     string `json:"TopicArn"`
+	SubscribeURL string `json:"SubscribeURL"`
+
+	// An EventBridge event.
+	Source     string            `json:"source"`
+	DetailType string            `json:"detail-type"`
+	Time       time.Time         `json:"time"`
+	Detail     eventBridgeDetail `json:"detail"`
+}
+
+// eventBridgeDetail is the detail of an EventBridge event from S3.
+type eventBridgeDetail struct {
+	Bucket EventBucket `json:"bucket"`
+	Object struct {
+		Key       string `json:"key"`
+		Size      int64  `json:"size"`
+		ETag      string `json:"etag"`
+		VersionID string `json:"version-id"`
+		Sequencer string `json:"sequencer"`
+	} `json:"object"`
+	Reason string `json:"reason"`
+}
+
+// eventBridgeRecords returns the EventBridge event e as a record named as
+// an S3 event notification's would be, e.g. ObjectCreated:PutObject.
+func (e eventEnvelope) eventBridgeRecords() []EventRecord {
+	name := e.DetailType
+	switch e.DetailType {
+	case "Object Created":
+		name = "ObjectCreated:" + e.Detail.Reason
+	case "Object Deleted":
+		name = "ObjectRemoved:" + e.Detail.Reason
+	}
+	o := e.Detail.Object
+	return []EventRecord{{
+		EventName: name,
+		EventTime: e.Time,
+		S3: EventS3{
+			Bucket: e.Detail.Bucket,
+			Object: EventObject{Key: o.Key, Size: o.Size, ETag: o.ETag, VersionID: o.VersionID, Sequencer: o.Sequencer},
+		},
+	}}
+}
+
+// ReadEvents calls fn with the records of each event notification message
+// in r, which holds JSON messages one after another, e.g. one per line. It
+// returns nil at the end of r, or the first error decoding or from fn.
+func ReadEvents(r io.Reader, fn func([]EventRecord) error) error {
+	dec := json.NewDecoder(r)
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("invalid event: %s", err)
+		}
+		records, err := ParseEvent(raw)
+		if err != nil {
+			return err
+		}
+		if err := fn(records); err != nil {
+			return err
+		}
+	}
+}
+
+// sequencerAfter reports whether the event sequencer a is later than b, or
+// b is empty. Sequencers are hex strings compared after padding the shorter
+// with zeros on the right.
+func sequencerAfter(a, b string) bool {
+	if b == "" {
+		return true
+	}
+	for len(a) < len(b) {
+		a += "0"
+	}
+	for len(b) < len(a) {
+		b += "0"
+	}
+	return strings.ToUpper(a) > strings.ToUpper(b)
+}
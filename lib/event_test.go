@@ -0,0 +1,65 @@
+package s3cp_test
+
+import (
+	"testing"
+
+	"github.com/reedobrien/checkers"
+	s3cp "github.com/reedobrien/s3cp/lib"
+)
+
+func TestParseEvent(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		event   string
+		records int
+		created bool
+		loc     s3cp.Location
+		err     string
+	}{
+		{
+			name:    "s3",
+			event:   `{"Records":[{"eventName":"ObjectCreated:Put","s3":{"bucket":{"name":"src"},"object":{"key":"a%2Fb+c","versionId":"v1"}}}]}`,
+			records: 1,
+			created: true,
+			loc:     s3cp.Location{Bucket: "src", Key: "a/b c", VersionID: "v1"},
+		},
+		{
+			name:    "sns",
+			event:   `{"Records":[{"EventSource":"aws:sns","Sns":{"Message":"{\"Records\":[{\"eventName\":\"ObjectCreated:Copy\",\"s3\":{\"bucket\":{\"name\":\"src\"},\"object\":{\"key\":\"k\"}}}]}"}}]}`,
+			records: 1,
+			created: true,
+			loc:     s3cp.Location{Bucket: "src", Key: "k"},
+		},
+		{
+			name:    "eventbridge deleted",
+			event:   `{"source":"aws.s3","detail-type":"Object Deleted","detail":{"bucket":{"name":"src"},"object":{"key":"k"},"reason":"DeleteObject"}}`,
+			records: 1,
+			loc:     s3cp.Location{Bucket: "src", Key: "k"},
+		},
+		{
+			name:  "subscription",
+			event: `{"Type":"SubscriptionConfirmation","TopicArn":"arn:aws:sns:us-east-1:123456789012:events","SubscribeURL":"https://sns.example.com/confirm"}`,
+			err:   "SNS subscription to arn:aws:sns:us-east-1:123456789012:events must be confirmed at https://sns.example.com/confirm",
+		},
+		{
+			name:  "invalid",
+			event: `[]`,
+			err:   "invalid event: json: cannot unmarshal array into Go value of type s3cp.eventEnvelope",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			records, err := s3cp.ParseEvent([]byte(tc.event))
+			if tc.err != "" {
+				checkers.Assert(t, err != nil, "expected an error")
+				checkers.Equals(t, err.Error(), tc.err)
+				return
+			}
+			checkers.OK(t, err)
+			checkers.Equals(t, len(records), tc.records)
+			checkers.Equals(t, records[0].Created(), tc.created)
+			loc, err := records[0].Location()
+			checkers.OK(t, err)
+			checkers.Equals(t, loc, tc.loc)
+		})
+	}
+}
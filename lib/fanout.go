@@ -0,0 +1,149 @@
+package s3cp
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// FanOutInput is a parameter container for Copier.FanOut.
+type FanOutInput struct {
+	// Source is the object to copy.
+	Source Location
+
+	// Destinations are where it is copied to, e.g. buckets in other
+	// regions. A destination without a key gets the source's key.
+	Destinations []Location
+
+	// Template is the copy made in each destination's region, e.g. with
+	// Encryption set; the COI's Bucket and Key come from each Destination.
+	// With Delete set the source is deleted once every destination has it,
+	// and kept if any copy fails.
+	Template CopyInput
+}
+
+// DestinationResult is the outcome of a fan out's copy to one destination.
+type DestinationResult struct {
+	Destination Location
+	Err         error
+}
+
+// FanOut copies the source to every destination at once. The source is
+// HEADed once, and every copy must match its ETag. The copies share the
+// Copier's Concurrency, so no more parts than that are copied at a time
+// however many destinations there are. A result is returned for each
+// destination, in order, even if some fail, with an error.
+func (c Copier) FanOut(ctx aws.Context, in FanOutInput) ([]DestinationResult, error) {
+	if err := in.validate(); err != nil {
+		return nil, err
+	}
+
+	reader, err := c.sourceLister(ctx, in.Source, in.Template)
+	if err != nil {
+		return nil, err
+	}
+	impl := copier{in: in.Template, cfg: reader, ctx: ctx}
+	impl.cfg.SrcS3 = reader.S3
+	impl.in.COI.CopySource = aws.String(in.Source.CopySource())
+	impl.applyRequestPayer()
+	if err := impl.applyEncryption(); err != nil {
+		return nil, err
+	}
+	if err := impl.applyChecksum(); err != nil {
+		return nil, err
+	}
+	info, err := impl.headSource(reader.S3, in.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	ci := in.Template
+	ci.Delete = false
+	ci.COI.CopySource = aws.String(in.Source.CopySource())
+	if ci.COI.CopySourceIfMatch == nil {
+		ci.COI.CopySourceIfMatch = info.ETag
+	}
+
+	concurrency := c.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultCopyConcurrency
+	}
+	slots := make(chan struct{}, concurrency)
+	prepare := func(impl *copier) {
+		impl.sourceInfo = info
+		impl.slots = slots
+	}
+
+	results := make([]DestinationResult, len(in.Destinations))
+	var wg sync.WaitGroup
+	for i, dst := range in.Destinations {
+		if dst.Key == "" {
+			dst.Key = in.Source.Key
+		}
+		results[i].Destination = dst
+
+		dci := ci
+		dci.COI.Bucket = aws.String(dst.Bucket)
+		dci.COI.Key = aws.String(dst.Key)
+		wg.Add(1)
+		go func(r *DestinationResult) {
+			defer wg.Done()
+			r.Err = c.copyWith(ctx, dci, prepare)
+		}(&results[i])
+	}
+	wg.Wait()
+
+	var failed int
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		if in.Template.Delete {
+			return results, fmt.Errorf("%d of %d destinations failed, kept the source", failed, len(results))
+		}
+		return results, fmt.Errorf("%d of %d destinations failed", failed, len(results))
+	}
+
+	if in.Template.Delete {
+		if err := impl.deleteObject(); err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+func (in *FanOutInput) validate() error {
+	if in.Source.Bucket == "" || in.Source.Key == "" {
+		return errors.New("fan out requires a source bucket and key")
+	}
+	if len(in.Destinations) == 0 {
+		return errors.New("fan out requires at least one destination")
+	}
+	seen := make(map[Location]bool)
+	for _, dst := range in.Destinations {
+		if dst.Bucket == "" {
+			return errors.New("fan out requires a bucket for every destination")
+		}
+		if dst.VersionID != "" {
+			return fmt.Errorf("destination %s can't have a version ID", dst)
+		}
+		if dst.Key == "" {
+			dst.Key = in.Source.Key
+		}
+		if seen[dst] {
+			return fmt.Errorf("destination %s is given twice", dst)
+		}
+		seen[dst] = true
+		if in.Template.Delete && dst.Bucket == in.Source.Bucket && dst.Key == in.Source.Key {
+			return fmt.Errorf("destination %s is the source, which move deletes", dst)
+		}
+	}
+	if in.Template.SourceRange != nil && in.Template.Delete {
+		return errors.New("can't delete the source of a range copy")
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package s3cp_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/reedobrien/checkers"
+	s3cp "github.com/reedobrien/s3cp/lib"
+	"github.com/reedobrien/s3cp/lib/dummy"
+)
+
+// newFanOutFake returns a fake with src/big holding data in us-east-1 and a
+// bucket in each of four other regions.
+func newFanOutFake(data []byte) (*dummy.Fake, []s3cp.Location) {
+	fake := dummy.NewFakeWith(dummy.Buckets{"src": {"big": {Data: data}}})
+	fake.SetBucketRegion("src", "us-east-1")
+
+	var dsts []s3cp.Location
+	for _, region := range []string{"us-west-2", "eu-west-1", "ap-southeast-2", "sa-east-1"} {
+		fake.SetBucketRegion("dr-"+region, region)
+		dsts = append(dsts, s3cp.Location{Bucket: "dr-" + region})
+	}
+	return fake, dsts
+}
+
+func fanOutCopier(api s3cp.API, opts ...func(*s3cp.Copier)) *s3cp.Copier {
+	opts = append([]func(*s3cp.Copier){
+		func(c *s3cp.Copier) { c.PartSize = 10 },
+		func(c *s3cp.Copier) { c.MustSvcForRegion = func(*string) s3cp.API { return api } },
+	}, opts...)
+	return s3cp.NewCopier(api, opts...)
+}
+
+func TestFanOut(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 10)
+	fake, dsts := newFanOutFake(data)
+	dsts[1].Key = "copies/big"
+	tut := fanOutCopier(fake)
+
+	results, err := tut.FanOut(context.Background(), s3cp.FanOutInput{
+		Source:       s3cp.Location{Bucket: "src", Key: "big"},
+		Destinations: dsts,
+		Template:     s3cp.CopyInput{Delete: true},
+	})
+	checkers.OK(t, err)
+	checkers.Equals(t, len(results), 4)
+	for i, r := range results {
+		checkers.OK(t, r.Err)
+		checkers.Equals(t, r.Destination.Bucket, dsts[i].Bucket)
+		checkers.Equals(t, fake.Object(r.Destination.Bucket, r.Destination.Key).Data, data)
+	}
+	checkers.Equals(t, results[0].Destination.Key, "big")
+	checkers.Equals(t, results[1].Destination.Key, "copies/big")
+
+	checkers.Equals(t, fake.Calls("HeadObject"), 1)
+	checkers.Equals(t, fake.Calls("UploadPartCopy"), 40)
+	checkers.Equals(t, fake.Calls("DeleteObject"), 1)
+	checkers.Equals(t, fake.Keys("src"), []string{})
+}
+
+func TestFanOutFailureKeepsSource(t *testing.T) {
+	fake, dsts := newFanOutFake(bytes.Repeat([]byte("0123456789"), 10))
+	dsts = append(dsts, s3cp.Location{Bucket: "missing"})
+	tut := fanOutCopier(fake)
+
+	results, err := tut.FanOut(context.Background(), s3cp.FanOutInput{
+		Source:       s3cp.Location{Bucket: "src", Key: "big"},
+		Destinations: dsts,
+		Template:     s3cp.CopyInput{Delete: true},
+	})
+	checkers.Assert(t, err != nil, "expected an error")
+	checkers.Equals(t, err.Error(), "1 of 5 destinations failed, kept the source")
+	checkers.Equals(t, len(results), 5)
+	for _, r := range results[:4] {
+		checkers.OK(t, r.Err)
+	}
+	checkers.Assert(t, results[4].Err != nil, "expected the missing bucket to fail")
+	checkers.Equals(t, fake.Calls("DeleteObject"), 0)
+	checkers.Equals(t, fake.Keys("src"), []string{"big"})
+}
+
+func TestFanOutSharesConcurrency(t *testing.T) {
+	fake, dsts := newFanOutFake(bytes.Repeat([]byte("0123456789"), 10))
+	api := &concurrencyAPI{Fake: fake}
+	tut := fanOutCopier(api, func(c *s3cp.Copier) { c.Concurrency = 3 })
+
+	_, err := tut.FanOut(context.Background(), s3cp.FanOutInput{
+		Source:       s3cp.Location{Bucket: "src", Key: "big"},
+		Destinations: dsts,
+	})
+	checkers.OK(t, err)
+	checkers.Equals(t, fake.Calls("UploadPartCopy"), 40)
+	checkers.Assert(t, api.max <= 3, "expected at most 3 parts at once, got %d", api.max)
+	checkers.Assert(t, api.max > 1, "expected parts to be copied at once, got %d", api.max)
+}
+
+func TestFanOutErrors(t *testing.T) {
+	src := s3cp.Location{Bucket: "src", Key: "big"}
+	for _, tc := range []struct {
+		name string
+		in   s3cp.FanOutInput
+		err  string
+	}{
+		{
+			name: "no source",
+			in:   s3cp.FanOutInput{Destinations: []s3cp.Location{{Bucket: "dst"}}},
+			err:  "fan out requires a source bucket and key",
+		},
+		{
+			name: "no destinations",
+			in:   s3cp.FanOutInput{Source: src},
+			err:  "fan out requires at least one destination",
+		},
+		{
+			name: "no bucket",
+			in:   s3cp.FanOutInput{Source: src, Destinations: []s3cp.Location{{Bucket: "dst"}, {Key: "k"}}},
+			err:  "fan out requires a bucket for every destination",
+		},
+		{
+			name: "twice",
+			in:   s3cp.FanOutInput{Source: src, Destinations: []s3cp.Location{{Bucket: "dst"}, {Bucket: "dst", Key: "big"}}},
+			err:  "destination s3://dst/big is given twice",
+		},
+		{
+			name: "move onto source",
+			in:   s3cp.FanOutInput{Source: src, Destinations: []s3cp.Location{{Bucket: "dst"}, {Bucket: "src"}}, Template: s3cp.CopyInput{Delete: true}},
+			err:  "destination s3://src/big is the source, which move deletes",
+		},
+		{
+			name: "missing source",
+			in:   s3cp.FanOutInput{Source: s3cp.Location{Bucket: "src", Key: "nope"}, Destinations: []s3cp.Location{{Bucket: "dst"}}},
+			err:  "error getting object info: NotFound: NotFound",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := newSplitFake([]byte("data"))
+			_, err := s3cp.NewCopier(fake).FanOut(context.Background(), tc.in)
+			checkers.Assert(t, err != nil, "expected an error")
+			checkers.Equals(t, strings.SplitN(err.Error(), "\n", 2)[0], tc.err)
+			checkers.Equals(t, fake.Keys("dst"), []string{})
+		})
+	}
+}
+
+// concurrencyAPI records the most part copies in flight at once.
+type concurrencyAPI struct {
+	*dummy.Fake
+
+	mu       sync.Mutex
+	inFlight int
+	max      int
+}
+
+func (a *concurrencyAPI) UploadPartCopyWithContext(ctx aws.Context, in *s3.UploadPartCopyInput, opts ...request.Option) (*s3.UploadPartCopyOutput, error) {
+	a.mu.Lock()
+	a.inFlight++
+	if a.inFlight > a.max {
+		a.max = a.inFlight
+	}
+	a.mu.Unlock()
+
+	time.Sleep(time.Millisecond)
+	defer func() {
+		a.mu.Lock()
+		a.inFlight--
+		a.mu.Unlock()
+	}()
+	return a.Fake.UploadPartCopyWithContext(ctx, in, opts...)
+}
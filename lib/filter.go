@@ -0,0 +1,240 @@
+package s3cp
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Filter selects the objects a bulk operation works on. Everything but Tags
+// is checked against the listing, before any other request is made for the
+// object.
+//
+// An object is selected if it matches one of the include rules, or there
+// are none, matches no exclude rule and passes every other check.
+type Filter struct {
+	// Include and Exclude are glob patterns matched against the key with
+	// the prefix removed. * matches within a path segment, ** across
+	// segments, ? a single character other than / and [...] a character
+	// class, negated with [!...]. A pattern without a / matches the key's
+	// base name, as in rsync.
+	Include []string
+	Exclude []string
+
+	// IncludeRegexp and ExcludeRegexp are regular expressions matched
+	// against the key with the prefix removed.
+	IncludeRegexp []string
+	ExcludeRegexp []string
+
+	// MinSize and MaxSize bound the object's size in bytes. A zero MaxSize
+	// is no bound.
+	MinSize int64
+	MaxSize int64
+
+	// ModifiedAfter and ModifiedBefore, if not zero, bound the object's
+	// LastModified.
+	ModifiedAfter  time.Time
+	ModifiedBefore time.Time
+
+	// StorageClasses, if not empty, are the storage classes selected.
+	StorageClasses []string
+
+	// Tags, if not empty, must all be set on the object with these values.
+	// They aren't listed, so checking them costs a GetObjectTagging for
+	// every object passing the other checks.
+	Tags map[string]string
+}
+
+// Validate checks the patterns compile and the bounds make sense.
+func (f *Filter) Validate() error {
+	_, err := f.compile()
+	return err
+}
+
+// filter is a compiled Filter. A nil filter selects everything.
+type filter struct {
+	*Filter
+	include, exclude []*regexp.Regexp
+}
+
+func (f *Filter) compile() (*filter, error) {
+	if f == nil {
+		return nil, nil
+	}
+
+	if f.MinSize < 0 || f.MaxSize < 0 {
+		return nil, errors.New("filter sizes can't be negative")
+	}
+	if f.MaxSize > 0 && f.MinSize > f.MaxSize {
+		return nil, fmt.Errorf("filter min size %d is over max size %d", f.MinSize, f.MaxSize)
+	}
+	if !f.ModifiedAfter.IsZero() && !f.ModifiedBefore.IsZero() && !f.ModifiedAfter.Before(f.ModifiedBefore) {
+		return nil, fmt.Errorf("filter modified after %s is not before %s",
+			f.ModifiedAfter.Format(time.RFC3339), f.ModifiedBefore.Format(time.RFC3339))
+	}
+
+	c := &filter{Filter: f}
+	for _, r := range []struct {
+		name     string
+		patterns []string
+		compile  func(string) (*regexp.Regexp, error)
+		into     *[]*regexp.Regexp
+	}{
+		{"include", f.Include, globRegexp, &c.include},
+		{"exclude", f.Exclude, globRegexp, &c.exclude},
+		{"include regexp", f.IncludeRegexp, regexp.Compile, &c.include},
+		{"exclude regexp", f.ExcludeRegexp, regexp.Compile, &c.exclude},
+	} {
+		for _, p := range r.patterns {
+			re, err := r.compile(p)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q: %s", r.name, p, err)
+			}
+			*r.into = append(*r.into, re)
+		}
+	}
+	return c, nil
+}
+
+// listed reports whether the listed object o under prefix passes every
+// check but the tags.
+func (f *filter) listed(prefix string, o *s3.Object) bool {
+	if f == nil {
+		return true
+	}
+
+	rel := strings.TrimPrefix(aws.StringValue(o.Key), prefix)
+	if len(f.include) > 0 && !matchAny(f.include, rel) {
+		return false
+	}
+	if matchAny(f.exclude, rel) {
+		return false
+	}
+
+	size := aws.Int64Value(o.Size)
+	if size < f.MinSize || (f.MaxSize > 0 && size > f.MaxSize) {
+		return false
+	}
+
+	modified := aws.TimeValue(o.LastModified)
+	if !f.ModifiedAfter.IsZero() && !modified.After(f.ModifiedAfter) {
+		return false
+	}
+	if !f.ModifiedBefore.IsZero() && !modified.Before(f.ModifiedBefore) {
+		return false
+	}
+
+	if len(f.StorageClasses) > 0 {
+		class := aws.StringValue(o.StorageClass)
+		if class == "" {
+			class = s3.StorageClassStandard
+		}
+		for _, sc := range f.StorageClasses {
+			if strings.EqualFold(sc, class) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// needsTags reports whether the filter checks tags.
+func (f *filter) needsTags() bool {
+	return f != nil && len(f.Tags) > 0
+}
+
+// tagged reports whether tags has every tag the filter requires.
+func (f *filter) tagged(tags []*s3.Tag) bool {
+	if !f.needsTags() {
+		return true
+	}
+
+	have := make(map[string]string, len(tags))
+	for _, t := range tags {
+		have[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+	}
+	for k, v := range f.Tags {
+		if got, ok := have[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+func matchAny(res []*regexp.Regexp, s string) bool {
+	for _, re := range res {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// globRegexp compiles the glob pattern described on Filter.Include.
+func globRegexp(glob string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	if !strings.Contains(glob, "/") {
+		b.WriteString("(?:.*/)?")
+	}
+
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				b.WriteString(".*")
+				i++
+				continue
+			}
+			b.WriteString("[^/]*")
+		case '?':
+			b.WriteString("[^/]")
+		case '\\':
+			if i+1 == len(glob) {
+				return nil, errors.New("trailing \\")
+			}
+			i++
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		case '[':
+			j := i + 1
+			negated := j < len(glob) && glob[j] == '!'
+			if negated {
+				j++
+			}
+			// A ] straight after the [ is part of the class.
+			end := -1
+			if j < len(glob) {
+				end = strings.IndexByte(glob[j+1:], ']')
+			}
+			if end < 0 {
+				return nil, errors.New("unterminated [")
+			}
+			end += j + 1
+
+			b.WriteString("[")
+			if negated {
+				b.WriteString("^/")
+			}
+			for _, r := range glob[j:end] {
+				if r == '-' {
+					b.WriteRune(r)
+					continue
+				}
+				b.WriteString(regexp.QuoteMeta(string(r)))
+			}
+			b.WriteString("]")
+			i = end
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
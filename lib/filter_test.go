@@ -0,0 +1,315 @@
+package s3cp_test
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/reedobrien/checkers"
+	s3cp "github.com/reedobrien/s3cp/lib"
+	"github.com/reedobrien/s3cp/lib/dummy"
+)
+
+var day = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+// filterBuckets holds keys under src/data/ that differ in every attribute a
+// Filter can match.
+var filterBuckets = dummy.Buckets{
+	"src": {
+		"data/a.csv":          {Data: []byte("0123456789"), LastModified: day},
+		"data/b.json":         {Data: []byte("01234"), LastModified: day.Add(24 * time.Hour)},
+		"data/2024/c.csv":     {Data: []byte("01"), LastModified: day.Add(48 * time.Hour), Tags: map[string]string{"team": "ops"}},
+		"data/2024/tmp/d.csv": {Data: []byte("0123456789012345"), LastModified: day, StorageClass: s3.StorageClassGlacierIr},
+		"data/[x].csv":        {Data: []byte("x"), LastModified: day, Tags: map[string]string{"team": "ops", "tier": "cold"}},
+		"other/e.csv":         {Data: []byte("e"), LastModified: day},
+	},
+	"dst": nil,
+}
+
+func TestCopyPrefixFilter(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		filter *s3cp.Filter
+		want   []string
+	}{
+		{
+			name: "none",
+			want: []string{"[x].csv", "2024/c.csv", "2024/tmp/d.csv", "a.csv", "b.json"},
+		},
+		{
+			name:   "base name glob",
+			filter: &s3cp.Filter{Include: []string{"*.csv"}},
+			want:   []string{"[x].csv", "2024/c.csv", "2024/tmp/d.csv", "a.csv"},
+		},
+		{
+			name:   "path glob",
+			filter: &s3cp.Filter{Include: []string{"2024/*"}},
+			want:   []string{"2024/c.csv"},
+		},
+		{
+			name:   "double star",
+			filter: &s3cp.Filter{Include: []string{"2024/**"}, Exclude: []string{"tmp/*"}},
+			want:   []string{"2024/c.csv", "2024/tmp/d.csv"},
+		},
+		{
+			name:   "exclude directory",
+			filter: &s3cp.Filter{Exclude: []string{"**/tmp/**", "?.json"}},
+			want:   []string{"[x].csv", "2024/c.csv", "a.csv"},
+		},
+		{
+			name:   "class",
+			filter: &s3cp.Filter{Include: []string{"[ab].*", `\[x\].csv`}},
+			want:   []string{"[x].csv", "a.csv", "b.json"},
+		},
+		{
+			name:   "negated class",
+			filter: &s3cp.Filter{Include: []string{"[!a]*"}},
+			want:   []string{"[x].csv", "2024/c.csv", "2024/tmp/d.csv", "b.json"},
+		},
+		{
+			name:   "regexp",
+			filter: &s3cp.Filter{IncludeRegexp: []string{`^\d{4}/`}, ExcludeRegexp: []string{`/tmp/`}},
+			want:   []string{"2024/c.csv"},
+		},
+		{
+			name:   "size",
+			filter: &s3cp.Filter{MinSize: 2, MaxSize: 10},
+			want:   []string{"2024/c.csv", "a.csv", "b.json"},
+		},
+		{
+			name:   "modified",
+			filter: &s3cp.Filter{ModifiedAfter: day, ModifiedBefore: day.Add(72 * time.Hour)},
+			want:   []string{"2024/c.csv", "b.json"},
+		},
+		{
+			name:   "storage class",
+			filter: &s3cp.Filter{StorageClasses: []string{"glacier_ir"}},
+			want:   []string{"2024/tmp/d.csv"},
+		},
+		{
+			name:   "tags",
+			filter: &s3cp.Filter{Tags: map[string]string{"team": "ops"}},
+			want:   []string{"[x].csv", "2024/c.csv"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := dummy.NewFakeWith(filterBuckets)
+			tut := s3cp.NewCopier(fake)
+
+			sum, err := tut.CopyPrefix(context.Background(), s3cp.CopyPrefixInput{
+				Source:      s3cp.Location{Bucket: "src", Key: "data/"},
+				Destination: s3cp.Location{Bucket: "dst", Key: "copy/"},
+				Filter:      tc.filter,
+			}, nil)
+			checkers.OK(t, err)
+
+			var got []string
+			for _, k := range fake.Keys("dst") {
+				got = append(got, k[len("copy/"):])
+			}
+			sort.Strings(tc.want)
+			checkers.Equals(t, got, tc.want)
+			checkers.Equals(t, sum, s3cp.Summary{Copied: len(tc.want), Filtered: 5 - len(tc.want)})
+		})
+	}
+}
+
+func TestCopyPrefixFilterCalls(t *testing.T) {
+	fake := dummy.NewFakeWith(filterBuckets)
+	tut := s3cp.NewCopier(fake)
+
+	var (
+		mu      sync.Mutex
+		results []s3cp.ObjectResult
+	)
+	sum, err := tut.CopyPrefix(context.Background(), s3cp.CopyPrefixInput{
+		Source:      s3cp.Location{Bucket: "src", Key: "data/"},
+		Destination: s3cp.Location{Bucket: "dst"},
+		Filter: &s3cp.Filter{
+			Include: []string{"*.csv"},
+			MaxSize: 10,
+			Tags:    map[string]string{"team": "ops"},
+		},
+	}, func(r s3cp.ObjectResult) {
+		mu.Lock()
+		results = append(results, r)
+		mu.Unlock()
+	})
+	checkers.OK(t, err)
+	checkers.Equals(t, sum, s3cp.Summary{Copied: 2, Filtered: 3})
+	checkers.Equals(t, fake.Keys("dst"), []string{"2024/c.csv", "[x].csv"})
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Key < results[j].Key })
+	checkers.Equals(t, results, []s3cp.ObjectResult{
+		{Key: "data/2024/c.csv"},
+		{Key: "data/2024/tmp/d.csv", Filtered: true},
+		{Key: "data/[x].csv"},
+		{Key: "data/a.csv", Filtered: true},
+		{Key: "data/b.json", Filtered: true},
+	})
+
+	// The listing's sizes are used and only the listed candidates' tags
+	// fetched.
+	checkers.Equals(t, fake.Calls("HeadObject"), 0)
+	checkers.Equals(t, fake.Calls("GetObjectTagging"), 3)
+}
+
+func TestReencryptFilter(t *testing.T) {
+	fake := dummy.NewFakeWith(reencryptBuckets)
+	tut := s3cp.NewCopier(fake, func(c *s3cp.Copier) { c.PartSize = 10 })
+
+	sum, err := tut.Reencrypt(context.Background(), s3cp.ReencryptInput{
+		Bucket:     "bucket",
+		Prefix:     "logs/",
+		Filter:     &s3cp.Filter{Tags: map[string]string{"tier": "cold"}, Exclude: []string{"done"}},
+		Encryption: s3cp.Encryption{Type: s3cp.SSEKMS, KMSKeyID: "new-key"},
+	}, nil)
+	checkers.OK(t, err)
+	checkers.Equals(t, sum, s3cp.Summary{Copied: 1, Filtered: 2})
+	checkers.Equals(t, fake.Object("bucket", "logs/large").SSEKMSKeyID, "new-key")
+	checkers.Equals(t, fake.Object("bucket", "logs/small").ServerSideEncryption, "")
+}
+
+func TestCopyPrefixErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		in   s3cp.CopyPrefixInput
+		err  string
+	}{
+		{
+			name: "no bucket",
+			in:   s3cp.CopyPrefixInput{Source: s3cp.Location{Bucket: "src"}},
+			err:  "a prefix copy requires source and destination buckets",
+		},
+		{
+			name: "version",
+			in: s3cp.CopyPrefixInput{
+				Source:      s3cp.Location{Bucket: "src", Key: "key", VersionID: "v1"},
+				Destination: s3cp.Location{Bucket: "dst"},
+			},
+			err: "a prefix copy can't have a version ID",
+		},
+		{
+			name: "under source",
+			in: s3cp.CopyPrefixInput{
+				Source:      s3cp.Location{Bucket: "src", Key: "data/"},
+				Destination: s3cp.Location{Bucket: "src", Key: "data/copy/"},
+			},
+			err: "destination s3://src/data/copy/ is under source s3://src/data/",
+		},
+		{
+			name: "same prefix",
+			in: s3cp.CopyPrefixInput{
+				Source:      s3cp.Location{Bucket: "src", Key: "data/"},
+				Destination: s3cp.Location{Bucket: "src", Key: "data/"},
+				Mapper:      &s3cp.KeyMapper{Lower: true},
+			},
+			err: "destination s3://src/data/ is under source s3://src/data/",
+		},
+		{
+			name: "glob",
+			in: s3cp.CopyPrefixInput{
+				Source:      s3cp.Location{Bucket: "src"},
+				Destination: s3cp.Location{Bucket: "dst"},
+				Filter:      &s3cp.Filter{Include: []string{"[a"}},
+			},
+			err: `invalid include "[a": unterminated [`,
+		},
+		{
+			name: "regexp",
+			in: s3cp.CopyPrefixInput{
+				Source:      s3cp.Location{Bucket: "src"},
+				Destination: s3cp.Location{Bucket: "dst"},
+				Filter:      &s3cp.Filter{ExcludeRegexp: []string{"(a"}},
+			},
+			err: "invalid exclude regexp \"(a\": error parsing regexp: missing closing ): `(a`",
+		},
+		{
+			name: "sizes",
+			in: s3cp.CopyPrefixInput{
+				Source:      s3cp.Location{Bucket: "src"},
+				Destination: s3cp.Location{Bucket: "dst"},
+				Filter:      &s3cp.Filter{MinSize: 10, MaxSize: 5},
+			},
+			err: "filter min size 10 is over max size 5",
+		},
+		{
+			name: "times",
+			in: s3cp.CopyPrefixInput{
+				Source:      s3cp.Location{Bucket: "src"},
+				Destination: s3cp.Location{Bucket: "dst"},
+				Filter:      &s3cp.Filter{ModifiedAfter: day, ModifiedBefore: day},
+			},
+			err: "filter modified after 2024-03-01T00:00:00Z is not before 2024-03-01T00:00:00Z",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := dummy.NewFakeWith(filterBuckets)
+			tut := s3cp.NewCopier(fake)
+
+			_, err := tut.CopyPrefix(context.Background(), tc.in, nil)
+			checkers.Assert(t, err != nil, "expected an error")
+			checkers.Equals(t, err.Error(), tc.err)
+			checkers.Equals(t, fake.Calls("ListObjectsV2"), 0)
+		})
+	}
+}
+
+func TestCopyPrefixTemplate(t *testing.T) {
+	fake := dummy.NewFakeWith(filterBuckets)
+	tut := s3cp.NewCopier(fake, func(c *s3cp.Copier) { c.PartSize = 5 })
+
+	sum, err := tut.CopyPrefix(context.Background(), s3cp.CopyPrefixInput{
+		Source:      s3cp.Location{Bucket: "src", Key: "data/2024/"},
+		Destination: s3cp.Location{Bucket: "dst", Key: "archive/"},
+		Template: s3cp.CopyInput{
+			Delete:     true,
+			Encryption: &s3cp.Encryption{Type: s3cp.SSES3},
+			COI:        s3.CopyObjectInput{StorageClass: aws.String(s3.StorageClassStandardIa)},
+		},
+	}, nil)
+	checkers.OK(t, err)
+	checkers.Equals(t, sum, s3cp.Summary{Copied: 2})
+	checkers.Equals(t, fake.Keys("dst"), []string{"archive/c.csv", "archive/tmp/d.csv"})
+	for _, k := range fake.Keys("dst") {
+		o := fake.Object("dst", k)
+		checkers.Equals(t, o.ServerSideEncryption, s3cp.SSES3)
+		checkers.Equals(t, o.StorageClass, s3.StorageClassStandardIa)
+	}
+	checkers.Equals(t, fake.Object("dst", "archive/tmp/d.csv").Data, []byte("0123456789012345"))
+	checkers.Assert(t, fake.Object("src", "data/2024/c.csv") == nil, "expected the source to be moved")
+}
+
+// replacingLister replaces each listed object with a longer one before the
+// listing is returned, as a put racing a prefix copy would.
+type replacingLister struct {
+	*dummy.Fake
+}
+
+func (a replacingLister) ListObjectsV2PagesWithContext(ctx aws.Context, in *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool, opts ...request.Option) error {
+	return a.Fake.ListObjectsV2PagesWithContext(ctx, in, func(page *s3.ListObjectsV2Output, last bool) bool {
+		for _, o := range page.Contents {
+			a.Fake.PutObject(*in.Bucket, *o.Key, &dummy.Object{Data: []byte(*o.Key + " replaced")})
+		}
+		return fn(page, last)
+	}, opts...)
+}
+
+func TestCopyPrefixReplaced(t *testing.T) {
+	fake := dummy.NewFakeWith(dummy.Buckets{"src": {"in/a": {Data: []byte("0123456789")}}, "dst": nil})
+	tut := s3cp.NewCopier(replacingLister{fake}, func(c *s3cp.Copier) { c.PartSize = 5 })
+
+	sum, err := tut.CopyPrefix(context.Background(), s3cp.CopyPrefixInput{
+		Source:      s3cp.Location{Bucket: "src", Key: "in/"},
+		Destination: s3cp.Location{Bucket: "dst", Key: "out/"},
+	}, nil)
+	checkers.Assert(t, err != nil, "expected an error")
+	checkers.Equals(t, sum, s3cp.Summary{Failed: 1})
+	checkers.Assert(t, fake.Object("dst", "out/a") == nil, "expected no short copy")
+}
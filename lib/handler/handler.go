@@ -0,0 +1,248 @@
+// Package handler copies the objects created in S3 event notifications to
+// the destinations of rules, e.g. as an AWS Lambda function's handler:
+//
+//	h := &handler.Handler{Copier: s3cp.NewCopier(s3.New(sess)), Rules: rules}
+//	lambda.Start(h.Handle)
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	s3cp "github.com/reedobrien/s3cp/lib"
+)
+
+// DefaultMargin is how long before its deadline Handle stops copying, to
+// leave time to abort uploads and respond.
+const DefaultMargin = 10 * time.Second
+
+// Rule copies the objects created under a source prefix to a destination.
+type Rule struct {
+	// Source is the bucket and key prefix of the objects the rule copies.
+	Source s3cp.Location
+
+	// Destination is the bucket and key prefix they are copied to. Each
+	// key has the Source prefix replaced with the Destination's.
+	Destination s3cp.Location
+
+	// Template is this rule's CopyInput for each notified object, e.g. with
+	// Encryption set; the object's size and keys come from the event.
+	// Delete can't be set, as another rule may copy the object too.
+	Template s3cp.CopyInput
+}
+
+// ParseRules parses rules from JSON, a list of objects with a source and
+// destination, e.g. s3://bucket/prefix/, and optionally a storageClass.
+func ParseRules(data []byte) ([]Rule, error) {
+	var configs []struct {
+		Source       string `json:"source"`
+		Destination  string `json:"destination"`
+		StorageClass string `json:"storageClass"`
+	}
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("invalid rules: %s", err)
+	}
+
+	rules := make([]Rule, len(configs))
+	for i, c := range configs {
+		var err error
+		if rules[i].Source, err = s3cp.ParseLocation(c.Source); err != nil {
+			return nil, fmt.Errorf("rule %d source: %s", i, err)
+		}
+		if rules[i].Destination, err = s3cp.ParseLocation(c.Destination); err != nil {
+			return nil, fmt.Errorf("rule %d destination: %s", i, err)
+		}
+		if c.StorageClass != "" {
+			rules[i].Template.COI.StorageClass = aws.String(c.StorageClass)
+		}
+		if err := rules[i].validate(); err != nil {
+			return nil, fmt.Errorf("rule %d: %s", i, err)
+		}
+	}
+	return rules, nil
+}
+
+func (r Rule) validate() error {
+	src, dst := r.Source, r.Destination
+	if src.Bucket == "" || dst.Bucket == "" {
+		return errors.New("a rule requires source and destination buckets")
+	}
+	if src.VersionID != "" || dst.VersionID != "" {
+		return errors.New("a rule can't have a version ID")
+	}
+	// S3 would notify the handler of each copy it makes, to copy again.
+	if src.Bucket == dst.Bucket && strings.HasPrefix(dst.Key, src.Key) {
+		return fmt.Errorf("destination %s is under source %s", dst, src)
+	}
+	if r.Template.Delete {
+		return errors.New("a rule can't delete its source")
+	}
+	return nil
+}
+
+// match returns the copy r makes of the object of rec at loc, if loc is under
+// its source. The copy is of the version or ETag in rec, so the size in rec
+// is that of the object copied.
+func (r Rule) match(rec s3cp.EventRecord, loc s3cp.Location) (s3cp.CopyInput, bool) {
+	if loc.Bucket != r.Source.Bucket || !strings.HasPrefix(loc.Key, r.Source.Key) {
+		return s3cp.CopyInput{}, false
+	}
+	ci := r.Template
+	if loc.VersionID == "" {
+		ci.COI.CopySourceIfMatch = rec.S3.Object.IfMatch()
+	}
+	if loc.VersionID != "" || ci.COI.CopySourceIfMatch != nil {
+		ci.Size = rec.S3.Object.Size
+	}
+	ci.COI.Bucket = aws.String(r.Destination.Bucket)
+	ci.COI.Key = aws.String(r.Destination.Key + strings.TrimPrefix(loc.Key, r.Source.Key))
+	ci.COI.CopySource = aws.String(loc.CopySource())
+	return ci, true
+}
+
+// Handler copies the objects created in S3 event notifications by every Rule
+// they match.
+type Handler struct {
+	Copier *s3cp.Copier
+	Rules  []Rule
+
+	// Margin is how long before the context's deadline no more copies are
+	// started and those running are cancelled. Defaults to DefaultMargin.
+	Margin time.Duration
+
+	// How many objects to copy at once. Defaults to
+	// s3cp.DefaultObjectConcurrency.
+	Concurrency int
+}
+
+// Response reports the copies of a Handle, by their destinations.
+type Response struct {
+	Copied []string `json:"copied,omitempty"`
+	Failed []Failed `json:"failed,omitempty"`
+
+	// Unstarted copies were not started before the Margin.
+	Unstarted []string `json:"unstarted,omitempty"`
+
+	// Replaced copies were skipped because their source no longer has the
+	// ETag in the event. The object replacing it has an event of its own.
+	Replaced []string `json:"replaced,omitempty"`
+
+	// Ignored counts the records that are not for created objects or that
+	// no rule matches.
+	Ignored int `json:"ignored,omitempty"`
+}
+
+// Failed is a copy that failed.
+type Failed struct {
+	Destination string `json:"destination"`
+	Error       string `json:"error"`
+}
+
+// Handle copies the objects created in event, an S3 event notification or
+// one delivered by SNS or EventBridge, as s3cp.ParseEvent takes. It has the
+// signature of a Lambda handler. If ctx has a deadline the copies stop Margin
+// before it. An error is returned if any copy failed or wasn't started, so
+// the event is retried.
+func (h *Handler) Handle(ctx context.Context, event json.RawMessage) (Response, error) {
+	var resp Response
+
+	for i, rule := range h.Rules {
+		if err := rule.validate(); err != nil {
+			return resp, fmt.Errorf("rule %d: %s", i, err)
+		}
+	}
+	records, err := s3cp.ParseEvent(event)
+	if err != nil {
+		return resp, err
+	}
+
+	type job struct {
+		in  s3cp.CopyInput
+		dst string
+		err error
+		ran bool
+	}
+	var jobs []*job
+	for _, rec := range records {
+		loc, err := rec.Location()
+		if !rec.Created() || err != nil {
+			resp.Ignored++
+			continue
+		}
+		var matched bool
+		for _, rule := range h.Rules {
+			if in, ok := rule.match(rec, loc); ok {
+				dst := s3cp.Location{Bucket: aws.StringValue(in.COI.Bucket), Key: aws.StringValue(in.COI.Key)}
+				jobs = append(jobs, &job{in: in, dst: dst.String()})
+				matched = true
+			}
+		}
+		if !matched {
+			resp.Ignored++
+		}
+	}
+
+	ctx, cancel := h.budget(ctx)
+	defer cancel()
+
+	n := h.Concurrency
+	if n <= 0 {
+		n = s3cp.DefaultObjectConcurrency
+	}
+	work := make(chan *job)
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			for j := range work {
+				if ctx.Err() != nil {
+					continue
+				}
+				j.ran = true
+				j.err = h.Copier.CopyWithContext(ctx, j.in)
+			}
+		}()
+	}
+	for _, j := range jobs {
+		work <- j
+	}
+	close(work)
+	wg.Wait()
+
+	for _, j := range jobs {
+		switch {
+		case !j.ran:
+			resp.Unstarted = append(resp.Unstarted, j.dst)
+		case s3cp.PreconditionFailed(j.err):
+			resp.Replaced = append(resp.Replaced, j.dst)
+		case j.err != nil:
+			resp.Failed = append(resp.Failed, Failed{Destination: j.dst, Error: j.err.Error()})
+		default:
+			resp.Copied = append(resp.Copied, j.dst)
+		}
+	}
+	if len(resp.Failed) > 0 || len(resp.Unstarted) > 0 {
+		return resp, fmt.Errorf("%d of %d copies failed and %d weren't started", len(resp.Failed), len(jobs), len(resp.Unstarted))
+	}
+	return resp, nil
+}
+
+// budget returns ctx ending Margin before its deadline, if it has one.
+func (h *Handler) budget(ctx context.Context) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+	margin := h.Margin
+	if margin <= 0 {
+		margin = DefaultMargin
+	}
+	return context.WithDeadline(ctx, deadline.Add(-margin))
+}
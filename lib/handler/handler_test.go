@@ -0,0 +1,146 @@
+package handler_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/reedobrien/checkers"
+	s3cp "github.com/reedobrien/s3cp/lib"
+	"github.com/reedobrien/s3cp/lib/dummy"
+	"github.com/reedobrien/s3cp/lib/handler"
+)
+
+const rules = `[
+	{"source": "s3://src/in/", "destination": "s3://dr-east/in/"},
+	{"source": "src/in/", "destination": "archive", "storageClass": "GLACIER"}
+]`
+
+func newHandler(t *testing.T) (*handler.Handler, *dummy.Fake) {
+	fake := dummy.NewFake()
+	fake.PutObject("src", "in/daily report.csv", &dummy.Object{Data: []byte("a,b\n1,2\n3,4\n")})
+	fake.PutObject("src", "tmp/scratch", &dummy.Object{Data: []byte("temp")})
+	fake.CreateBucket("dr-east")
+	fake.CreateBucket("archive")
+
+	rs, err := handler.ParseRules([]byte(rules))
+	checkers.OK(t, err)
+	return &handler.Handler{Copier: s3cp.NewCopier(fake), Rules: rs}, fake
+}
+
+func fixture(t *testing.T, name string) []byte {
+	b, err := os.ReadFile(filepath.Join("testdata", name))
+	checkers.OK(t, err)
+	return b
+}
+
+func TestHandle(t *testing.T) {
+	for _, tc := range []struct {
+		fixture string
+		ignored int
+	}{
+		{fixture: "s3.json", ignored: 2},
+		{fixture: "sns.json", ignored: 2},
+		{fixture: "sns-http.json", ignored: 2},
+		{fixture: "eventbridge.json"},
+	} {
+		t.Run(tc.fixture, func(t *testing.T) {
+			tut, fake := newHandler(t)
+
+			resp, err := tut.Handle(context.Background(), fixture(t, tc.fixture))
+			checkers.OK(t, err)
+			checkers.Equals(t, resp, handler.Response{
+				Copied:  []string{"s3://dr-east/in/daily report.csv", "s3://archive/daily report.csv"},
+				Ignored: tc.ignored,
+			})
+			checkers.Equals(t, fake.Object("dr-east", "in/daily report.csv").Data, []byte("a,b\n1,2\n3,4\n"))
+			checkers.Equals(t, fake.Object("archive", "daily report.csv").StorageClass, "GLACIER")
+			checkers.Equals(t, fake.Keys("src"), []string{"in/daily report.csv", "tmp/scratch"})
+		})
+	}
+}
+
+func TestHandleTestEvent(t *testing.T) {
+	tut, fake := newHandler(t)
+
+	resp, err := tut.Handle(context.Background(), fixture(t, "test.json"))
+	checkers.OK(t, err)
+	checkers.Equals(t, resp, handler.Response{})
+	checkers.Equals(t, fake.Calls("CopyObject"), 0)
+}
+
+func TestHandleFailed(t *testing.T) {
+	tut, fake := newHandler(t)
+	_, err := fake.DeleteObjectWithContext(context.Background(), &s3.DeleteObjectInput{Bucket: aws.String("src"), Key: aws.String("in/daily report.csv")})
+	checkers.OK(t, err)
+
+	resp, err := tut.Handle(context.Background(), fixture(t, "eventbridge.json"))
+	checkers.Assert(t, err != nil, "expected an error")
+	checkers.Equals(t, err.Error(), "2 of 2 copies failed and 0 weren't started")
+	checkers.Equals(t, len(resp.Failed), 2)
+	checkers.Equals(t, resp.Failed[0].Destination, "s3://dr-east/in/daily report.csv")
+}
+
+func TestHandleReplaced(t *testing.T) {
+	tut, fake := newHandler(t)
+	tut.Copier.PartSize = 5
+	// The object was replaced by a longer one after the event was sent.
+	fake.PutObject("src", "in/daily report.csv", &dummy.Object{Data: []byte("a,b\n1,2\n3,4\n5,6\n")})
+
+	resp, err := tut.Handle(context.Background(), fixture(t, "s3.json"))
+	checkers.OK(t, err)
+	checkers.Equals(t, resp, handler.Response{
+		Replaced: []string{"s3://dr-east/in/daily report.csv", "s3://archive/daily report.csv"},
+		Ignored:  2,
+	})
+	checkers.Equals(t, len(fake.Keys("dr-east")), 0)
+	checkers.Equals(t, len(fake.Keys("archive")), 0)
+}
+
+func TestHandleBudget(t *testing.T) {
+	tut, fake := newHandler(t)
+	tut.Margin = time.Minute
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	resp, err := tut.Handle(ctx, fixture(t, "s3.json"))
+	checkers.Assert(t, err != nil, "expected an error")
+	checkers.Equals(t, err.Error(), "0 of 2 copies failed and 2 weren't started")
+	checkers.Equals(t, resp.Unstarted, []string{"s3://dr-east/in/daily report.csv", "s3://archive/daily report.csv"})
+	checkers.Equals(t, fake.Calls("CopyObject"), 0)
+}
+
+func TestParseRulesErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		rules string
+		err   string
+	}{
+		{
+			name:  "json",
+			rules: `[`,
+			err:   "invalid rules: unexpected end of JSON input",
+		},
+		{
+			name:  "no destination",
+			rules: `[{"source": "src/in/"}]`,
+			err:   "rule 0 destination: empty location",
+		},
+		{
+			name:  "loop",
+			rules: `[{"source": "src/in/", "destination": "dr-east"}, {"source": "src/in/", "destination": "src/in/copies/"}]`,
+			err:   "rule 1: destination s3://src/in/copies/ is under source s3://src/in/",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := handler.ParseRules([]byte(tc.rules))
+			checkers.Assert(t, err != nil, "expected an error")
+			checkers.Equals(t, err.Error(), tc.err)
+		})
+	}
+}
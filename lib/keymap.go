@@ -0,0 +1,177 @@
+package s3cp
+
+import (
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Date partition granularities for KeyMapper.DatePartition.
+const (
+	PartitionYear  = "year"
+	PartitionMonth = "month"
+	PartitionDay   = "day"
+	PartitionHour  = "hour"
+)
+
+// KeyMapper maps the keys of a prefix copy to new keys, to move objects
+// between bucket layouts. Its steps apply, in the order of its fields, to the
+// key with the source prefix removed, and the destination prefix is added to
+// the result.
+type KeyMapper struct {
+	// Pattern, if set, is a regular expression whose matches in the key are
+	// replaced with Template, in which $1 or ${name} is a submatch, as in
+	// regexp.Regexp.ReplaceAllString.
+	Pattern  string
+	Template string
+
+	// Lower lowercases the key.
+	Lower bool
+
+	// DatePartition, if set, prefixes the key with a Hive-style partition
+	// of the object's LastModified in UTC, down to the PartitionYear,
+	// PartitionMonth, PartitionDay or PartitionHour, e.g.
+	// year=2024/month=03/day=01/.
+	DatePartition string
+
+	// Shards, if over 1, prefixes the key with one of that many zero padded
+	// shard numbers, picked by a hash of the key, to spread the load over
+	// prefixes.
+	Shards int
+}
+
+// Validate checks the pattern compiles and the steps are known.
+func (m *KeyMapper) Validate() error {
+	_, err := m.compile()
+	return err
+}
+
+// keyMapper is a compiled KeyMapper. A nil keyMapper keeps keys as they are.
+type keyMapper struct {
+	*KeyMapper
+	pattern *regexp.Regexp
+}
+
+func (m *KeyMapper) compile() (*keyMapper, error) {
+	if m == nil {
+		return nil, nil
+	}
+
+	c := &keyMapper{KeyMapper: m}
+	if m.Pattern != "" {
+		re, err := regexp.Compile(m.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key pattern %q: %s", m.Pattern, err)
+		}
+		c.pattern = re
+	}
+
+	switch m.DatePartition {
+	case "", PartitionYear, PartitionMonth, PartitionDay, PartitionHour:
+	default:
+		return nil, fmt.Errorf("invalid date partition %q, want year, month, day or hour", m.DatePartition)
+	}
+	if m.Shards < 0 {
+		return nil, fmt.Errorf("invalid shard count %d", m.Shards)
+	}
+	return c, nil
+}
+
+// mapKey maps key, last modified at modified.
+func (m *keyMapper) mapKey(key string, modified time.Time) string {
+	if m == nil {
+		return key
+	}
+
+	if m.pattern != nil {
+		key = m.pattern.ReplaceAllString(key, m.Template)
+	}
+	if m.Lower {
+		key = strings.ToLower(key)
+	}
+	if m.DatePartition != "" {
+		key = datePartition(m.DatePartition, modified) + key
+	}
+	if m.Shards > 1 {
+		h := fnv.New32a()
+		h.Write([]byte(key))
+		width := len(strconv.Itoa(m.Shards - 1))
+		key = fmt.Sprintf("%0*d/", width, h.Sum32()%uint32(m.Shards)) + key
+	}
+	return key
+}
+
+// datePartition returns the Hive-style partition of t down to granularity.
+func datePartition(granularity string, t time.Time) string {
+	t = t.UTC()
+	p := fmt.Sprintf("year=%04d/", t.Year())
+	if granularity == PartitionYear {
+		return p
+	}
+	p += fmt.Sprintf("month=%02d/", t.Month())
+	if granularity == PartitionMonth {
+		return p
+	}
+	p += fmt.Sprintf("day=%02d/", t.Day())
+	if granularity == PartitionDay {
+		return p
+	}
+	return p + fmt.Sprintf("hour=%02d/", t.Hour())
+}
+
+// destinations assigns the destination keys of a prefix copy and detects
+// collisions, where two sources map to the same key. It is safe for
+// concurrent use.
+type destinations struct {
+	src, dst Location
+	mapper   *keyMapper
+
+	mu   sync.Mutex
+	seen map[string]string
+}
+
+func newDestinations(in CopyPrefixInput, m *keyMapper) *destinations {
+	return &destinations{
+		src:    in.Source,
+		dst:    in.Destination,
+		mapper: m,
+		seen:   make(map[string]string),
+	}
+}
+
+// claim returns the destination key of the listed object o, or an error if
+// it is invalid, under the source prefix in the same bucket, or already the
+// destination of another object. Without a mapper keys can't collide and
+// aren't remembered.
+func (d *destinations) claim(o *s3.Object) (string, error) {
+	key := aws.StringValue(o.Key)
+	rel := strings.TrimPrefix(key, d.src.Key)
+	dst := d.dst.Key + d.mapper.mapKey(rel, aws.TimeValue(o.LastModified))
+
+	switch {
+	case dst == "":
+		return "", fmt.Errorf("%s maps to an empty key", key)
+	case len(dst) > MaxKeyLength:
+		return "", fmt.Errorf("%s maps to a key over %d bytes", key, MaxKeyLength)
+	case d.src.Bucket == d.dst.Bucket && strings.HasPrefix(dst, d.src.Key):
+		return "", fmt.Errorf("%s maps to %s, under source %s", key, dst, d.src)
+	case d.mapper == nil:
+		return dst, nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if other, ok := d.seen[dst]; ok {
+		return "", fmt.Errorf("%s maps to %s, which %s already maps to", key, dst, other)
+	}
+	d.seen[dst] = key
+	return dst, nil
+}
@@ -0,0 +1,172 @@
+package s3cp_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/reedobrien/checkers"
+	s3cp "github.com/reedobrien/s3cp/lib"
+	"github.com/reedobrien/s3cp/lib/dummy"
+)
+
+var modified = time.Date(2024, 3, 1, 7, 30, 0, 0, time.UTC)
+
+func newKeyMapFake(keys ...string) *dummy.Fake {
+	src := make(map[string]*dummy.Object)
+	for _, k := range keys {
+		src[k] = &dummy.Object{Data: []byte(k), LastModified: modified}
+	}
+	return dummy.NewFakeWith(dummy.Buckets{"src": src, "dst": nil})
+}
+
+func TestPlanPrefixKeyMapper(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		mapper *s3cp.KeyMapper
+		keys   []string
+		want   []string
+	}{
+		{
+			name: "none",
+			keys: []string{"logs/a.gz"},
+			want: []string{"new/a.gz"},
+		},
+		{
+			name: "template",
+			mapper: &s3cp.KeyMapper{
+				Pattern:  `^(\d{4})-(\d{2})-\d{2}/(?P<file>.*)$`,
+				Template: "year=$1/month=$2/${file}",
+			},
+			keys: []string{"logs/2023-12-31/a.gz", "logs/other.gz"},
+			want: []string{"new/year=2023/month=12/a.gz", "new/other.gz"},
+		},
+		{
+			name:   "lower",
+			mapper: &s3cp.KeyMapper{Lower: true},
+			keys:   []string{"logs/Mixed/CASE.GZ"},
+			want:   []string{"new/mixed/case.gz"},
+		},
+		{
+			name:   "day partition",
+			mapper: &s3cp.KeyMapper{DatePartition: s3cp.PartitionDay},
+			keys:   []string{"logs/a.gz"},
+			want:   []string{"new/year=2024/month=03/day=01/a.gz"},
+		},
+		{
+			name:   "hour partition",
+			mapper: &s3cp.KeyMapper{DatePartition: s3cp.PartitionHour},
+			keys:   []string{"logs/a.gz"},
+			want:   []string{"new/year=2024/month=03/day=01/hour=07/a.gz"},
+		},
+		{
+			name:   "shards",
+			mapper: &s3cp.KeyMapper{Shards: 16},
+			keys:   []string{"logs/a.gz", "logs/b.gz", "logs/c.gz"},
+			want:   []string{"new/11/a.gz", "new/08/b.gz", "new/09/c.gz"},
+		},
+		{
+			name:   "all",
+			mapper: &s3cp.KeyMapper{Pattern: `\.GZ$`, Template: ".gzip", Lower: true, DatePartition: s3cp.PartitionYear, Shards: 4},
+			keys:   []string{"logs/A.GZ"},
+			want:   []string{"new/3/year=2024/a.gzip"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := newKeyMapFake(tc.keys...)
+			tut := s3cp.NewCopier(fake)
+
+			p, err := tut.PlanPrefix(context.Background(), s3cp.CopyPrefixInput{
+				Source:      s3cp.Location{Bucket: "src", Key: "logs/"},
+				Destination: s3cp.Location{Bucket: "dst", Key: "new/"},
+				Mapper:      tc.mapper,
+			})
+			checkers.OK(t, err)
+
+			got := make(map[string]string)
+			for _, m := range p.Copies {
+				got[m.Source] = m.Destination
+			}
+			want := make(map[string]string)
+			for i, k := range tc.keys {
+				want[k] = tc.want[i]
+			}
+			checkers.Equals(t, got, want)
+			checkers.Equals(t, len(fake.Keys("dst")), 0)
+		})
+	}
+}
+
+func TestPlanPrefixString(t *testing.T) {
+	fake := newKeyMapFake("logs/A.gz", "logs/a.gz", "logs/b.txt")
+	tut := s3cp.NewCopier(fake)
+
+	p, err := tut.PlanPrefix(context.Background(), s3cp.CopyPrefixInput{
+		Source:      s3cp.Location{Bucket: "src", Key: "logs/"},
+		Destination: s3cp.Location{Bucket: "dst", Key: "new/"},
+		Filter:      &s3cp.Filter{Include: []string{"*.gz"}},
+		Mapper:      &s3cp.KeyMapper{Lower: true},
+	})
+	checkers.OK(t, err)
+	checkers.Equals(t, p.String(), `copy s3://src/logs/ to s3://dst/new/
+  logs/A.gz -> new/a.gz (9 bytes)
+  1 objects, 9 bytes; 1 filtered
+  error: logs/a.gz maps to new/a.gz, which logs/A.gz already maps to
+`)
+}
+
+func TestPlanPrefixUnderSource(t *testing.T) {
+	fake := newKeyMapFake("logs/2024/a.gz", "logs/2024/b.gz")
+	tut := s3cp.NewCopier(fake)
+
+	p, err := tut.PlanPrefix(context.Background(), s3cp.CopyPrefixInput{
+		Source:      s3cp.Location{Bucket: "src", Key: "logs/2024/"},
+		Destination: s3cp.Location{Bucket: "src", Key: "logs/"},
+		Mapper:      &s3cp.KeyMapper{Pattern: `^a`, Template: "2024/a"},
+	})
+	checkers.OK(t, err)
+	checkers.Equals(t, p.Copies, []s3cp.KeyMapping{{Source: "logs/2024/b.gz", Destination: "logs/b.gz", Size: 14}})
+	checkers.Equals(t, p.Errors, []string{"logs/2024/a.gz maps to logs/2024/a.gz, under source s3://src/logs/2024/"})
+}
+
+func TestCopyPrefixCollisions(t *testing.T) {
+	fake := newKeyMapFake("logs/A.gz", "logs/a.gz", "logs/b.gz")
+	tut := s3cp.NewCopier(fake)
+
+	var failed []s3cp.ObjectResult
+	sum, err := tut.CopyPrefix(context.Background(), s3cp.CopyPrefixInput{
+		Source:      s3cp.Location{Bucket: "src", Key: "logs/"},
+		Destination: s3cp.Location{Bucket: "dst", Key: "new/"},
+		Mapper:      &s3cp.KeyMapper{Lower: true},
+		Concurrency: 1,
+	}, func(r s3cp.ObjectResult) {
+		if r.Err != nil {
+			failed = append(failed, r)
+		}
+	})
+	checkers.Equals(t, err.Error(), "1 of 3 objects failed")
+	checkers.Equals(t, sum, s3cp.Summary{Copied: 2, Failed: 1})
+	checkers.Equals(t, fake.Keys("dst"), []string{"new/a.gz", "new/b.gz"})
+	checkers.Equals(t, fake.Object("dst", "new/a.gz").Data, []byte("logs/A.gz"))
+
+	checkers.Equals(t, len(failed), 1)
+	checkers.Equals(t, failed[0].Key, "logs/a.gz")
+	checkers.Equals(t, failed[0].Err.Error(), "logs/a.gz maps to new/a.gz, which logs/A.gz already maps to")
+}
+
+func TestKeyMapperValidate(t *testing.T) {
+	for _, tc := range []struct {
+		mapper s3cp.KeyMapper
+		err    string
+	}{
+		{s3cp.KeyMapper{Pattern: "(a"}, "invalid key pattern \"(a\": error parsing regexp: missing closing ): `(a`"},
+		{s3cp.KeyMapper{DatePartition: "week"}, `invalid date partition "week", want year, month, day or hour`},
+		{s3cp.KeyMapper{Shards: -1}, "invalid shard count -1"},
+	} {
+		t.Run(tc.err, func(t *testing.T) {
+			err := tc.mapper.Validate()
+			checkers.Assert(t, err != nil, "expected an error")
+			checkers.Equals(t, err.Error(), tc.err)
+		})
+	}
+}
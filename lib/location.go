@@ -0,0 +1,242 @@
+package s3cp
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxKeyLength is the longest key S3 accepts, in bytes.
+const MaxKeyLength = 1024
+
+const versionQuery = "?versionId="
+
+// bucketName matches bucket names, including the upper case and underscores
+// of legacy us-east-1 buckets.
+var bucketName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]{1,253}[a-zA-Z0-9]$`)
+
+// Location is an object, or a bucket or prefix if Key is empty or ends in /.
+type Location struct {
+	// Bucket is the bucket name, or an access point or Multi-Region Access
+	// Point ARN, which the API accepts in place of a bucket name.
+	Bucket string
+
+	Key string
+
+	// VersionID selects a version of the object. If empty the current
+	// version is used.
+	VersionID string
+}
+
+// ParseLocation parses s, which is one of
+//
+//	bucket/key
+//	s3://bucket/key
+//	https://bucket.s3.region.amazonaws.com/key
+//	https://s3.region.amazonaws.com/bucket/key
+//	arn:aws:s3:region:account:accesspoint/name/key
+//	arn:aws:s3::account:accesspoint/alias.mrap/key
+//
+// with an optional ?versionId=. The key of an HTTPS URL is URL-decoded, the
+// others are taken as is.
+func ParseLocation(s string) (Location, error) {
+	if s == "" {
+		return Location{}, errors.New("empty location")
+	}
+	if strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "http://") {
+		return parseURL(s)
+	}
+
+	path, version := splitVersion(strings.TrimPrefix(s, "s3://"))
+	l, err := parsePath(path, false)
+	if err != nil {
+		return Location{}, fmt.Errorf("invalid location %q: %s", s, err)
+	}
+	l.VersionID = version
+	return l, l.validate(s)
+}
+
+// ParseCopySource parses a CopySource, the URL-encoded bucket/key, or access
+// point ARN/object/key, with an optional ?versionId=.
+func ParseCopySource(cs string) (Location, error) {
+	path, version := splitVersion(strings.TrimPrefix(cs, "/"))
+	if version != "" {
+		var err error
+		if version, err = url.PathUnescape(version); err != nil {
+			return Location{}, fmt.Errorf("invalid copy source %q: %s", cs, err)
+		}
+	}
+	path, err := url.PathUnescape(path)
+	if err != nil {
+		return Location{}, fmt.Errorf("invalid copy source %q: %s", cs, err)
+	}
+
+	l, err := parsePath(path, true)
+	if err != nil {
+		return Location{}, fmt.Errorf("invalid copy source %q: %s", cs, err)
+	}
+	l.VersionID = version
+	if err := l.validate(cs); err != nil {
+		return Location{}, err
+	}
+	if l.Key == "" {
+		return Location{}, fmt.Errorf("invalid copy source %q: no key", cs)
+	}
+	return l, nil
+}
+
+// CopySource returns l as a canonical CopySource, with everything but the
+// RFC 3986 unreserved characters and / percent-encoded.
+func (l Location) CopySource() string {
+	path := l.Bucket + "/" + l.Key
+	if isARN(l.Bucket) {
+		path = l.Bucket + "/object/" + l.Key
+	}
+
+	cs := escape(path, "/")
+	if l.VersionID != "" {
+		cs += versionQuery + escape(l.VersionID, "")
+	}
+	return cs
+}
+
+// escape percent-encodes every byte of s except the RFC 3986 unreserved
+// characters and those in keep. Unlike url.PathEscape it encodes +, which
+// some decoders take for a space, and the sub-delimiters.
+func escape(s, keep string) string {
+	const hex = "0123456789ABCDEF"
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9',
+			c == '-', c == '.', c == '_', c == '~', strings.IndexByte(keep, c) >= 0:
+			b.WriteByte(c)
+		default:
+			b.WriteByte('%')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&15])
+		}
+	}
+	return b.String()
+}
+
+func (l Location) String() string {
+	s := "s3://" + l.Bucket + "/" + l.Key
+	if l.VersionID != "" {
+		s += versionQuery + l.VersionID
+	}
+	return s
+}
+
+func (l Location) validate(s string) error {
+	switch {
+	case l.Bucket == "":
+		return fmt.Errorf("invalid location %q: no bucket", s)
+	case !isARN(l.Bucket) && !bucketName.MatchString(l.Bucket):
+		return fmt.Errorf("invalid location %q: invalid bucket name %q", s, l.Bucket)
+	case len(l.Key) > MaxKeyLength:
+		return fmt.Errorf("invalid location %q: key is longer than %d bytes", s, MaxKeyLength)
+	case !utf8.ValidString(l.Key):
+		return fmt.Errorf("invalid location %q: key is not valid UTF-8", s)
+	case l.VersionID != "" && l.Key == "":
+		return fmt.Errorf("invalid location %q: a version ID requires a key", s)
+	}
+	return nil
+}
+
+// splitVersion splits a trailing ?versionId= from s.
+func splitVersion(s string) (string, string) {
+	i := strings.LastIndex(s, versionQuery)
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], s[i+len(versionQuery):]
+}
+
+// parsePath parses bucket/key, or an access point ARN followed by the key.
+// A CopySource puts /object/ between an ARN and its key.
+func parsePath(path string, copySource bool) (Location, error) {
+	if !isARN(path) {
+		bucket, key, _ := strings.Cut(path, "/")
+		return Location{Bucket: bucket, Key: key}, nil
+	}
+
+	// arn:partition:s3:region:account:accesspoint/name[/key]
+	fields := strings.SplitN(path, ":", 6)
+	if len(fields) != 6 || fields[1] == "" || fields[2] != "s3" {
+		return Location{}, errors.New("not an S3 access point ARN")
+	}
+	if len(fields[4]) != 12 {
+		return Location{}, fmt.Errorf("invalid account ID %q", fields[4])
+	}
+
+	resource := strings.SplitN(fields[5], "/", 3)
+	if len(resource) < 2 || resource[0] != "accesspoint" || resource[1] == "" {
+		return Location{}, errors.New("not an S3 access point ARN")
+	}
+	name := resource[1]
+	if fields[3] == "" && !strings.HasSuffix(name, ".mrap") {
+		return Location{}, errors.New("access point ARN has no region")
+	}
+
+	l := Location{Bucket: strings.Join(fields[:5], ":") + ":accesspoint/" + name}
+	if len(resource) == 3 {
+		l.Key = resource[2]
+	}
+	if copySource {
+		key, ok := strings.CutPrefix(l.Key, "object/")
+		if !ok {
+			return Location{}, errors.New("access point ARN without /object/")
+		}
+		l.Key = key
+	}
+	return l, nil
+}
+
+// parseURL parses a virtual-hosted or path-style S3 URL.
+func parseURL(s string) (Location, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return Location{}, fmt.Errorf("invalid location %q: %s", s, err)
+	}
+
+	host := strings.ToLower(u.Hostname())
+	host, ok := strings.CutSuffix(host, ".amazonaws.com")
+	if !ok {
+		host, ok = strings.CutSuffix(host, ".amazonaws.com.cn")
+	}
+	if !ok {
+		return Location{}, fmt.Errorf("invalid location %q: not an S3 URL", s)
+	}
+
+	// The endpoint is s3, s3-region, s3.region or s3.dualstack.region, which
+	// a virtual-hosted URL prefixes with the bucket.
+	labels := strings.Split(host, ".")
+	i := len(labels) - 1
+	for ; i >= 0; i-- {
+		if labels[i] == "s3" || strings.HasPrefix(labels[i], "s3-") {
+			break
+		}
+	}
+	if i < 0 || len(labels)-i > 3 || labels[i] == "s3-accesspoint" {
+		return Location{}, fmt.Errorf("invalid location %q: not an S3 URL", s)
+	}
+
+	path := strings.TrimPrefix(u.Path, "/")
+	l := Location{VersionID: u.Query().Get("versionId")}
+	if i == 0 {
+		l.Bucket, l.Key, _ = strings.Cut(path, "/")
+	} else {
+		l.Bucket, l.Key = strings.Join(labels[:i], "."), path
+	}
+	return l, l.validate(s)
+}
+
+func isARN(s string) bool {
+	return strings.HasPrefix(s, "arn:")
+}
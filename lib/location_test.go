@@ -0,0 +1,111 @@
+package s3cp_test
+
+import (
+	"testing"
+
+	"github.com/reedobrien/checkers"
+	s3cp "github.com/reedobrien/s3cp/lib"
+)
+
+const (
+	accessPointARN = "arn:aws:s3:us-west-2:123456789012:accesspoint/my-ap"
+	mrapARN        = "arn:aws:s3::123456789012:accesspoint/mfzwi23gnjvgw.mrap"
+)
+
+func TestParseLocation(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want s3cp.Location
+	}{
+		{"bucket/key", s3cp.Location{Bucket: "bucket", Key: "key"}},
+		{"bucket", s3cp.Location{Bucket: "bucket"}},
+		{"bucket/", s3cp.Location{Bucket: "bucket"}},
+		{"bucket/a/b c+d%", s3cp.Location{Bucket: "bucket", Key: "a/b c+d%"}},
+		{"Legacy_Bucket/key", s3cp.Location{Bucket: "Legacy_Bucket", Key: "key"}},
+		{"s3://bucket/a/key", s3cp.Location{Bucket: "bucket", Key: "a/key"}},
+		{"s3://bucket/key?versionId=v1", s3cp.Location{Bucket: "bucket", Key: "key", VersionID: "v1"}},
+		{"https://bucket.s3.amazonaws.com/a/key", s3cp.Location{Bucket: "bucket", Key: "a/key"}},
+		{"https://my.bucket.s3.us-west-2.amazonaws.com/a%20key", s3cp.Location{Bucket: "my.bucket", Key: "a key"}},
+		{"https://bucket.s3-eu-west-1.amazonaws.com/key", s3cp.Location{Bucket: "bucket", Key: "key"}},
+		{"https://bucket.s3.dualstack.us-east-1.amazonaws.com/key", s3cp.Location{Bucket: "bucket", Key: "key"}},
+		{"https://s3.amazonaws.com/bucket/a/key", s3cp.Location{Bucket: "bucket", Key: "a/key"}},
+		{"https://s3.cn-north-1.amazonaws.com.cn/bucket/key", s3cp.Location{Bucket: "bucket", Key: "key"}},
+		{"https://s3.us-west-2.amazonaws.com/bucket/key?versionId=v%2B1", s3cp.Location{Bucket: "bucket", Key: "key", VersionID: "v+1"}},
+		{accessPointARN + "/a/key", s3cp.Location{Bucket: accessPointARN, Key: "a/key"}},
+		{"s3://" + accessPointARN + "/key", s3cp.Location{Bucket: accessPointARN, Key: "key"}},
+		{mrapARN + "/key?versionId=v1", s3cp.Location{Bucket: mrapARN, Key: "key", VersionID: "v1"}},
+	} {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := s3cp.ParseLocation(tc.in)
+			checkers.OK(t, err)
+			checkers.Equals(t, got, tc.want)
+		})
+	}
+}
+
+func TestParseLocationErrors(t *testing.T) {
+	for _, tc := range []struct {
+		in  string
+		err string
+	}{
+		{"", "empty location"},
+		{"/key", `invalid location "/key": no bucket`},
+		{"s3://", `invalid location "s3://": no bucket`},
+		{"b/key", `invalid location "b/key": invalid bucket name "b"`},
+		{"-bucket/key", `invalid location "-bucket/key": invalid bucket name "-bucket"`},
+		{"bucket?versionId=v1", `invalid location "bucket?versionId=v1": a version ID requires a key`},
+		{"bucket/\xff", `invalid location "bucket/\xff": key is not valid UTF-8`},
+		{"https://example.com/bucket/key", `invalid location "https://example.com/bucket/key": not an S3 URL`},
+		{"https://ec2.us-east-1.amazonaws.com/key", `invalid location "https://ec2.us-east-1.amazonaws.com/key": not an S3 URL`},
+		{"https://bucket%zz.s3.amazonaws.com/key", `invalid location "https://bucket%zz.s3.amazonaws.com/key": parse "https://bucket%zz.s3.amazonaws.com/key": invalid URL escape "%zz"`},
+		{"arn:aws:sqs:us-east-1:123456789012:queue", `invalid location "arn:aws:sqs:us-east-1:123456789012:queue": not an S3 access point ARN`},
+		{"arn:aws:s3:us-east-1:123:accesspoint/ap/key", `invalid location "arn:aws:s3:us-east-1:123:accesspoint/ap/key": invalid account ID "123"`},
+		{"arn:aws:s3:::bucket/key", `invalid location "arn:aws:s3:::bucket/key": invalid account ID ""`},
+		{"arn:aws:s3::123456789012:accesspoint/ap/key", `invalid location "arn:aws:s3::123456789012:accesspoint/ap/key": access point ARN has no region`},
+	} {
+		t.Run(tc.in, func(t *testing.T) {
+			_, err := s3cp.ParseLocation(tc.in)
+			checkers.Assert(t, err != nil, "expected an error")
+			checkers.Equals(t, err.Error(), tc.err)
+		})
+	}
+}
+
+func TestLocationCopySource(t *testing.T) {
+	for _, tc := range []struct {
+		loc  s3cp.Location
+		want string
+	}{
+		{s3cp.Location{Bucket: "bucket", Key: "a/key"}, "bucket/a/key"},
+		{s3cp.Location{Bucket: "bucket", Key: "a key?#"}, "bucket/a%20key%3F%23"},
+		{s3cp.Location{Bucket: "bucket", Key: "key", VersionID: "v+1"}, "bucket/key?versionId=v%2B1"},
+		{s3cp.Location{Bucket: "bucket", Key: "a+b=c&d;é"}, "bucket/a%2Bb%3Dc%26d%3B%C3%A9"},
+		{s3cp.Location{Bucket: accessPointARN, Key: "a/key"}, "arn%3Aaws%3As3%3Aus-west-2%3A123456789012%3Aaccesspoint/my-ap/object/a/key"},
+	} {
+		t.Run(tc.want, func(t *testing.T) {
+			got := tc.loc.CopySource()
+			checkers.Equals(t, got, tc.want)
+
+			back, err := s3cp.ParseCopySource(got)
+			checkers.OK(t, err)
+			checkers.Equals(t, back, tc.loc)
+		})
+	}
+}
+
+func TestParseCopySourceErrors(t *testing.T) {
+	for _, tc := range []struct {
+		in  string
+		err string
+	}{
+		{"bucket", `invalid copy source "bucket": no key`},
+		{"bucket/100%", `invalid copy source "bucket/100%": invalid URL escape "%"`},
+		{accessPointARN + "/key", `invalid copy source "` + accessPointARN + `/key": access point ARN without /object/`},
+	} {
+		t.Run(tc.in, func(t *testing.T) {
+			_, err := s3cp.ParseCopySource(tc.in)
+			checkers.Assert(t, err != nil, "expected an error")
+			checkers.Equals(t, err.Error(), tc.err)
+		})
+	}
+}
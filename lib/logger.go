@@ -0,0 +1,54 @@
+package s3cp
+
+import (
+	"log/slog"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+// Logger is a leveled structured logger. Arguments after the message are
+// alternating keys and values, as with log/slog, and a *slog.Logger satisfies
+// the interface.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// logger returns the configured Logger, or the slog default if none is set.
+func (c Copier) logger() Logger {
+	if c.Logger == nil {
+		return slog.Default()
+	}
+	return c.Logger
+}
+
+func (c *copier) logger() Logger {
+	return c.cfg.logger()
+}
+
+// logAttrs returns the key/value pairs identifying the copy followed by kv.
+// Only identifying fields are logged; never whole API inputs, which can carry
+// SSE-C keys.
+func (c *copier) logAttrs(kv ...interface{}) []interface{} {
+	attrs := []interface{}{
+		"source", aws.StringValue(c.in.COI.CopySource),
+		"bucket", aws.StringValue(c.in.COI.Bucket),
+		"key", aws.StringValue(c.in.COI.Key),
+	}
+	if c.MultipartUploadID != nil {
+		attrs = append(attrs, "upload_id", *c.MultipartUploadID)
+	}
+	return append(attrs, kv...)
+}
+
+// errAttrs returns the key/value pairs describing err, including its code if
+// it is an awserr.Error.
+func errAttrs(err error) []interface{} {
+	if aerr, ok := err.(awserr.Error); ok {
+		return []interface{}{"error", err.Error(), "code", aerr.Code()}
+	}
+	return []interface{}{"error", err.Error()}
+}
@@ -0,0 +1,54 @@
+package s3cp
+
+import "time"
+
+// Metrics receives measurements from a Copier. Implementations must be safe
+// for concurrent use. The metrics package has Prometheus and expvar
+// implementations.
+type Metrics interface {
+	// AddBytesCopied counts bytes successfully copied to the destination.
+	AddBytesCopied(n int64)
+
+	// IncPartsSucceeded counts parts copied successfully.
+	IncPartsSucceeded()
+
+	// IncPartsFailed counts parts that failed after every attempt.
+	IncPartsFailed()
+
+	// IncPartsRetried counts part copy attempts after the first.
+	IncPartsRetried()
+
+	// AddPartsInFlight adjusts the number of parts being copied.
+	AddPartsInFlight(delta int64)
+
+	// IncUploadsAborted counts aborted multipart uploads.
+	IncUploadsAborted()
+
+	// ObserveCall records the latency of an S3 call. The op is the S3
+	// operation name, e.g. UploadPartCopy.
+	ObserveCall(op string, d time.Duration)
+}
+
+// nopMetrics is the Metrics used when none are configured.
+type nopMetrics struct{}
+
+func (nopMetrics) AddBytesCopied(int64)              {}
+func (nopMetrics) IncPartsSucceeded()                {}
+func (nopMetrics) IncPartsFailed()                   {}
+func (nopMetrics) IncPartsRetried()                  {}
+func (nopMetrics) AddPartsInFlight(int64)            {}
+func (nopMetrics) IncUploadsAborted()                {}
+func (nopMetrics) ObserveCall(string, time.Duration) {}
+
+// metrics returns the configured Metrics, or a no-op implementation.
+func (c *copier) metrics() Metrics {
+	if c.cfg.Metrics == nil {
+		return nopMetrics{}
+	}
+	return c.cfg.Metrics
+}
+
+// observe records the latency of the op call that began at start.
+func (c *copier) observe(op string, start time.Time) {
+	c.metrics().ObserveCall(op, time.Since(start))
+}
@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"expvar"
+	"time"
+)
+
+// NewExpvar publishes an expvar.Map with the given name and returns an
+// s3cp.Metrics recording to it. Like expvar.Publish it panics if the name is
+// already in use.
+//
+// Call latencies are exported as a count and total seconds per operation
+// under calls and call_seconds.
+func NewExpvar(name string) *Expvar {
+	e := &Expvar{
+		vars:        expvar.NewMap(name),
+		calls:       new(expvar.Map).Init(),
+		callSeconds: new(expvar.Map).Init(),
+	}
+	e.vars.Set("calls", e.calls)
+	e.vars.Set("call_seconds", e.callSeconds)
+	return e
+}
+
+// Expvar is an s3cp.Metrics that exports to expvar.
+type Expvar struct {
+	vars        *expvar.Map
+	calls       *expvar.Map
+	callSeconds *expvar.Map
+}
+
+// AddBytesCopied implements s3cp.Metrics.
+func (e *Expvar) AddBytesCopied(n int64) { e.vars.Add("bytes_copied", n) }
+
+// IncPartsSucceeded implements s3cp.Metrics.
+func (e *Expvar) IncPartsSucceeded() { e.vars.Add("parts_succeeded", 1) }
+
+// IncPartsFailed implements s3cp.Metrics.
+func (e *Expvar) IncPartsFailed() { e.vars.Add("parts_failed", 1) }
+
+// IncPartsRetried implements s3cp.Metrics.
+func (e *Expvar) IncPartsRetried() { e.vars.Add("parts_retried", 1) }
+
+// AddPartsInFlight implements s3cp.Metrics.
+func (e *Expvar) AddPartsInFlight(delta int64) { e.vars.Add("parts_in_flight", delta) }
+
+// IncUploadsAborted implements s3cp.Metrics.
+func (e *Expvar) IncUploadsAborted() { e.vars.Add("uploads_aborted", 1) }
+
+// ObserveCall implements s3cp.Metrics.
+func (e *Expvar) ObserveCall(op string, d time.Duration) {
+	e.calls.Add(op, 1)
+	e.callSeconds.AddFloat(op, d.Seconds())
+}
@@ -0,0 +1,58 @@
+// Package metrics provides s3cp.Metrics implementations that export copy
+// measurements to Prometheus and expvar.
+package metrics
+
+import (
+	"time"
+
+	s3cp "github.com/reedobrien/s3cp/lib"
+)
+
+// Multi returns a s3cp.Metrics that records to every m.
+func Multi(m ...s3cp.Metrics) s3cp.Metrics {
+	return multi(m)
+}
+
+type multi []s3cp.Metrics
+
+func (mm multi) AddBytesCopied(n int64) {
+	for _, m := range mm {
+		m.AddBytesCopied(n)
+	}
+}
+
+func (mm multi) IncPartsSucceeded() {
+	for _, m := range mm {
+		m.IncPartsSucceeded()
+	}
+}
+
+func (mm multi) IncPartsFailed() {
+	for _, m := range mm {
+		m.IncPartsFailed()
+	}
+}
+
+func (mm multi) IncPartsRetried() {
+	for _, m := range mm {
+		m.IncPartsRetried()
+	}
+}
+
+func (mm multi) AddPartsInFlight(delta int64) {
+	for _, m := range mm {
+		m.AddPartsInFlight(delta)
+	}
+}
+
+func (mm multi) IncUploadsAborted() {
+	for _, m := range mm {
+		m.IncUploadsAborted()
+	}
+}
+
+func (mm multi) ObserveCall(op string, d time.Duration) {
+	for _, m := range mm {
+		m.ObserveCall(op, d)
+	}
+}
@@ -0,0 +1,113 @@
+package metrics_test
+
+import (
+	"expvar"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/reedobrien/checkers"
+	"github.com/reedobrien/s3cp/lib/dummy"
+	"github.com/reedobrien/s3cp/lib/metrics"
+)
+
+func TestExpvar(t *testing.T) {
+	tut := metrics.NewExpvar("s3cp_test")
+	record(tut)
+
+	vars := expvar.Get("s3cp_test").(*expvar.Map)
+	checkers.Equals(t, vars.Get("bytes_copied").String(), "30")
+	checkers.Equals(t, vars.Get("parts_succeeded").String(), "2")
+	checkers.Equals(t, vars.Get("parts_failed").String(), "1")
+	checkers.Equals(t, vars.Get("parts_retried").String(), "1")
+	checkers.Equals(t, vars.Get("parts_in_flight").String(), "0")
+	checkers.Equals(t, vars.Get("uploads_aborted").String(), "1")
+
+	calls := vars.Get("calls").(*expvar.Map)
+	checkers.Equals(t, calls.Get("UploadPartCopy").String(), "3")
+	seconds := vars.Get("call_seconds").(*expvar.Map)
+	checkers.Equals(t, seconds.Get("UploadPartCopy").String(), "6")
+}
+
+func TestPrometheus(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	tut, err := metrics.NewPrometheus(reg)
+	checkers.OK(t, err)
+	record(tut)
+
+	n, err := testutil.GatherAndCount(reg)
+	checkers.OK(t, err)
+	checkers.Equals(t, n, 7)
+
+	err = testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP s3cp_bytes_copied_total Bytes copied to the destination.
+# TYPE s3cp_bytes_copied_total counter
+s3cp_bytes_copied_total 30
+# HELP s3cp_parts_total Multipart copy parts by result: succeeded, failed or retried.
+# TYPE s3cp_parts_total counter
+s3cp_parts_total{result="failed"} 1
+s3cp_parts_total{result="retried"} 1
+s3cp_parts_total{result="succeeded"} 2
+# HELP s3cp_parts_in_flight Parts currently being copied.
+# TYPE s3cp_parts_in_flight gauge
+s3cp_parts_in_flight 0
+# HELP s3cp_uploads_aborted_total Multipart uploads aborted after a failure.
+# TYPE s3cp_uploads_aborted_total counter
+s3cp_uploads_aborted_total 1
+`),
+		"s3cp_bytes_copied_total",
+		"s3cp_parts_total",
+		"s3cp_parts_in_flight",
+		"s3cp_uploads_aborted_total",
+	)
+	checkers.OK(t, err)
+
+	_, err = metrics.NewPrometheus(reg)
+	checkers.Assert(t, err != nil, "expected duplicate registration error")
+}
+
+func TestMulti(t *testing.T) {
+	a, b := dummy.NewMetrics(), dummy.NewMetrics()
+	record(metrics.Multi(a, b))
+
+	for _, m := range []*dummy.Metrics{a, b} {
+		checkers.Equals(t, m.BytesCopied, int64(30))
+		checkers.Equals(t, m.PartsSucceeded, int64(2))
+		checkers.Equals(t, m.PartsFailed, int64(1))
+		checkers.Equals(t, m.PartsRetried, int64(1))
+		checkers.Equals(t, m.PartsInFlight, int64(0))
+		checkers.Equals(t, m.UploadsAborted, int64(1))
+		checkers.Equals(t, m.Calls, map[string]int{"UploadPartCopy": 3})
+	}
+}
+
+// recorder is the s3cp.Metrics method set, declared here to avoid importing
+// s3cp just for the interface.
+type recorder interface {
+	AddBytesCopied(int64)
+	IncPartsSucceeded()
+	IncPartsFailed()
+	IncPartsRetried()
+	AddPartsInFlight(int64)
+	IncUploadsAborted()
+	ObserveCall(string, time.Duration)
+}
+
+// record simulates a copy of three parts where one fails after a retry.
+func record(m recorder) {
+	for i := 0; i < 3; i++ {
+		m.AddPartsInFlight(1)
+		m.ObserveCall("UploadPartCopy", 2*time.Second)
+		m.AddPartsInFlight(-1)
+	}
+	m.AddBytesCopied(10)
+	m.AddBytesCopied(20)
+	m.IncPartsSucceeded()
+	m.IncPartsSucceeded()
+	m.IncPartsRetried()
+	m.IncPartsFailed()
+	m.IncUploadsAborted()
+}
@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// NewPrometheus registers the copy collectors with reg and returns an
+// s3cp.Metrics recording to them.
+func NewPrometheus(reg prometheus.Registerer) (*Prometheus, error) {
+	p := &Prometheus{
+		bytesCopied: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "s3cp_bytes_copied_total",
+			Help: "Bytes copied to the destination.",
+		}),
+		parts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "s3cp_parts_total",
+			Help: "Multipart copy parts by result: succeeded, failed or retried.",
+		}, []string{"result"}),
+		partsInFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "s3cp_parts_in_flight",
+			Help: "Parts currently being copied.",
+		}),
+		uploadsAborted: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "s3cp_uploads_aborted_total",
+			Help: "Multipart uploads aborted after a failure.",
+		}),
+		callDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "s3cp_s3_call_duration_seconds",
+			Help:    "Latency of S3 calls by operation.",
+			Buckets: prometheus.ExponentialBuckets(0.01, 2, 16),
+		}, []string{"operation"}),
+	}
+
+	for _, c := range []prometheus.Collector{
+		p.bytesCopied,
+		p.parts,
+		p.partsInFlight,
+		p.uploadsAborted,
+		p.callDuration,
+	} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+// Prometheus is an s3cp.Metrics that exports to Prometheus.
+type Prometheus struct {
+	bytesCopied    prometheus.Counter
+	parts          *prometheus.CounterVec
+	partsInFlight  prometheus.Gauge
+	uploadsAborted prometheus.Counter
+	callDuration   *prometheus.HistogramVec
+}
+
+// AddBytesCopied implements s3cp.Metrics.
+func (p *Prometheus) AddBytesCopied(n int64) { p.bytesCopied.Add(float64(n)) }
+
+// IncPartsSucceeded implements s3cp.Metrics.
+func (p *Prometheus) IncPartsSucceeded() { p.parts.WithLabelValues("succeeded").Inc() }
+
+// IncPartsFailed implements s3cp.Metrics.
+func (p *Prometheus) IncPartsFailed() { p.parts.WithLabelValues("failed").Inc() }
+
+// IncPartsRetried implements s3cp.Metrics.
+func (p *Prometheus) IncPartsRetried() { p.parts.WithLabelValues("retried").Inc() }
+
+// AddPartsInFlight implements s3cp.Metrics.
+func (p *Prometheus) AddPartsInFlight(delta int64) { p.partsInFlight.Add(float64(delta)) }
+
+// IncUploadsAborted implements s3cp.Metrics.
+func (p *Prometheus) IncUploadsAborted() { p.uploadsAborted.Inc() }
+
+// ObserveCall implements s3cp.Metrics.
+func (p *Prometheus) ObserveCall(op string, d time.Duration) {
+	p.callDuration.WithLabelValues(op).Observe(d.Seconds())
+}
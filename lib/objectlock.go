@@ -0,0 +1,183 @@
+package s3cp
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// ObjectLock describes the Object Lock settings of a copy's destination.
+type ObjectLock struct {
+	// FromSource copies the source's retention and legal hold. Mode,
+	// RetainUntil and LegalHold, when set, override what is copied.
+	FromSource bool
+
+	// Mode is the retention mode, s3.ObjectLockModeGovernance or
+	// s3.ObjectLockModeCompliance. It requires RetainUntil.
+	Mode string
+
+	// RetainUntil is the date the retention expires.
+	RetainUntil time.Time
+
+	// LegalHold places a legal hold on the destination.
+	LegalHold bool
+}
+
+// Validate checks the retention mode and date are set together.
+func (l *ObjectLock) Validate() error {
+	switch l.Mode {
+	case "":
+		if !l.RetainUntil.IsZero() {
+			return fmt.Errorf("a retain until date requires a retention mode")
+		}
+	case s3.ObjectLockModeGovernance, s3.ObjectLockModeCompliance:
+		if l.RetainUntil.IsZero() {
+			return fmt.Errorf("retention mode %s requires a retain until date", l.Mode)
+		}
+	default:
+		return fmt.Errorf("unknown retention mode %q", l.Mode)
+	}
+	return nil
+}
+
+// applyObjectLock validates the CopyInput's ObjectLock and sets the
+// corresponding fields of the CopyObjectInput. If any are set the
+// destination bucket must have Object Lock enabled, which is checked first so
+// a copy doesn't fail after moving terabytes.
+func (c *copier) applyObjectLock() error {
+	l := c.in.ObjectLock
+	if l == nil {
+		return nil
+	}
+	if err := l.Validate(); err != nil {
+		return fmt.Errorf("object lock: %s", err)
+	}
+
+	if l.FromSource {
+		if err := c.sourceObjectLock(); err != nil {
+			return err
+		}
+	}
+	if l.Mode != "" {
+		c.in.COI.ObjectLockMode = aws.String(l.Mode)
+		c.in.COI.ObjectLockRetainUntilDate = aws.Time(l.RetainUntil)
+	}
+	if l.LegalHold {
+		c.in.COI.ObjectLockLegalHoldStatus = aws.String(s3.ObjectLockLegalHoldStatusOn)
+	}
+
+	if c.in.COI.ObjectLockMode == nil && c.in.COI.ObjectLockLegalHoldStatus == nil {
+		return nil
+	}
+	return c.checkObjectLockEnabled()
+}
+
+// sourceObjectLock sets the source's retention and legal hold on the input.
+// HEAD returns them if the caller may read them; otherwise they are
+// requested directly.
+func (c *copier) sourceObjectLock() error {
+	info, err := c.sourceHead()
+	if err != nil {
+		return err
+	}
+	if info.ObjectLockMode != nil || info.ObjectLockLegalHoldStatus != nil {
+		c.sourceRetention(info.ObjectLockMode, info.ObjectLockRetainUntilDate)
+		c.in.COI.ObjectLockLegalHoldStatus = info.ObjectLockLegalHoldStatus
+		return nil
+	}
+
+	source, err := c.sourceLocation()
+	if err != nil {
+		return err
+	}
+
+	ctx, opts, done := c.startCall("GetObjectRetention")
+	ret, err := c.cfg.SrcS3.GetObjectRetentionWithContext(ctx, &s3.GetObjectRetentionInput{
+		Bucket:              aws.String(source.Bucket),
+		ExpectedBucketOwner: c.in.COI.ExpectedSourceBucketOwner,
+		Key:                 aws.String(source.Key),
+		RequestPayer:        c.in.SourceRequestPayer,
+		VersionId:           optional(source.VersionID),
+	}, opts...)
+	done(err)
+	switch {
+	case noObjectLock(err):
+	case err != nil:
+		return fmt.Errorf("error getting source retention: %s", err)
+	case ret.Retention != nil:
+		c.sourceRetention(ret.Retention.Mode, ret.Retention.RetainUntilDate)
+	}
+
+	ctx, opts, done = c.startCall("GetObjectLegalHold")
+	hold, err := c.cfg.SrcS3.GetObjectLegalHoldWithContext(ctx, &s3.GetObjectLegalHoldInput{
+		Bucket:              aws.String(source.Bucket),
+		ExpectedBucketOwner: c.in.COI.ExpectedSourceBucketOwner,
+		Key:                 aws.String(source.Key),
+		RequestPayer:        c.in.SourceRequestPayer,
+		VersionId:           optional(source.VersionID),
+	}, opts...)
+	done(err)
+	switch {
+	case noObjectLock(err):
+	case err != nil:
+		return fmt.Errorf("error getting source legal hold: %s", err)
+	case hold.LegalHold != nil:
+		c.in.COI.ObjectLockLegalHoldStatus = hold.LegalHold.Status
+	}
+
+	return nil
+}
+
+// sourceRetention sets the source's retention on the input if it hasn't
+// expired. S3 refuses a retain until date in the past, and the source is no
+// longer retained anyway.
+func (c *copier) sourceRetention(mode *string, until *time.Time) {
+	if until == nil || !until.After(time.Now()) {
+		return
+	}
+	c.in.COI.ObjectLockMode = mode
+	c.in.COI.ObjectLockRetainUntilDate = until
+}
+
+// checkObjectLockEnabled returns an error unless the destination bucket has
+// Object Lock enabled.
+func (c *copier) checkObjectLockEnabled() error {
+	ctx, opts, done := c.startCall("GetObjectLockConfiguration")
+	out, err := c.cfg.S3.GetObjectLockConfigurationWithContext(ctx, &s3.GetObjectLockConfigurationInput{
+		Bucket:              c.in.COI.Bucket,
+		ExpectedBucketOwner: c.in.COI.ExpectedBucketOwner,
+	}, opts...)
+	done(err)
+
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "ObjectLockConfigurationNotFoundError" {
+		err = nil
+		out = &s3.GetObjectLockConfigurationOutput{}
+	}
+	if err != nil {
+		return fmt.Errorf("error checking object lock of %s: %s", aws.StringValue(c.in.COI.Bucket), err)
+	}
+	if out.ObjectLockConfiguration == nil ||
+		aws.StringValue(out.ObjectLockConfiguration.ObjectLockEnabled) != s3.ObjectLockEnabledEnabled {
+		return fmt.Errorf("destination bucket %s does not have Object Lock enabled", aws.StringValue(c.in.COI.Bucket))
+	}
+	return nil
+}
+
+// noObjectLock reports whether err says the object has no lock setting, or
+// its bucket has no Object Lock at all. Other invalid requests are errors.
+func noObjectLock(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	if !ok {
+		return false
+	}
+	switch aerr.Code() {
+	case "NoSuchObjectLockConfiguration":
+		return true
+	case "InvalidRequest":
+		return aerr.Message() == "Bucket is missing Object Lock Configuration"
+	}
+	return false
+}
@@ -0,0 +1,238 @@
+package s3cp_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/reedobrien/checkers"
+	s3cp "github.com/reedobrien/s3cp/lib"
+	"github.com/reedobrien/s3cp/lib/dummy"
+)
+
+var retainUntil = time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func TestObjectLockValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		lock s3cp.ObjectLock
+		err  string
+	}{
+		{name: "none"},
+		{name: "legal hold", lock: s3cp.ObjectLock{LegalHold: true}},
+		{name: "governance", lock: s3cp.ObjectLock{Mode: s3.ObjectLockModeGovernance, RetainUntil: retainUntil}},
+		{
+			name: "mode without date",
+			lock: s3cp.ObjectLock{Mode: s3.ObjectLockModeCompliance},
+			err:  "retention mode COMPLIANCE requires a retain until date",
+		},
+		{
+			name: "date without mode",
+			lock: s3cp.ObjectLock{RetainUntil: retainUntil},
+			err:  "a retain until date requires a retention mode",
+		},
+		{
+			name: "unknown mode",
+			lock: s3cp.ObjectLock{Mode: "FOREVER", RetainUntil: retainUntil},
+			err:  `unknown retention mode "FOREVER"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.lock.Validate()
+			if tc.err == "" {
+				checkers.OK(t, err)
+				return
+			}
+			checkers.Equals(t, err.Error(), tc.err)
+		})
+	}
+}
+
+// lockedBuckets holds src/key with a compliance retention and a legal
+// hold.
+var lockedBuckets = dummy.Buckets{
+	"src": {"key": {
+		Data:                      thirtyBytes,
+		ObjectLockMode:            s3.ObjectLockModeCompliance,
+		ObjectLockRetainUntilDate: aws.Time(retainUntil),
+		ObjectLockLegalHold:       s3.ObjectLockLegalHoldStatusOn,
+	}},
+	"dst": nil,
+}
+
+// lockHidingHead hides Object Lock settings from HEAD, as S3 does without
+// s3:GetObjectRetention and s3:GetObjectLegalHold.
+type lockHidingHead struct {
+	*dummy.Fake
+}
+
+func (l lockHidingHead) HeadObjectWithContext(ctx aws.Context, in *s3.HeadObjectInput, opts ...request.Option) (*s3.HeadObjectOutput, error) {
+	out, err := l.Fake.HeadObjectWithContext(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+	out.ObjectLockMode, out.ObjectLockRetainUntilDate, out.ObjectLockLegalHoldStatus = nil, nil, nil
+	return out, nil
+}
+
+// invalidRetention refuses to return the source's retention.
+type invalidRetention struct {
+	lockHidingHead
+}
+
+func (invalidRetention) GetObjectRetentionWithContext(aws.Context, *s3.GetObjectRetentionInput, ...request.Option) (*s3.GetObjectRetentionOutput, error) {
+	return nil, awserr.New("InvalidRequest", "Invalid version id specified", nil)
+}
+
+func TestCopyObjectLock(t *testing.T) {
+	later := retainUntil.AddDate(1, 0, 0)
+	for _, tc := range []struct {
+		name       string
+		api        func(*dummy.Fake) s3cp.API
+		lock       s3cp.ObjectLock
+		expired    bool
+		mode       string
+		until      time.Time
+		retentions int
+	}{
+		{
+			name:  "from source multipart",
+			lock:  s3cp.ObjectLock{FromSource: true},
+			mode:  s3.ObjectLockModeCompliance,
+			until: retainUntil,
+		},
+		{
+			name:       "from source retention",
+			api:        func(f *dummy.Fake) s3cp.API { return lockHidingHead{f} },
+			lock:       s3cp.ObjectLock{FromSource: true},
+			mode:       s3.ObjectLockModeCompliance,
+			until:      retainUntil,
+			retentions: 1,
+		},
+		{
+			name:    "expired source multipart",
+			lock:    s3cp.ObjectLock{FromSource: true},
+			expired: true,
+		},
+		{
+			name:       "expired source retention",
+			api:        func(f *dummy.Fake) s3cp.API { return lockHidingHead{f} },
+			lock:       s3cp.ObjectLock{FromSource: true},
+			expired:    true,
+			retentions: 1,
+		},
+		{
+			name:  "explicit",
+			lock:  s3cp.ObjectLock{FromSource: true, Mode: s3.ObjectLockModeGovernance, RetainUntil: later},
+			mode:  s3.ObjectLockModeGovernance,
+			until: later,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := dummy.NewFakeWith(lockedBuckets)
+			fake.EnableObjectLock("src")
+			fake.EnableObjectLock("dst")
+			if tc.expired {
+				// A retain until date in the past isn't copied.
+				o := fake.Object("src", "key")
+				o.ObjectLockRetainUntilDate = aws.Time(time.Now().Add(-time.Hour))
+				fake.PutObject("src", "key", o)
+			}
+			var api s3cp.API = fake
+			if tc.api != nil {
+				api = tc.api(fake)
+			}
+			tut := s3cp.NewCopier(api, func(c *s3cp.Copier) { c.PartSize = 10 })
+
+			lock := tc.lock
+			err := tut.Copy(s3cp.CopyInput{
+				ObjectLock: &lock,
+				COI: s3.CopyObjectInput{
+					Bucket:     aws.String("dst"),
+					CopySource: aws.String("src/key"),
+					Key:        aws.String("key"),
+				},
+			})
+			checkers.OK(t, err)
+
+			got := fake.Object("dst", "key")
+			checkers.Equals(t, got.Data, thirtyBytes)
+			checkers.Equals(t, got.ObjectLockMode, tc.mode)
+			checkers.Equals(t, aws.TimeValue(got.ObjectLockRetainUntilDate), tc.until)
+			checkers.Equals(t, got.ObjectLockLegalHold, s3.ObjectLockLegalHoldStatusOn)
+			checkers.Equals(t, fake.Calls("GetObjectRetention"), tc.retentions)
+			checkers.Equals(t, fake.Calls("GetObjectLegalHold"), tc.retentions)
+		})
+	}
+}
+
+func TestCopyObjectLockErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		api    func(*dummy.Fake) s3cp.API
+		bucket string
+		lock   s3cp.ObjectLock
+		err    string
+	}{
+		{
+			name:   "source retention",
+			api:    func(f *dummy.Fake) s3cp.API { return invalidRetention{lockHidingHead{f}} },
+			bucket: "dst",
+			lock:   s3cp.ObjectLock{FromSource: true},
+			err:    "error getting source retention: InvalidRequest: Invalid version id specified",
+		},
+		{
+			name:   "destination not enabled",
+			bucket: "plain",
+			lock:   s3cp.ObjectLock{LegalHold: true},
+			err:    "destination bucket plain does not have Object Lock enabled",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := dummy.NewFakeWith(lockedBuckets)
+			fake.EnableObjectLock("src")
+			fake.EnableObjectLock("dst")
+			fake.CreateBucket("plain")
+			var api s3cp.API = fake
+			if tc.api != nil {
+				api = tc.api(fake)
+			}
+			tut := s3cp.NewCopier(api, func(c *s3cp.Copier) { c.PartSize = 10 })
+
+			lock := tc.lock
+			err := tut.Copy(s3cp.CopyInput{
+				ObjectLock: &lock,
+				COI: s3.CopyObjectInput{
+					Bucket:     aws.String(tc.bucket),
+					CopySource: aws.String("src/key"),
+					Key:        aws.String("key"),
+				},
+			})
+			checkers.Assert(t, err != nil, "expected an error")
+			checkers.Equals(t, err.Error(), tc.err)
+			checkers.Assert(t, fake.Object(tc.bucket, "key") == nil, "expected no copy")
+			checkers.Equals(t, fake.Calls("CreateMultipartUpload"), 0)
+		})
+	}
+}
+
+func TestCopyObjectLockFromUnlockedSource(t *testing.T) {
+	fake := dummy.NewFakeWith(dummy.Buckets{"src": {"key": {Data: []byte("data")}}, "dst": nil})
+	tut := s3cp.NewCopier(fake)
+
+	err := tut.Copy(s3cp.CopyInput{
+		ObjectLock: &s3cp.ObjectLock{FromSource: true},
+		COI: s3.CopyObjectInput{
+			Bucket:     aws.String("dst"),
+			CopySource: aws.String("src/key"),
+			Key:        aws.String("key"),
+		},
+	})
+	checkers.OK(t, err)
+	checkers.Equals(t, fake.Object("dst", "key").ObjectLockMode, "")
+	checkers.Equals(t, fake.Calls("GetObjectLockConfiguration"), 0)
+}
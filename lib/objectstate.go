@@ -0,0 +1,187 @@
+package s3cp
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// objectState is the state of an object that a copy onto itself must carry
+// over. S3 only preserves the data and, with the COPY directives, metadata
+// and tags; the rest has to be read first and set again.
+type objectState struct {
+	bucket, key, owner string
+
+	head *s3.HeadObjectOutput
+	tags []*s3.Tag
+	acl  *s3.GetObjectAclOutput
+}
+
+// objectState reads the metadata, tags and ACL of bucket/key. owner, if set,
+// is the account expected to own the bucket and src provides the SSE-C key if
+// the object has one.
+func (c Copier) objectState(ctx aws.Context, bucket, key, owner string, src *Encryption) (*objectState, error) {
+	hoi := &s3.HeadObjectInput{
+		Bucket:              aws.String(bucket),
+		ExpectedBucketOwner: optional(owner),
+		Key:                 aws.String(key),
+	}
+	if src != nil && src.Type == SSEC {
+		hoi.SSECustomerAlgorithm, hoi.SSECustomerKey, hoi.SSECustomerKeyMD5 = src.customerKey()
+	}
+
+	head, err := c.S3.HeadObjectWithContext(ctx, hoi, c.RequestOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := c.S3.GetObjectTaggingWithContext(ctx, &s3.GetObjectTaggingInput{
+		Bucket:              aws.String(bucket),
+		ExpectedBucketOwner: optional(owner),
+		Key:                 aws.String(key),
+	}, c.RequestOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	acl, err := c.S3.GetObjectAclWithContext(ctx, &s3.GetObjectAclInput{
+		Bucket:              aws.String(bucket),
+		ExpectedBucketOwner: optional(owner),
+		Key:                 aws.String(key),
+	}, c.RequestOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &objectState{
+		bucket: bucket,
+		key:    key,
+		owner:  owner,
+		head:   head,
+		tags:   tags.TagSet,
+		acl:    acl,
+	}, nil
+}
+
+// copyInput returns a CopyInput copying the object onto itself with its
+// metadata, tags and Object Lock settings replaced by their current values,
+// so that both the single part and multipart paths keep them. Its grants are
+// written with the copy too, unless they can't be, when the ACL is nil and
+// restoreACL must put them back. The copy is
+// conditional on the ETag so an object changed since it was read is not
+// clobbered.
+func (s *objectState) copyInput() CopyInput {
+	h := s.head
+	coi := s3.CopyObjectInput{
+		Bucket:                    aws.String(s.bucket),
+		CacheControl:              h.CacheControl,
+		ContentDisposition:        h.ContentDisposition,
+		ContentEncoding:           h.ContentEncoding,
+		ContentLanguage:           h.ContentLanguage,
+		ContentType:               h.ContentType,
+		CopySource:                aws.String(Location{Bucket: s.bucket, Key: s.key}.CopySource()),
+		CopySourceIfMatch:         h.ETag,
+		ExpectedBucketOwner:       optional(s.owner),
+		ExpectedSourceBucketOwner: optional(s.owner),
+		Key:                       aws.String(s.key),
+		Metadata:                  h.Metadata,
+		MetadataDirective:         aws.String(s3.MetadataDirectiveReplace),
+		ObjectLockLegalHoldStatus: h.ObjectLockLegalHoldStatus,
+		ObjectLockMode:            h.ObjectLockMode,
+		ObjectLockRetainUntilDate: h.ObjectLockRetainUntilDate,
+		StorageClass:              h.StorageClass,
+		TaggingDirective:          aws.String(s3.TaggingDirectiveReplace),
+		WebsiteRedirectLocation:   h.WebsiteRedirectLocation,
+	}
+	if h.Expires != nil {
+		if t, err := http.ParseTime(*h.Expires); err == nil {
+			coi.Expires = aws.Time(t)
+		}
+	}
+	if len(s.tags) > 0 {
+		coi.Tagging = aws.String(encodeTags(s.tags))
+	}
+
+	ci := CopyInput{
+		Size: aws.Int64Value(h.ContentLength),
+		COI:  coi,
+	}
+	if acl, ok := grantsACL(s.acl.Grants); ok {
+		ci.ACL = acl
+	}
+	return ci
+}
+
+// encryption returns the object's current encryption, so a copy can keep it.
+// src provides the SSE-C key if the object has one.
+func (s *objectState) encryption(src *Encryption) *Encryption {
+	switch aws.StringValue(s.head.ServerSideEncryption) {
+	case SSES3:
+		return &Encryption{Type: SSES3}
+	case SSEKMS:
+		return &Encryption{
+			Type:      SSEKMS,
+			KMSKeyID:  aws.StringValue(s.head.SSEKMSKeyId),
+			BucketKey: aws.BoolValue(s.head.BucketKeyEnabled),
+		}
+	}
+	if s.head.SSECustomerAlgorithm != nil {
+		return src
+	}
+	return nil
+}
+
+// restoreACL puts back the ACL read by objectState, for a copy that couldn't
+// write its grants and so left it private. If that fails the error lists the
+// grants, so they can be put back by hand.
+func (c Copier) restoreACL(ctx aws.Context, s *objectState) error {
+	_, err := c.S3.PutObjectAclWithContext(ctx, &s3.PutObjectAclInput{
+		AccessControlPolicy: &s3.AccessControlPolicy{
+			Grants: s.acl.Grants,
+			Owner:  s.acl.Owner,
+		},
+		Bucket:              aws.String(s.bucket),
+		ExpectedBucketOwner: optional(s.owner),
+		Key:                 aws.String(s.key),
+	}, c.RequestOptions...)
+	if err != nil {
+		return fmt.Errorf("error restoring the ACL of %s/%s, which had %s: %s", s.bucket, s.key, describeGrants(s.acl.Grants), err)
+	}
+	return nil
+}
+
+// describeGrants lists grants as grantee permission pairs, e.g.
+// id=abc123:READ.
+func describeGrants(grants []*s3.Grant) string {
+	var ds []string
+	for _, g := range grants {
+		var grantee string
+		switch {
+		case g.Grantee == nil:
+		case g.Grantee.ID != nil:
+			grantee = "id=" + *g.Grantee.ID
+		case g.Grantee.URI != nil:
+			grantee = "uri=" + *g.Grantee.URI
+		case g.Grantee.EmailAddress != nil:
+			grantee = "emailAddress=" + *g.Grantee.EmailAddress
+		}
+		ds = append(ds, grantee+":"+aws.StringValue(g.Permission))
+	}
+	if len(ds) == 0 {
+		return "no grants"
+	}
+	return "grants " + strings.Join(ds, ", ")
+}
+
+// encodeTags encodes tags as the query string the Tagging header expects.
+func encodeTags(tags []*s3.Tag) string {
+	v := url.Values{}
+	for _, t := range tags {
+		v.Set(aws.StringValue(t.Key), aws.StringValue(t.Value))
+	}
+	return v.Encode()
+}
@@ -0,0 +1,101 @@
+package s3cp
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// sourceParts returns the sizes of the parts the source was uploaded in, or
+// nil if it wasn't uploaded in parts or they can't be copied as they are. The
+// first part is HEADed for the part count and then the rest for their sizes,
+// Concurrency at a time.
+func (c *copier) sourceParts() []int64 {
+	first, err := c.headPart(1)
+	if err != nil {
+		c.logger().Warn("can't preserve the source's parts", c.logAttrs(errAttrs(err)...)...)
+		return nil
+	}
+	count := int(aws.Int64Value(first.PartsCount))
+	if count == 0 {
+		return nil
+	}
+
+	sizes := make([]int64, count)
+	sizes[0] = aws.Int64Value(first.ContentLength)
+
+	var (
+		wg   sync.WaitGroup
+		once sync.Once
+		herr error
+	)
+	nums := make(chan int)
+	workers := c.cfg.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for n := range nums {
+				out, err := c.headPart(n)
+				if err != nil {
+					once.Do(func() { herr = err })
+					continue
+				}
+				sizes[n-1] = aws.Int64Value(out.ContentLength)
+			}
+		}()
+	}
+	for n := 2; n <= count; n++ {
+		nums <- n
+	}
+	close(nums)
+	wg.Wait()
+
+	if herr == nil {
+		herr = checkParts(sizes, *c.contentLength)
+	}
+	if herr != nil {
+		c.logger().Warn("can't preserve the source's parts", c.logAttrs(errAttrs(herr)...)...)
+		return nil
+	}
+	return sizes
+}
+
+// checkParts returns an error if parts of sizes can't make an object of
+// size bytes.
+func checkParts(sizes []int64, size int64) error {
+	var total int64
+	for i, n := range sizes {
+		if n > MaxCopyObjectSize || (i < len(sizes)-1 && n < MinPartSize) {
+			return fmt.Errorf("part %d of %d bytes is out of bounds", i+1, n)
+		}
+		total += n
+	}
+	if total != size {
+		return fmt.Errorf("the parts add up to %d bytes, not %d", total, size)
+	}
+	return nil
+}
+
+// headPart HEADs part n of the source.
+func (c *copier) headPart(n int) (*s3.HeadObjectOutput, error) {
+	source, err := c.sourceLocation()
+	if err != nil {
+		return nil, err
+	}
+	in := c.headInput(source)
+	in.PartNumber = aws.Int64(int64(n))
+
+	ctx, opts, done := c.startCall("HeadObject", attrPartNumber.Int64(int64(n)))
+	out, err := c.cfg.SrcS3.HeadObjectWithContext(ctx, in, opts...)
+	done(err)
+	if err != nil {
+		return nil, fmt.Errorf("error getting part %d info: %s", n, err)
+	}
+	return out, nil
+}
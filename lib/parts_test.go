@@ -0,0 +1,106 @@
+package s3cp_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/reedobrien/checkers"
+	s3cp "github.com/reedobrien/s3cp/lib"
+	"github.com/reedobrien/s3cp/lib/dummy"
+)
+
+// partsInput copies src/big to dst/copy.
+var partsInput = s3cp.CopyInput{
+	COI: s3.CopyObjectInput{
+		Bucket:     aws.String("dst"),
+		Key:        aws.String("copy"),
+		CopySource: aws.String("src/big"),
+	},
+}
+
+// newPartsFake stores src/big uploaded in parts of sizes.
+func newPartsFake(sizes ...int64) *dummy.Fake {
+	var total int64
+	for _, n := range sizes {
+		total += n
+	}
+	fake := dummy.NewFakeWith(dummy.Buckets{
+		"src": {"big": {Data: bytes.Repeat([]byte("x"), int(total)), PartSizes: sizes}},
+		"dst": nil,
+	})
+	fake.MinPartSize = s3cp.MinPartSize
+	return fake
+}
+
+func TestCopyPreserveParts(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		sizes       []int64
+		preserve    bool
+		sameETag    bool
+		copyObjects int
+		partCopies  int
+	}{
+		{
+			name:       "preserved",
+			sizes:      []int64{6 * mib, 5*mib + 3, 100},
+			preserve:   true,
+			sameETag:   true,
+			partCopies: 3,
+		},
+		{
+			name:        "not preserved",
+			sizes:       []int64{6 * mib, 5*mib + 3, 100},
+			copyObjects: 1,
+		},
+		{
+			name:        "single part",
+			preserve:    true,
+			sameETag:    true,
+			copyObjects: 1,
+		},
+		{
+			name:        "small middle part",
+			sizes:       []int64{6 * mib, 10, 6 * mib},
+			preserve:    true,
+			copyObjects: 1,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := newPartsFake(tc.sizes...)
+			if tc.sizes == nil {
+				fake.PutObject("src", "big", &dummy.Object{Data: []byte("small")})
+			}
+			tut := s3cp.NewCopier(fake)
+
+			in := partsInput
+			in.PreserveParts = tc.preserve
+			checkers.OK(t, tut.Copy(in))
+
+			got, src := fake.Object("dst", "copy"), fake.Object("src", "big")
+			checkers.Equals(t, got.Data, src.Data)
+			checkers.Equals(t, got.ETag == src.ETag, tc.sameETag)
+			checkers.Equals(t, fake.Calls("CopyObject"), tc.copyObjects)
+			checkers.Equals(t, fake.Calls("UploadPartCopy"), tc.partCopies)
+			if tc.partCopies > 0 {
+				checkers.Equals(t, got.PartSizes, tc.sizes)
+				// The size, then each part.
+				checkers.Equals(t, fake.Calls("HeadObject"), 1+tc.partCopies)
+			}
+		})
+	}
+}
+
+func TestPlanPreserveParts(t *testing.T) {
+	fake := newPartsFake(6*mib, 5*mib+3, 100)
+	tut := s3cp.NewCopier(fake)
+
+	in := partsInput
+	in.PreserveParts = true
+	p, err := tut.Plan(context.Background(), in)
+	checkers.OK(t, err)
+	checkers.Equals(t, p.Parts, 3)
+}
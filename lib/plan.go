@@ -0,0 +1,214 @@
+package s3cp
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Plan describes what a copy will do, without doing it.
+type Plan struct {
+	// The copy source and the destination bucket/key.
+	Source      string
+	Destination string
+
+	// The size of the source object.
+	Size int64
+
+	// Parts is the number of parts of a multipart copy, or 0 if the object
+	// is copied with a single CopyObject.
+	Parts int
+
+	// Warnings are worth reading before running the copy, e.g. that it is
+	// billed to us.
+	Warnings []string
+}
+
+func (p *Plan) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "copy %s to %s\n", p.Source, p.Destination)
+	if p.Parts == 0 {
+		fmt.Fprintf(&b, "  %d bytes in one CopyObject\n", p.Size)
+	} else {
+		fmt.Fprintf(&b, "  %d bytes in %d parts\n", p.Size, p.Parts)
+	}
+	for _, w := range p.Warnings {
+		fmt.Fprintf(&b, "  warning: %s\n", w)
+	}
+	return b.String()
+}
+
+// Plan returns the Plan for copying input. The source is HEADed unless
+// input.Size is set.
+func (c Copier) Plan(ctx aws.Context, input CopyInput) (*Plan, error) {
+	impl := copier{in: input, cfg: c, ctx: ctx}
+
+	impl.applyRequestPayer()
+	if err := impl.canonicalCopySource(); err != nil {
+		return nil, err
+	}
+	source, err := impl.sourceLocation()
+	if err != nil {
+		return nil, err
+	}
+	if err := impl.resolveRegions(source); err != nil {
+		return nil, err
+	}
+	if err := impl.applyEncryption(); err != nil {
+		return nil, err
+	}
+	impl.getContentLength()
+	if err := impl.getErr(); err != nil {
+		return nil, err
+	}
+
+	p := &Plan{
+		Source:      aws.StringValue(input.COI.CopySource),
+		Destination: aws.StringValue(input.COI.Bucket) + "/" + aws.StringValue(input.COI.Key),
+		Size:        *impl.contentLength,
+		Warnings:    costWarnings(input, source),
+	}
+	if input.SourceRange != nil {
+		rng, err := input.SourceRange.resolve(p.Size)
+		if err != nil {
+			return nil, err
+		}
+		p.Source += " " + rng.header()
+		p.Size = rng.end - rng.start
+	}
+	if p.Size >= c.PartSize || p.Size > MaxCopyObjectSize || input.SourceRange != nil {
+		p.Parts = int(math.Ceil(float64(p.Size) / float64(c.PartSize)))
+	}
+	if input.PreserveParts && input.SourceRange == nil {
+		if sizes := impl.sourceParts(); sizes != nil {
+			p.Parts = len(sizes)
+		}
+	}
+	return p, nil
+}
+
+// KeyMapping is an object of a prefix copy and the key it is copied to.
+type KeyMapping struct {
+	Source      string
+	Destination string
+	Size        int64
+}
+
+// PrefixPlan describes what a prefix copy will do, without doing it.
+type PrefixPlan struct {
+	// The source and destination prefixes.
+	Source      string
+	Destination string
+
+	// Copies are the objects selected by the Filter, in listing order.
+	Copies []KeyMapping
+
+	// Size is the total size of the Copies.
+	Size int64
+
+	// Filtered counts the objects left out by the Filter.
+	Filtered int
+
+	// Errors are the objects that would fail, e.g. because their key
+	// collides with another's.
+	Errors []string
+
+	// Warnings are worth reading before running the copy.
+	Warnings []string
+}
+
+func (p *PrefixPlan) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "copy %s to %s\n", p.Source, p.Destination)
+	for _, m := range p.Copies {
+		fmt.Fprintf(&b, "  %s -> %s (%d bytes)\n", m.Source, m.Destination, m.Size)
+	}
+	fmt.Fprintf(&b, "  %d objects, %d bytes; %d filtered\n", len(p.Copies), p.Size, p.Filtered)
+	for _, e := range p.Errors {
+		fmt.Fprintf(&b, "  error: %s\n", e)
+	}
+	for _, w := range p.Warnings {
+		fmt.Fprintf(&b, "  warning: %s\n", w)
+	}
+	return b.String()
+}
+
+// PlanPrefix returns the PrefixPlan for copying input. It lists the source,
+// and fetches tags if the Filter checks them, but copies nothing.
+func (c Copier) PlanPrefix(ctx aws.Context, input CopyPrefixInput) (*PrefixPlan, error) {
+	if err := input.validate(); err != nil {
+		return nil, err
+	}
+	f, err := input.Filter.compile()
+	if err != nil {
+		return nil, err
+	}
+	m, err := input.Mapper.compile()
+	if err != nil {
+		return nil, err
+	}
+	lister, err := c.sourceLister(ctx, input.Source, input.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	ci := input.Template
+	ci.COI.Bucket = aws.String(input.Destination.Bucket)
+	p := &PrefixPlan{
+		Source:      input.Source.String(),
+		Destination: input.Destination.String(),
+		Warnings:    costWarnings(ci, input.Source),
+	}
+
+	src, dsts := input.Source, newDestinations(input, m)
+	var tagErr error
+	err = lister.listObjects(ctx, src.Bucket, src.Key, aws.StringValue(ci.COI.ExpectedSourceBucketOwner), ci.SourceRequestPayer, func(o *s3.Object) bool {
+		key := aws.StringValue(o.Key)
+		if !f.listed(src.Key, o) {
+			p.Filtered++
+			return true
+		}
+		tagged, err := lister.sourceTagged(ctx, input, f, key)
+		if err != nil {
+			tagErr = err
+			return false
+		}
+		if !tagged {
+			p.Filtered++
+			return true
+		}
+
+		dst, err := dsts.claim(o)
+		if err != nil {
+			p.Errors = append(p.Errors, err.Error())
+			return true
+		}
+		p.Copies = append(p.Copies, KeyMapping{Source: key, Destination: dst, Size: aws.Int64Value(o.Size)})
+		p.Size += aws.Int64Value(o.Size)
+		return true
+	})
+	if err == nil {
+		err = tagErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error listing %s: %s", src, err)
+	}
+	return p, nil
+}
+
+// costWarnings warns of the requester pays buckets a copy is billed for.
+func costWarnings(in CopyInput, source Location) []string {
+	var warnings []string
+	if aws.StringValue(in.SourceRequestPayer) != "" {
+		warnings = append(warnings, fmt.Sprintf(
+			"source bucket %s is requester pays: its requests and data transfer out are billed to us", source.Bucket))
+	}
+	if aws.StringValue(in.COI.RequestPayer) != "" {
+		warnings = append(warnings, fmt.Sprintf(
+			"destination bucket %s is requester pays: its requests are billed to us", aws.StringValue(in.COI.Bucket)))
+	}
+	return warnings
+}
@@ -0,0 +1,96 @@
+package s3cp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SourceRange selects the part of the source a copy holds: Length bytes from
+// Offset, or to the end if Length is 0, or, if Header is set, the bytes of an
+// HTTP Range header such as bytes=0-1023, bytes=1024- or bytes=-512. A range
+// running past the end of the source is cut short at it.
+type SourceRange struct {
+	Offset int64
+	Length int64
+	Header string
+}
+
+func (r *SourceRange) String() string {
+	switch {
+	case r.Header != "":
+		return r.Header
+	case r.Length > 0:
+		return fmt.Sprintf("bytes=%d-%d", r.Offset, r.Offset+r.Length-1)
+	}
+	return fmt.Sprintf("bytes=%d-", r.Offset)
+}
+
+// Validate checks the range is well formed.
+func (r *SourceRange) Validate() error {
+	_, _, err := r.parse()
+	return err
+}
+
+// parse returns the range's start and length. A negative start is the
+// length of a suffix, and a length of 0 runs to the end.
+func (r *SourceRange) parse() (int64, int64, error) {
+	if r.Header == "" {
+		if r.Offset < 0 || r.Length < 0 {
+			return 0, 0, fmt.Errorf("range offset and length can't be negative")
+		}
+		return r.Offset, r.Length, nil
+	}
+	if r.Offset != 0 || r.Length != 0 {
+		return 0, 0, fmt.Errorf("range %q can't also have an offset or length", r.Header)
+	}
+
+	spec, ok := strings.CutPrefix(r.Header, "bytes=")
+	first, last, dash := strings.Cut(spec, "-")
+	if !ok || !dash || strings.Contains(spec, ",") {
+		return 0, 0, fmt.Errorf("invalid range %q, want bytes=first-last, bytes=first- or bytes=-suffix", r.Header)
+	}
+	if first == "" {
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, fmt.Errorf("invalid range %q suffix", r.Header)
+		}
+		return -n, 0, nil
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, fmt.Errorf("invalid range %q start", r.Header)
+	}
+	if last == "" {
+		return start, 0, nil
+	}
+	end, err := strconv.ParseInt(last, 10, 64)
+	if err != nil || end < start {
+		return 0, 0, fmt.Errorf("invalid range %q end", r.Header)
+	}
+	return start, end - start + 1, nil
+}
+
+// resolve returns the bytes of a source of size the range selects.
+func (r *SourceRange) resolve(size int64) (byteRange, error) {
+	start, length, err := r.parse()
+	if err != nil {
+		return byteRange{}, err
+	}
+	if start < 0 {
+		start += size
+		if start < 0 {
+			start = 0
+		}
+	}
+	if start >= size {
+		return byteRange{}, fmt.Errorf("range %s starts past the end of the %d byte source", r, size)
+	}
+
+	end := size
+	if length > 0 && start+length < size {
+		end = start + length
+	}
+	return byteRange{start: start, end: end}, nil
+}
@@ -0,0 +1,82 @@
+package s3cp_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/reedobrien/checkers"
+	s3cp "github.com/reedobrien/s3cp/lib"
+)
+
+func TestCopySourceRange(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 10)
+	for _, tc := range []struct {
+		name   string
+		rng    s3cp.SourceRange
+		delete bool
+		want   []byte
+		parts  int
+		err    string
+	}{
+		{name: "offset and length", rng: s3cp.SourceRange{Offset: 25, Length: 30}, want: data[25:55], parts: 3},
+		{name: "offset to end", rng: s3cp.SourceRange{Offset: 90}, want: data[90:], parts: 1},
+		{name: "small", rng: s3cp.SourceRange{Length: 3}, want: data[:3], parts: 1},
+		{name: "header", rng: s3cp.SourceRange{Header: "bytes=5-14"}, want: data[5:15], parts: 1},
+		{name: "header open", rng: s3cp.SourceRange{Header: "bytes=79-"}, want: data[79:], parts: 3},
+		{name: "suffix", rng: s3cp.SourceRange{Header: "bytes=-7"}, want: data[93:], parts: 1},
+		{name: "past end", rng: s3cp.SourceRange{Header: "bytes=95-200"}, want: data[95:], parts: 1},
+		{name: "suffix past start", rng: s3cp.SourceRange{Header: "bytes=-200"}, want: data, parts: 10},
+		{name: "bad header", rng: s3cp.SourceRange{Header: "bytes=1-2,4-5"}, err: `invalid range "bytes=1-2,4-5", want bytes=first-last, bytes=first- or bytes=-suffix`},
+		{name: "bad unit", rng: s3cp.SourceRange{Header: "items=1-2"}, err: `invalid range "items=1-2", want bytes=first-last, bytes=first- or bytes=-suffix`},
+		{name: "bad end", rng: s3cp.SourceRange{Header: "bytes=5-1"}, err: `invalid range "bytes=5-1" end`},
+		{name: "bad suffix", rng: s3cp.SourceRange{Header: "bytes=-0"}, err: `invalid range "bytes=-0" suffix`},
+		{name: "header and length", rng: s3cp.SourceRange{Header: "bytes=0-1", Length: 2}, err: `range "bytes=0-1" can't also have an offset or length`},
+		{name: "negative", rng: s3cp.SourceRange{Offset: -1}, err: "range offset and length can't be negative"},
+		{name: "offset past end", rng: s3cp.SourceRange{Offset: 100}, err: "range bytes=100- starts past the end of the 100 byte source"},
+		{name: "delete", rng: s3cp.SourceRange{Offset: 1}, delete: true, err: "can't delete the source of a range copy"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := newSplitFake(data)
+			tut := s3cp.NewCopier(fake, func(c *s3cp.Copier) { c.PartSize = 10 })
+
+			err := tut.Copy(s3cp.CopyInput{
+				Delete:      tc.delete,
+				SourceRange: &tc.rng,
+				COI: s3.CopyObjectInput{
+					Bucket:     aws.String("dst"),
+					Key:        aws.String("slice"),
+					CopySource: aws.String("src/big"),
+				},
+			})
+			if tc.err != "" {
+				checkers.Assert(t, err != nil, "expected an error")
+				checkers.Equals(t, err.Error(), tc.err)
+				checkers.Equals(t, fake.Calls("CreateMultipartUpload"), 0)
+				return
+			}
+			checkers.OK(t, err)
+			checkers.Equals(t, fake.Object("dst", "slice").Data, tc.want)
+			checkers.Equals(t, fake.Calls("UploadPartCopy"), tc.parts)
+			checkers.Equals(t, fake.Calls("CopyObject"), 0)
+		})
+	}
+}
+
+func TestPlanSourceRange(t *testing.T) {
+	fake := newSplitFake(bytes.Repeat([]byte("0123456789"), 10))
+	tut := s3cp.NewCopier(fake, func(c *s3cp.Copier) { c.PartSize = 10 })
+
+	p, err := tut.Plan(context.Background(), s3cp.CopyInput{
+		SourceRange: &s3cp.SourceRange{Header: "bytes=-25"},
+		COI: s3.CopyObjectInput{
+			Bucket:     aws.String("dst"),
+			Key:        aws.String("slice"),
+			CopySource: aws.String("src/big"),
+		},
+	})
+	checkers.OK(t, err)
+	checkers.Equals(t, p.String(), "copy src/big bytes=75-99 to dst/slice\n  25 bytes in 3 parts\n")
+}
@@ -0,0 +1,142 @@
+package s3cp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// ReencryptInput is a parameter container for Copier.Reencrypt.
+type ReencryptInput struct {
+	// The bucket holding the objects.
+	Bucket string
+
+	// Keys lists the objects to rewrite. If empty every object under Prefix
+	// is rewritten.
+	Keys []string
+
+	// Prefix selects the objects to rewrite when Keys is empty.
+	Prefix string
+
+	// Filter, if set, selects among the objects under Prefix. It is not
+	// used with Keys.
+	Filter *Filter
+
+	// Encryption is the new server side encryption.
+	Encryption Encryption
+
+	// SourceEncryption provides the objects' current SSE-C key, if they
+	// have one.
+	SourceEncryption *Encryption
+
+	// ExpectedBucketOwner, if set, is the account that must own the bucket.
+	ExpectedBucketOwner string
+
+	// How many objects to rewrite at once. Defaults to
+	// DefaultObjectConcurrency.
+	Concurrency int
+}
+
+// Reencrypt copies each object onto itself with the new Encryption, keeping
+// its metadata, tags and ACL. Objects already encrypted as requested are
+// skipped. progress, if not nil, is called with each object's result; calls
+// are serialized. An error is returned if the input is invalid, the listing
+// fails or any object fails.
+func (c Copier) Reencrypt(ctx aws.Context, in ReencryptInput, progress func(ObjectResult)) (Summary, error) {
+	var sum Summary
+
+	if in.Encryption.Type == "" {
+		return sum, errors.New("reencrypt requires an encryption type")
+	}
+	if err := in.Encryption.Validate(); err != nil {
+		return sum, fmt.Errorf("destination encryption: %s", err)
+	}
+
+	f, err := in.Filter.compile()
+	if err != nil {
+		return sum, err
+	}
+	if len(in.Keys) > 0 {
+		f = nil
+	}
+
+	c, err = c.bucketClient(ctx, in.Bucket, in.ExpectedBucketOwner)
+	if err != nil {
+		return sum, err
+	}
+
+	b := &bulk{progress: progress}
+	err = b.run(ctx, in.Concurrency, func(ctx aws.Context, out chan<- *s3.Object) error {
+		return c.produceObjects(ctx, b, in.Bucket, in.Prefix, in.ExpectedBucketOwner, nil, in.Keys, f, out)
+	}, func(ctx aws.Context, o *s3.Object) ObjectResult {
+		return c.reencrypt(ctx, in, f, aws.StringValue(o.Key))
+	})
+	sum = b.sum
+
+	if err != nil {
+		return sum, fmt.Errorf("error listing %s/%s: %s", in.Bucket, in.Prefix, err)
+	}
+	if sum.Failed > 0 {
+		return sum, fmt.Errorf("%d of %d objects failed", sum.Failed, sum.Copied+sum.Skipped+sum.Failed)
+	}
+	return sum, nil
+}
+
+// reencrypt rewrites a single object, if it has the tags f requires.
+func (c Copier) reencrypt(ctx aws.Context, in ReencryptInput, f *filter, key string) ObjectResult {
+	r := ObjectResult{Key: key}
+
+	state, err := c.objectState(ctx, in.Bucket, key, in.ExpectedBucketOwner, in.SourceEncryption)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+
+	if !f.tagged(state.tags) {
+		r.Filtered = true
+		return r
+	}
+
+	if encryptedWith(state.head, &in.Encryption) {
+		r.Skipped = true
+		c.logger().Debug("already encrypted", "bucket", in.Bucket, "key", key)
+		return r
+	}
+
+	ci := state.copyInput()
+	enc := in.Encryption
+	ci.Encryption = &enc
+	ci.SourceEncryption = in.SourceEncryption
+
+	if r.Err = c.CopyWithContext(ctx, ci); r.Err != nil || ci.ACL != nil {
+		return r
+	}
+	r.Err = c.restoreACL(ctx, state)
+	return r
+}
+
+// encryptedWith reports whether an object with the head is already encrypted
+// as e requests. An SSE-KMS object only matches an explicit KMSKeyID, given
+// as the key ID or ARN; HEAD can't tell an alias or the AWS managed key.
+func encryptedWith(head *s3.HeadObjectOutput, e *Encryption) bool {
+	switch e.Type {
+	case SSES3:
+		return aws.StringValue(head.ServerSideEncryption) == SSES3
+	case SSEKMS:
+		got := aws.StringValue(head.SSEKMSKeyId)
+		if aws.StringValue(head.ServerSideEncryption) != SSEKMS || e.KMSKeyID == "" || got == "" {
+			return false
+		}
+		if aws.BoolValue(head.BucketKeyEnabled) != e.BucketKey {
+			return false
+		}
+		return got == e.KMSKeyID || strings.HasSuffix(got, ":key/"+e.KMSKeyID)
+	case SSEC:
+		_, _, md5sum := e.customerKey()
+		return aws.StringValue(head.SSECustomerKeyMD5) == *md5sum
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package s3cp_test
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/reedobrien/checkers"
+	s3cp "github.com/reedobrien/s3cp/lib"
+	"github.com/reedobrien/s3cp/lib/dummy"
+)
+
+const newKeyARN = "arn:aws:kms:us-east-1:111122223333:key/new-key"
+
+var readGrant = &s3.Grant{
+	Grantee: &s3.Grantee{
+		ID:   aws.String("partner-id"),
+		Type: aws.String(s3.TypeCanonicalUser),
+	},
+	Permission: aws.String(s3.PermissionRead),
+}
+
+// reencryptBuckets holds objects under bucket/logs/ with and without the
+// new key, and one outside the prefix.
+var reencryptBuckets = dummy.Buckets{
+	"bucket": {
+		"logs/small": {
+			Data:        []byte("small"),
+			ContentType: "text/plain",
+			Metadata:    map[string]string{"owner": "ops"},
+			Tags:        map[string]string{"team": "sec"},
+			Grants:      []*s3.Grant{readGrant},
+		},
+		"logs/large": {
+			Data:                 []byte(strings.Repeat("0123456789", 3)),
+			ContentType:          "application/gzip",
+			Metadata:             map[string]string{"sha1": "abc"},
+			Tags:                 map[string]string{"team": "sec", "tier": "cold"},
+			ServerSideEncryption: s3cp.SSEKMS,
+			SSEKMSKeyID:          "arn:aws:kms:us-east-1:111122223333:key/old-key",
+			StorageClass:         s3.StorageClassStandardIa,
+		},
+		"logs/done": {
+			Data:                 []byte("done"),
+			ServerSideEncryption: s3cp.SSEKMS,
+			SSEKMSKeyID:          newKeyARN,
+		},
+		"other/untouched": {Data: []byte("other")},
+	},
+}
+
+func TestReencryptPrefix(t *testing.T) {
+	fake := dummy.NewFakeWith(reencryptBuckets)
+	tut := s3cp.NewCopier(fake,
+		func(c *s3cp.Copier) { c.PartSize = 10 },
+		func(c *s3cp.Copier) { c.Logger = dummy.NewLogger() },
+	)
+
+	var (
+		mu      sync.Mutex
+		results []s3cp.ObjectResult
+	)
+	sum, err := tut.Reencrypt(context.Background(), s3cp.ReencryptInput{
+		Bucket:     "bucket",
+		Prefix:     "logs/",
+		Encryption: s3cp.Encryption{Type: s3cp.SSEKMS, KMSKeyID: "new-key"},
+	}, func(r s3cp.ObjectResult) {
+		mu.Lock()
+		results = append(results, r)
+		mu.Unlock()
+	})
+	checkers.OK(t, err)
+	checkers.Equals(t, sum, s3cp.Summary{Copied: 2, Skipped: 1})
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Key < results[j].Key })
+	checkers.Equals(t, results, []s3cp.ObjectResult{
+		{Key: "logs/done", Skipped: true},
+		{Key: "logs/large"},
+		{Key: "logs/small"},
+	})
+
+	small := fake.Object("bucket", "logs/small")
+	checkers.Equals(t, small.Data, []byte("small"))
+	checkers.Equals(t, small.ServerSideEncryption, s3cp.SSEKMS)
+	checkers.Equals(t, small.SSEKMSKeyID, "new-key")
+	checkers.Equals(t, small.ContentType, "text/plain")
+	checkers.Equals(t, small.Metadata, map[string]string{"owner": "ops"})
+	checkers.Equals(t, small.Tags, map[string]string{"team": "sec"})
+	checkers.Equals(t, small.Grants, []*s3.Grant{readGrant})
+
+	// 30 bytes with a PartSize of 10 goes through the multipart path.
+	large := fake.Object("bucket", "logs/large")
+	checkers.Equals(t, large.Data, []byte(strings.Repeat("0123456789", 3)))
+	checkers.Assert(t, strings.HasSuffix(large.ETag, `-3"`), "expected a multipart ETag, got %s", large.ETag)
+	checkers.Equals(t, large.SSEKMSKeyID, "new-key")
+	checkers.Equals(t, large.ContentType, "application/gzip")
+	checkers.Equals(t, large.Metadata, map[string]string{"sha1": "abc"})
+	checkers.Equals(t, large.Tags, map[string]string{"team": "sec", "tier": "cold"})
+	checkers.Equals(t, large.StorageClass, s3.StorageClassStandardIa)
+	checkers.Equals(t, fake.Uploads(), 0)
+
+	checkers.Equals(t, fake.Object("bucket", "logs/done").SSEKMSKeyID, newKeyARN)
+	checkers.Equals(t, fake.Object("bucket", "other/untouched").ServerSideEncryption, "")
+}
+
+func TestReencryptKeysReportsFailures(t *testing.T) {
+	fake := dummy.NewFakeWith(reencryptBuckets)
+	tut := s3cp.NewCopier(fake, func(c *s3cp.Copier) { c.Logger = dummy.NewLogger() })
+
+	failed := map[string]bool{}
+	sum, err := tut.Reencrypt(context.Background(), s3cp.ReencryptInput{
+		Bucket:      "bucket",
+		Keys:        []string{"other/untouched", "missing"},
+		Encryption:  s3cp.Encryption{Type: s3cp.SSES3},
+		Concurrency: 1,
+	}, func(r s3cp.ObjectResult) { failed[r.Key] = r.Err != nil })
+
+	checkers.Equals(t, err.Error(), "1 of 2 objects failed")
+	checkers.Equals(t, sum, s3cp.Summary{Copied: 1, Failed: 1})
+	checkers.Equals(t, failed, map[string]bool{"other/untouched": false, "missing": true})
+	checkers.Equals(t, fake.Object("bucket", "other/untouched").ServerSideEncryption, s3cp.SSES3)
+}
+
+func TestReencryptSSECRotation(t *testing.T) {
+	fake := dummy.NewFake()
+	fake.PutObject("bucket", "secret", &dummy.Object{
+		Data:              []byte("secret"),
+		SSECustomerKeyMD5: keyMD5(srcKey),
+	})
+	tut := s3cp.NewCopier(fake, func(c *s3cp.Copier) { c.Logger = dummy.NewLogger() })
+
+	in := s3cp.ReencryptInput{
+		Bucket:           "bucket",
+		Keys:             []string{"secret"},
+		Encryption:       s3cp.Encryption{Type: s3cp.SSEC, CustomerKey: dstKey},
+		SourceEncryption: &s3cp.Encryption{Type: s3cp.SSEC, CustomerKey: srcKey},
+	}
+	sum, err := tut.Reencrypt(context.Background(), in, nil)
+	checkers.OK(t, err)
+	checkers.Equals(t, sum, s3cp.Summary{Copied: 1})
+	checkers.Equals(t, fake.Object("bucket", "secret").SSECustomerKeyMD5, keyMD5(dstKey))
+
+	// Running it again with the new key as the source skips the object.
+	in.SourceEncryption = &s3cp.Encryption{Type: s3cp.SSEC, CustomerKey: dstKey}
+	sum, err = tut.Reencrypt(context.Background(), in, nil)
+	checkers.OK(t, err)
+	checkers.Equals(t, sum, s3cp.Summary{Skipped: 1})
+}
+
+func TestReencryptInvalidInput(t *testing.T) {
+	tut := s3cp.NewCopier(dummy.NewFake())
+
+	_, err := tut.Reencrypt(context.Background(), s3cp.ReencryptInput{Bucket: "bucket"}, nil)
+	checkers.Equals(t, err.Error(), "reencrypt requires an encryption type")
+
+	_, err = tut.Reencrypt(context.Background(), s3cp.ReencryptInput{
+		Bucket:     "bucket",
+		Encryption: s3cp.Encryption{Type: s3cp.SSES3, KMSKeyID: "key"},
+	}, nil)
+	checkers.Equals(t, err.Error(), `destination encryption: KMS settings require encryption type aws:kms, got "AES256"`)
+}
@@ -0,0 +1,138 @@
+package s3cp
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// bucketRegionHeader is sent by HeadBucket, even when it refuses the request
+// or the bucket is in another region.
+const bucketRegionHeader = "X-Amz-Bucket-Region"
+
+// regionCache caches bucket regions across copies.
+type regionCache struct {
+	sync.Mutex
+	regions map[string]string
+}
+
+func newRegionCache() *regionCache {
+	return &regionCache{regions: make(map[string]string)}
+}
+
+func (r *regionCache) get(bucket string) (string, bool) {
+	if r == nil {
+		return "", false
+	}
+	r.Lock()
+	defer r.Unlock()
+
+	region, ok := r.regions[bucket]
+	return region, ok
+}
+
+func (r *regionCache) set(bucket, region string) {
+	if r == nil {
+		return
+	}
+	r.Lock()
+	defer r.Unlock()
+
+	r.regions[bucket] = region
+}
+
+// resolveRegions discovers the regions of the buckets the input doesn't give
+// a region for, and uses a client in each bucket's region. If a region
+// can't be discovered the configured client is used as is.
+func (c *copier) resolveRegions(source Location) error {
+	region := aws.StringValue(c.in.Region)
+	if region == "" {
+		region = c.bucketRegion(aws.StringValue(c.in.COI.Bucket), c.in.COI.ExpectedBucketOwner)
+		if region != "" {
+			api, err := c.cfg.regionClient(region)
+			if err != nil {
+				return err
+			}
+			c.in.Region = aws.String(region)
+			c.cfg.S3 = api
+		}
+	}
+
+	c.cfg.SrcS3 = c.cfg.S3
+	srcRegion := aws.StringValue(c.in.SourceRegion)
+	if srcRegion == "" {
+		srcRegion = c.bucketRegion(source.Bucket, c.in.COI.ExpectedSourceBucketOwner)
+		if srcRegion == region {
+			return nil
+		}
+	}
+	if srcRegion == "" {
+		return nil
+	}
+
+	api, err := c.cfg.regionClient(srcRegion)
+	if err != nil {
+		return err
+	}
+	c.in.SourceRegion = aws.String(srcRegion)
+	c.cfg.SrcS3 = api
+	return nil
+}
+
+// bucketRegion returns the region of bucket, which must be owned by owner if
+// it is set, or "" if it can't be found.
+// The region of an access point is in its ARN. Otherwise HeadBucket's
+// x-amz-bucket-region header is used, falling back to GetBucketLocation,
+// which only the bucket's owner may call.
+func (c *copier) bucketRegion(bucket string, owner *string) string {
+	if isARN(bucket) {
+		// A Multi-Region Access Point has no region.
+		return strings.SplitN(bucket, ":", 5)[3]
+	}
+	if region, ok := c.cfg.regions.get(bucket); ok {
+		return region
+	}
+
+	var header string
+	ctx, opts, done := c.startCall("HeadBucket")
+	opts = append(opts, func(r *request.Request) {
+		r.Handlers.Complete.PushBack(func(r *request.Request) {
+			if r.HTTPResponse != nil {
+				header = r.HTTPResponse.Header.Get(bucketRegionHeader)
+			}
+		})
+	})
+	out, err := c.cfg.S3.HeadBucketWithContext(ctx, &s3.HeadBucketInput{
+		Bucket:              aws.String(bucket),
+		ExpectedBucketOwner: owner,
+	}, opts...)
+	done(err)
+	region := header
+	if err == nil && out.BucketRegion != nil {
+		region = *out.BucketRegion
+	}
+
+	if region == "" && err != nil {
+		ctx, opts, done := c.startCall("GetBucketLocation")
+		loc, lerr := c.cfg.S3.GetBucketLocationWithContext(ctx, &s3.GetBucketLocationInput{
+			Bucket:              aws.String(bucket),
+			ExpectedBucketOwner: owner,
+		}, opts...)
+		done(lerr)
+		if lerr != nil {
+			attrs := c.logAttrs("region_of", bucket)
+			c.logger().Warn("failed to discover bucket region", append(attrs, errAttrs(lerr)...)...)
+			return ""
+		}
+		region = s3.NormalizeBucketLocation(aws.StringValue(loc.LocationConstraint))
+	}
+
+	if region != "" {
+		c.cfg.regions.set(bucket, region)
+		c.logger().Debug("discovered bucket region", c.logAttrs("region_of", bucket, "region", region)...)
+	}
+	return region
+}
@@ -0,0 +1,231 @@
+package s3cp_test
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/reedobrien/checkers"
+	s3cp "github.com/reedobrien/s3cp/lib"
+	"github.com/reedobrien/s3cp/lib/dummy"
+)
+
+// regionalFake is a client for region of a Fake holding every region's
+// buckets.
+type regionalFake struct {
+	*dummy.Fake
+	region string
+}
+
+func (r regionalFake) Region() string { return r.region }
+
+// regionRecorder records the regions clients are made for.
+type regionRecorder struct {
+	sync.Mutex
+	regions []string
+	api     func(region string) s3cp.API
+}
+
+func (r *regionRecorder) svcForRegion(region *string) s3cp.API {
+	r.Lock()
+	defer r.Unlock()
+
+	r.regions = append(r.regions, *region)
+	return r.api(*region)
+}
+
+func (r *regionRecorder) got() []string {
+	r.Lock()
+	defer r.Unlock()
+
+	sort.Strings(r.regions)
+	return r.regions
+}
+
+func newRegionsFake() *dummy.Fake {
+	fake := dummy.NewFakeWith(dummy.Buckets{
+		"src": {"one": {Data: thirtyBytes}, "two": {Data: thirtyBytes}},
+		"dst": nil,
+	})
+	fake.SetBucketRegion("src", "eu-west-1")
+	fake.SetBucketRegion("dst", "us-west-2")
+	return fake
+}
+
+func TestCopyRegions(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		client       string
+		srcRegion    string
+		keys         []string
+		region       *string
+		sourceRegion *string
+		regions      []string
+		headBuckets  int
+	}{
+		{
+			// Both the regions and their clients are cached across copies.
+			name:        "discovered",
+			client:      "us-east-1",
+			keys:        []string{"one", "two"},
+			regions:     []string{"eu-west-1", "us-west-2"},
+			headBuckets: 2,
+		},
+		{
+			name:        "same region keeps client",
+			client:      "us-west-2",
+			srcRegion:   "us-west-2",
+			keys:        []string{"one"},
+			headBuckets: 2,
+		},
+		{
+			name:         "given",
+			client:       "us-west-2",
+			keys:         []string{"one"},
+			region:       aws.String("us-west-2"),
+			sourceRegion: aws.String("eu-west-1"),
+			regions:      []string{"eu-west-1"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := newRegionsFake()
+			if tc.srcRegion != "" {
+				fake.SetBucketRegion("src", tc.srcRegion)
+			}
+			rec := &regionRecorder{api: func(r string) s3cp.API { return regionalFake{fake, r} }}
+			tut := s3cp.NewCopier(regionalFake{fake, tc.client},
+				func(c *s3cp.Copier) { c.PartSize = 10 },
+				func(c *s3cp.Copier) { c.MustSvcForRegion = rec.svcForRegion },
+			)
+
+			for _, key := range tc.keys {
+				err := tut.Copy(s3cp.CopyInput{
+					Region:       tc.region,
+					SourceRegion: tc.sourceRegion,
+					COI: s3.CopyObjectInput{
+						Bucket:     aws.String("dst"),
+						CopySource: aws.String("src/" + key),
+						Key:        aws.String(key),
+					},
+				})
+				checkers.OK(t, err)
+				checkers.Equals(t, fake.Object("dst", key).Data, thirtyBytes)
+			}
+
+			checkers.Equals(t, rec.got(), tc.regions)
+			checkers.Equals(t, fake.Calls("HeadBucket"), tc.headBuckets)
+		})
+	}
+}
+
+func TestPlanRegions(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		bucket  string
+		api     func(*dummy.S3API)
+		regions []string
+		calls   int64
+	}{
+		{
+			name:   "bucket location",
+			bucket: "dst",
+			api: func(d *dummy.S3API) {
+				d.HboErr = errors.New("forbidden")
+				d.Gbl = &s3.GetBucketLocationOutput{LocationConstraint: aws.String("EU")}
+			},
+			regions: []string{"eu-west-1"},
+			calls:   2,
+		},
+		{
+			name:   "not found",
+			bucket: "dst",
+			api: func(d *dummy.S3API) {
+				d.HboErr = errors.New("forbidden")
+				d.GblErr = errors.New("forbidden")
+			},
+			calls: 2,
+		},
+		{
+			name:    "access point",
+			bucket:  accessPointARN,
+			api:     func(d *dummy.S3API) { d.Hbo = &s3.HeadBucketOutput{BucketRegion: aws.String("us-west-2")} },
+			regions: []string{"us-west-2"},
+			calls:   1,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			api := dummy.NewS3API("us-east-1", tc.api)
+			rec := &regionRecorder{api: func(string) s3cp.API { return api }}
+			tut := s3cp.NewCopier(api, func(c *s3cp.Copier) { c.MustSvcForRegion = rec.svcForRegion })
+
+			_, err := tut.Plan(context.Background(), s3cp.CopyInput{
+				Size: 10,
+				COI: s3.CopyObjectInput{
+					Bucket:     aws.String(tc.bucket),
+					CopySource: aws.String("src/key"),
+					Key:        aws.String("key"),
+				},
+			})
+			checkers.OK(t, err)
+			checkers.Equals(t, rec.got(), tc.regions)
+			checkers.Equals(t, api.HboCalls, tc.calls)
+		})
+	}
+}
+
+// redirectingFake is a client in the wrong region for every bucket, so its
+// object reads fail as S3's do.
+type redirectingFake struct {
+	regionalFake
+}
+
+var errRedirect = awserr.New("PermanentRedirect", "wrong region", nil)
+
+func (redirectingFake) HeadObjectWithContext(aws.Context, *s3.HeadObjectInput, ...request.Option) (*s3.HeadObjectOutput, error) {
+	return nil, errRedirect
+}
+
+func (redirectingFake) GetObjectTaggingWithContext(aws.Context, *s3.GetObjectTaggingInput, ...request.Option) (*s3.GetObjectTaggingOutput, error) {
+	return nil, errRedirect
+}
+
+func (redirectingFake) GetObjectAclWithContext(aws.Context, *s3.GetObjectAclInput, ...request.Option) (*s3.GetObjectAclOutput, error) {
+	return nil, errRedirect
+}
+
+func (redirectingFake) PutObjectAclWithContext(aws.Context, *s3.PutObjectAclInput, ...request.Option) (*s3.PutObjectAclOutput, error) {
+	return nil, errRedirect
+}
+
+func (redirectingFake) ListObjectsV2PagesWithContext(aws.Context, *s3.ListObjectsV2Input, func(*s3.ListObjectsV2Output, bool) bool, ...request.Option) error {
+	return errRedirect
+}
+
+func TestRewriteInOtherRegion(t *testing.T) {
+	fake := dummy.NewFakeWith(dummy.Buckets{"bucket": {
+		"a": {Data: thirtyBytes, Grants: []*s3.Grant{readGrant}},
+		"b": {Data: thirtyBytes},
+	}})
+	fake.SetBucketRegion("bucket", "eu-west-1")
+	rec := &regionRecorder{api: func(r string) s3cp.API { return regionalFake{fake, r} }}
+	tut := s3cp.NewCopier(redirectingFake{regionalFake{fake, "us-east-1"}},
+		func(c *s3cp.Copier) { c.MustSvcForRegion = rec.svcForRegion },
+	)
+
+	err := tut.Rewrite(context.Background(), s3cp.RewriteInput{Bucket: "bucket", Key: "a", StorageClass: s3.StorageClassGlacierIr})
+	checkers.OK(t, err)
+	checkers.Equals(t, fake.Object("bucket", "a").StorageClass, s3.StorageClassGlacierIr)
+	checkers.Equals(t, fake.Object("bucket", "a").Grants, []*s3.Grant{readGrant})
+
+	sum, err := tut.Reencrypt(context.Background(), s3cp.ReencryptInput{Bucket: "bucket", Encryption: s3cp.Encryption{Type: s3cp.SSES3}}, nil)
+	checkers.OK(t, err)
+	checkers.Equals(t, sum, s3cp.Summary{Copied: 2})
+	checkers.Equals(t, rec.got(), []string{"eu-west-1"})
+}
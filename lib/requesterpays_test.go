@@ -0,0 +1,134 @@
+package s3cp_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/reedobrien/checkers"
+	s3cp "github.com/reedobrien/s3cp/lib"
+	"github.com/reedobrien/s3cp/lib/dummy"
+)
+
+func newRequesterPaysFake() *dummy.Fake {
+	fake := dummy.NewFakeWith(dummy.Buckets{
+		"dataset": {"key": {Data: thirtyBytes, Tags: map[string]string{"team": "data"}}},
+		"dst":     nil,
+	})
+	fake.EnableRequesterPays("dataset")
+	fake.EnableRequesterPays("dst")
+	return fake
+}
+
+// requesterPaysInput copies from the requester pays dataset bucket,
+// confirming the charges on both sides.
+var requesterPaysInput = s3cp.CopyInput{
+	SourceRequestPayer: aws.String(s3.RequestPayerRequester),
+	COI: s3.CopyObjectInput{
+		Bucket:       aws.String("dst"),
+		CopySource:   aws.String("dataset/key"),
+		Key:          aws.String("key"),
+		RequestPayer: aws.String(s3.RequestPayerRequester),
+	},
+}
+
+func TestCopyRequesterPays(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		partSize int64
+	}{
+		{"single part", s3cp.DefaultCopyPartSize},
+		{"multipart", 10},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := newRequesterPaysFake()
+			tut := s3cp.NewCopier(fake, func(c *s3cp.Copier) { c.PartSize = tc.partSize })
+
+			in := requesterPaysInput
+			in.Delete = true
+			in.ACL = &s3cp.ACL{FromSource: true}
+			err := tut.Copy(in)
+			checkers.OK(t, err)
+
+			got := fake.Object("dst", "key")
+			checkers.Equals(t, got.Data, thirtyBytes)
+			checkers.Equals(t, got.Tags, map[string]string{"team": "data"})
+			checkers.Assert(t, fake.Object("dataset", "key") == nil, "expected the source to be deleted")
+		})
+	}
+}
+
+func TestCopyRequesterPaysSourceOnly(t *testing.T) {
+	fake := newRequesterPaysFake()
+	fake.CreateBucket("plain")
+	tut := s3cp.NewCopier(fake, func(c *s3cp.Copier) { c.PartSize = 10 })
+
+	in := requesterPaysInput
+	in.COI.Bucket = aws.String("plain")
+	in.COI.RequestPayer = nil
+	err := tut.Copy(in)
+	checkers.OK(t, err)
+	checkers.Equals(t, fake.Object("plain", "key").Data, thirtyBytes)
+}
+
+func TestCopyRequesterPaysNotConfirmed(t *testing.T) {
+	fake := newRequesterPaysFake()
+	tut := s3cp.NewCopier(fake)
+
+	in := requesterPaysInput
+	in.SourceRequestPayer = nil
+	err := tut.Copy(in)
+
+	checkers.Assert(t, err != nil, "expected an error")
+	checkers.Assert(t, strings.Contains(err.Error(), "AccessDenied"), "expected AccessDenied, got %s", err)
+	checkers.Equals(t, fake.Calls("CopyObject"), 0)
+}
+
+func TestPlan(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		in       func(*s3cp.CopyInput)
+		partSize int64
+		parts    int
+		warnings []string
+	}{
+		{
+			name:     "single part",
+			in:       func(in *s3cp.CopyInput) {},
+			partSize: s3cp.DefaultCopyPartSize,
+			warnings: []string{
+				"source bucket dataset is requester pays: its requests and data transfer out are billed to us",
+				"destination bucket dst is requester pays: its requests are billed to us",
+			},
+		},
+		{
+			name:     "multipart",
+			in:       func(in *s3cp.CopyInput) { in.COI.RequestPayer = nil },
+			partSize: 10,
+			parts:    3,
+			warnings: []string{
+				"source bucket dataset is requester pays: its requests and data transfer out are billed to us",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := newRequesterPaysFake()
+			tut := s3cp.NewCopier(fake, func(c *s3cp.Copier) { c.PartSize = tc.partSize })
+
+			in := requesterPaysInput
+			tc.in(&in)
+			got, err := tut.Plan(context.Background(), in)
+			checkers.OK(t, err)
+
+			checkers.Equals(t, got.Source, "dataset/key")
+			checkers.Equals(t, got.Destination, "dst/key")
+			checkers.Equals(t, got.Size, int64(30))
+			checkers.Equals(t, got.Parts, tc.parts)
+			checkers.Equals(t, got.Warnings, tc.warnings)
+			checkers.Equals(t, fake.Object("dst", "key"), (*dummy.Object)(nil))
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package s3cp
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// RewriteInput is a parameter container for Copier.Rewrite. Empty fields
+// keep the object's current value.
+type RewriteInput struct {
+	// The bucket and key of the object.
+	Bucket string
+	Key    string
+
+	StorageClass       string
+	CacheControl       string
+	ContentDisposition string
+	ContentEncoding    string
+	ContentLanguage    string
+	ContentType        string
+
+	// Metadata is merged into the object's user metadata. Keys with an
+	// empty value are removed.
+	Metadata map[string]string
+
+	// Tags is merged into the object's tags. Keys with an empty value are
+	// removed.
+	Tags map[string]string
+
+	// Encryption, if set, replaces the object's encryption. Otherwise its
+	// SSE-S3 or SSE-KMS key, or the SSE-C key in SourceEncryption, is kept.
+	// An SSE-KMS encryption context is not visible to HEAD and is lost
+	// unless set here.
+	Encryption *Encryption
+
+	// SourceEncryption provides the object's SSE-C key, if it has one.
+	SourceEncryption *Encryption
+
+	// ExpectedBucketOwner, if set, is the account that must own the bucket.
+	ExpectedBucketOwner string
+}
+
+// Rewrite changes the attributes of an object of any size by copying it onto
+// itself, keeping everything not named in the input, including its ACL. The
+// rewritten object is then read back and checked against the source and the
+// requested changes.
+func (c Copier) Rewrite(ctx aws.Context, in RewriteInput) error {
+	c, err := c.bucketClient(ctx, in.Bucket, in.ExpectedBucketOwner)
+	if err != nil {
+		return err
+	}
+	state, err := c.objectState(ctx, in.Bucket, in.Key, in.ExpectedBucketOwner, in.SourceEncryption)
+	if err != nil {
+		return err
+	}
+
+	ci := state.copyInput()
+	coi := &ci.COI
+	setString(&coi.StorageClass, in.StorageClass)
+	setString(&coi.CacheControl, in.CacheControl)
+	setString(&coi.ContentDisposition, in.ContentDisposition)
+	setString(&coi.ContentEncoding, in.ContentEncoding)
+	setString(&coi.ContentLanguage, in.ContentLanguage)
+	setString(&coi.ContentType, in.ContentType)
+	coi.Metadata = mergeMetadata(coi.Metadata, in.Metadata)
+	coi.Tagging = nil
+	if tags := mergeTags(state.tags, in.Tags); len(tags) > 0 {
+		coi.Tagging = aws.String(encodeTags(tags))
+	}
+
+	ci.SourceEncryption = in.SourceEncryption
+	ci.Encryption = in.Encryption
+	if ci.Encryption == nil {
+		ci.Encryption = state.encryption(in.SourceEncryption)
+	}
+
+	if err := c.CopyWithContext(ctx, ci); err != nil {
+		return err
+	}
+	if ci.ACL == nil {
+		if err := c.restoreACL(ctx, state); err != nil {
+			return err
+		}
+	}
+
+	dst := ci.Encryption
+	if dst != nil && dst.Type != SSEC {
+		dst = nil
+	}
+	return c.verifyRewrite(ctx, state, ci, dst)
+}
+
+// verifyRewrite reads back the object rewritten by ci from the source state
+// and checks it has the source's size and the requested attributes. dst
+// provides its SSE-C key if it has one.
+func (c Copier) verifyRewrite(ctx aws.Context, src *objectState, ci CopyInput, dst *Encryption) error {
+	got, err := c.objectState(ctx, src.bucket, src.key, src.owner, dst)
+	if err != nil {
+		return fmt.Errorf("error verifying rewrite of %s/%s: %s", src.bucket, src.key, err)
+	}
+
+	storageClass := func(s *string) string {
+		if aws.StringValue(s) == "" {
+			return s3.StorageClassStandard
+		}
+		return *s
+	}
+
+	h := got.head
+	for _, f := range []struct {
+		name      string
+		got, want string
+	}{
+		{"size", fmt.Sprint(aws.Int64Value(h.ContentLength)), fmt.Sprint(aws.Int64Value(src.head.ContentLength))},
+		{"storage class", storageClass(h.StorageClass), storageClass(ci.COI.StorageClass)},
+		{"cache control", aws.StringValue(h.CacheControl), aws.StringValue(ci.COI.CacheControl)},
+		{"content disposition", aws.StringValue(h.ContentDisposition), aws.StringValue(ci.COI.ContentDisposition)},
+		{"content encoding", aws.StringValue(h.ContentEncoding), aws.StringValue(ci.COI.ContentEncoding)},
+		{"content language", aws.StringValue(h.ContentLanguage), aws.StringValue(ci.COI.ContentLanguage)},
+		{"content type", aws.StringValue(h.ContentType), aws.StringValue(ci.COI.ContentType)},
+		{"metadata", encodeMetadata(h.Metadata), encodeMetadata(ci.COI.Metadata)},
+		{"tags", encodeTags(got.tags), aws.StringValue(ci.COI.Tagging)},
+	} {
+		if f.got != f.want {
+			return fmt.Errorf("rewrite of %s/%s not verified: %s is %q, want %q", src.bucket, src.key, f.name, f.got, f.want)
+		}
+	}
+	return nil
+}
+
+// bucketClient returns c with its S3 a client in the region of bucket, which
+// owner, if set, is expected to own, to read and list the objects in it.
+func (c Copier) bucketClient(ctx aws.Context, bucket, owner string) (Copier, error) {
+	return c.sourceLister(ctx, Location{Bucket: bucket}, CopyInput{
+		COI: s3.CopyObjectInput{ExpectedSourceBucketOwner: optional(owner)},
+	})
+}
+
+// encodeMetadata encodes metadata with lower cased keys, for comparison.
+func encodeMetadata(m map[string]*string) string {
+	v := url.Values{}
+	for k, val := range m {
+		v.Set(strings.ToLower(k), aws.StringValue(val))
+	}
+	return v.Encode()
+}
+
+// mergeMetadata returns the metadata with changes applied. Keys are compared
+// case insensitively, as S3 does, and those with empty values are removed.
+func mergeMetadata(metadata map[string]*string, changes map[string]string) map[string]*string {
+	merged := make(map[string]*string, len(metadata)+len(changes))
+	for k, v := range metadata {
+		merged[strings.ToLower(k)] = v
+	}
+	for k, v := range changes {
+		k = strings.ToLower(k)
+		if v == "" {
+			delete(merged, k)
+			continue
+		}
+		merged[k] = aws.String(v)
+	}
+	return merged
+}
+
+// mergeTags returns the tags with changes applied. Those with empty values
+// are removed.
+func mergeTags(tags []*s3.Tag, changes map[string]string) []*s3.Tag {
+	merged := make(map[string]string, len(tags)+len(changes))
+	for _, t := range tags {
+		merged[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+	}
+	for k, v := range changes {
+		if v == "" {
+			delete(merged, k)
+			continue
+		}
+		merged[k] = v
+	}
+
+	out := make([]*s3.Tag, 0, len(merged))
+	for k, v := range merged {
+		out = append(out, &s3.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	return out
+}
+
+func setString(p **string, s string) {
+	if s != "" {
+		*p = aws.String(s)
+	}
+}
+
+// optional returns nil for an empty string, so an unset field is not sent.
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return aws.String(s)
+}
@@ -0,0 +1,163 @@
+package s3cp_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/reedobrien/checkers"
+	s3cp "github.com/reedobrien/s3cp/lib"
+	"github.com/reedobrien/s3cp/lib/dummy"
+)
+
+var thirtyBytes = []byte(strings.Repeat("0123456789", 3))
+
+func TestRewriteMultipart(t *testing.T) {
+	fake := dummy.NewFake()
+	fake.PutObject("bucket", "big", &dummy.Object{
+		Data:                 thirtyBytes,
+		ContentType:          "text/plain",
+		CacheControl:         "no-cache",
+		Metadata:             map[string]string{"keep": "1", "old": "x"},
+		Tags:                 map[string]string{"team": "sec", "tmp": "y"},
+		Grants:               []*s3.Grant{readGrant},
+		ServerSideEncryption: s3cp.SSEKMS,
+		SSEKMSKeyID:          newKeyARN,
+	})
+	tut := s3cp.NewCopier(fake, func(c *s3cp.Copier) { c.PartSize = 10 })
+
+	err := tut.Rewrite(context.Background(), s3cp.RewriteInput{
+		Bucket:       "bucket",
+		Key:          "big",
+		StorageClass: s3.StorageClassGlacierIr,
+		ContentType:  "text/csv",
+		Metadata:     map[string]string{"New": "2", "old": ""},
+		Tags:         map[string]string{"tier": "cold", "tmp": ""},
+	})
+	checkers.OK(t, err)
+
+	got := fake.Object("bucket", "big")
+	checkers.Equals(t, got.Data, thirtyBytes)
+	checkers.Assert(t, strings.HasSuffix(got.ETag, `-3"`), "expected a multipart ETag, got %s", got.ETag)
+	checkers.Equals(t, got.StorageClass, s3.StorageClassGlacierIr)
+	checkers.Equals(t, got.ContentType, "text/csv")
+	checkers.Equals(t, got.CacheControl, "no-cache")
+	checkers.Equals(t, got.Metadata, map[string]string{"keep": "1", "new": "2"})
+	checkers.Equals(t, got.Tags, map[string]string{"team": "sec", "tier": "cold"})
+	checkers.Equals(t, got.Grants, []*s3.Grant{readGrant})
+	checkers.Equals(t, got.ServerSideEncryption, s3cp.SSEKMS)
+	checkers.Equals(t, got.SSEKMSKeyID, newKeyARN)
+	// The grants are written with the copy, so it is never private.
+	checkers.Equals(t, fake.Calls("PutObjectAcl"), 0)
+}
+
+func TestRewriteSinglePartSSEC(t *testing.T) {
+	fake := dummy.NewFake()
+	fake.PutObject("bucket", "small", &dummy.Object{
+		Data:              []byte("small"),
+		SSECustomerKeyMD5: keyMD5(srcKey),
+	})
+	tut := s3cp.NewCopier(fake)
+
+	err := tut.Rewrite(context.Background(), s3cp.RewriteInput{
+		Bucket:           "bucket",
+		Key:              "small",
+		ContentType:      "text/plain",
+		SourceEncryption: &s3cp.Encryption{Type: s3cp.SSEC, CustomerKey: srcKey},
+	})
+	checkers.OK(t, err)
+
+	got := fake.Object("bucket", "small")
+	checkers.Equals(t, got.Data, []byte("small"))
+	checkers.Equals(t, got.ContentType, "text/plain")
+	checkers.Equals(t, got.SSECustomerKeyMD5, keyMD5(srcKey))
+	checkers.Equals(t, fake.Calls("CopyObject"), 1)
+}
+
+// storageClassDropper loses the storage class of copies, so the rewrite
+// can't be verified.
+type storageClassDropper struct {
+	*dummy.Fake
+}
+
+func (d storageClassDropper) CopyObjectWithContext(ctx aws.Context, in *s3.CopyObjectInput, opts ...request.Option) (*s3.CopyObjectOutput, error) {
+	cp := *in
+	cp.StorageClass = nil
+	return d.Fake.CopyObjectWithContext(ctx, &cp, opts...)
+}
+
+func TestRewriteVerifyFails(t *testing.T) {
+	fake := dummy.NewFake()
+	fake.PutObject("bucket", "key", &dummy.Object{Data: []byte("data")})
+	tut := s3cp.NewCopier(storageClassDropper{fake})
+
+	err := tut.Rewrite(context.Background(), s3cp.RewriteInput{
+		Bucket:       "bucket",
+		Key:          "key",
+		StorageClass: s3.StorageClassGlacierIr,
+	})
+	checkers.Equals(t, err.Error(), `rewrite of bucket/key not verified: storage class is "STANDARD", want "GLACIER_IR"`)
+}
+
+// aclRefuser refuses to put ACLs.
+type aclRefuser struct {
+	*dummy.Fake
+}
+
+func (aclRefuser) PutObjectAclWithContext(aws.Context, *s3.PutObjectAclInput, ...request.Option) (*s3.PutObjectAclOutput, error) {
+	return nil, errors.New("AccessDenied: put denied")
+}
+
+func TestRewriteReportsLostGrants(t *testing.T) {
+	// A log delivery group's WRITE can't be sent with the copy, so the ACL
+	// is put back after it.
+	write := &s3.Grant{
+		Grantee:    &s3.Grantee{URI: aws.String("http://acs.amazonaws.com/groups/s3/LogDelivery"), Type: aws.String(s3.TypeGroup)},
+		Permission: aws.String(s3.PermissionWrite),
+	}
+	fake := dummy.NewFake()
+	fake.PutObject("bucket", "key", &dummy.Object{Data: []byte("data"), Grants: []*s3.Grant{readGrant, write}})
+	tut := s3cp.NewCopier(aclRefuser{fake})
+
+	err := tut.Rewrite(context.Background(), s3cp.RewriteInput{Bucket: "bucket", Key: "key", ContentType: "text/plain"})
+	checkers.Assert(t, err != nil, "expected an error")
+	checkers.Equals(t, err.Error(), "error restoring the ACL of bucket/key, which had grants id=partner-id:READ, uri=http://acs.amazonaws.com/groups/s3/LogDelivery:WRITE: AccessDenied: put denied")
+}
+
+func TestRewriteMissing(t *testing.T) {
+	tut := s3cp.NewCopier(dummy.NewFake())
+	err := tut.Rewrite(context.Background(), s3cp.RewriteInput{Bucket: "bucket", Key: "key"})
+	checkers.Assert(t, err != nil, "expected an error")
+}
+
+func TestMultipartCopyKeepsSourceAttributes(t *testing.T) {
+	fake := dummy.NewFake()
+	fake.PutObject("src", "key", &dummy.Object{
+		Data:        thirtyBytes,
+		ContentType: "text/plain",
+		Metadata:    map[string]string{"sha1": "abc"},
+		Tags:        map[string]string{"team": "sec"},
+	})
+	fake.CreateBucket("dst")
+	tut := s3cp.NewCopier(fake, func(c *s3cp.Copier) { c.PartSize = 10 })
+
+	err := tut.Copy(s3cp.CopyInput{
+		COI: s3.CopyObjectInput{
+			Bucket:     aws.String("dst"),
+			CopySource: aws.String("src/key"),
+			Key:        aws.String("key"),
+		},
+	})
+	checkers.OK(t, err)
+
+	got := fake.Object("dst", "key")
+	checkers.Equals(t, got.Data, thirtyBytes)
+	checkers.Equals(t, got.ContentType, "text/plain")
+	checkers.Equals(t, got.Metadata, map[string]string{"sha1": "abc"})
+	checkers.Equals(t, got.Tags, map[string]string{"team": "sec"})
+}
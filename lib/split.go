@@ -0,0 +1,213 @@
+package s3cp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// splitLineWindow is how much of the source is read at a time looking for
+// the newline after a cut.
+const splitLineWindow = 64 * 1024
+
+// SplitInput is a parameter container for Copier.Split.
+type SplitInput struct {
+	// Source is the object to split.
+	Source Location
+
+	// Destination is the bucket and key prefix of the pieces. Each piece's
+	// key is the prefix followed by its zero padded index, e.g. 00000.
+	Destination Location
+
+	// Pieces is how many pieces of about the same size to split the source
+	// into, or PieceSize the size of every piece but the last. Set one.
+	Pieces    int
+	PieceSize int64
+
+	// Lines moves each cut forward to just after the next newline, so no
+	// line of a text object is split. Pieces may then be larger, and fewer
+	// if lines are longer than pieces.
+	Lines bool
+
+	// Template sets how each piece is written, e.g. its Encryption or
+	// StorageClass; Delete isn't allowed. Each piece gets its own size and
+	// key, and is only read while the source has the ETag it was split at.
+	Template CopyInput
+
+	// How many pieces to copy at once. Defaults to
+	// DefaultObjectConcurrency.
+	Concurrency int
+}
+
+// SplitManifest lists the pieces of a split source.
+type SplitManifest struct {
+	Source string       `json:"source"`
+	ETag   string       `json:"etag"`
+	Size   int64        `json:"size"`
+	Pieces []SplitPiece `json:"pieces"`
+}
+
+// SplitPiece is an object holding the bytes of the source from Offset.
+type SplitPiece struct {
+	Location string `json:"location"`
+	Offset   int64  `json:"offset"`
+	Size     int64  `json:"size"`
+}
+
+// Split copies consecutive byte ranges of the source into pieces, server side
+// with UploadPartCopy. The source is HEADed once and every piece must match
+// its ETag. With Lines set the cuts are found with small ranged GETs.
+// progress, if not nil, is called with the result of each piece, keyed by its
+// destination key; calls are serialized. The manifest is returned even if
+// pieces fail, with an error.
+func (c Copier) Split(ctx aws.Context, in SplitInput, progress func(ObjectResult)) (*SplitManifest, error) {
+	if err := in.validate(); err != nil {
+		return nil, err
+	}
+
+	reader, err := c.sourceLister(ctx, in.Source, in.Template)
+	if err != nil {
+		return nil, err
+	}
+	impl := copier{in: in.Template, cfg: reader, ctx: ctx}
+	impl.applyRequestPayer()
+	if err := impl.applyEncryption(); err != nil {
+		return nil, err
+	}
+	info, err := impl.headSource(reader.S3, in.Source)
+	if err != nil {
+		return nil, err
+	}
+	src := composeSource{Location: in.Source, api: reader.S3, size: aws.Int64Value(info.ContentLength), etag: info.ETag}
+	if src.size == 0 {
+		return nil, fmt.Errorf("%s is empty", in.Source)
+	}
+
+	cuts := splitCuts(src.size, in.Pieces, in.PieceSize)
+	if in.Lines {
+		if cuts, err = impl.lineCuts(src, cuts); err != nil {
+			return nil, err
+		}
+	}
+
+	m := &SplitManifest{Source: in.Source.String(), ETag: aws.StringValue(src.etag), Size: src.size}
+	ranges := make(map[string]byteRange)
+	var objects []*s3.Object
+	for i, start := range cuts {
+		end := src.size
+		if i+1 < len(cuts) {
+			end = cuts[i+1]
+		}
+		dst := Location{Bucket: in.Destination.Bucket, Key: fmt.Sprintf("%s%05d", in.Destination.Key, i)}
+		m.Pieces = append(m.Pieces, SplitPiece{Location: dst.String(), Offset: start, Size: end - start})
+		ranges[dst.Key] = byteRange{start: start, end: end}
+		objects = append(objects, &s3.Object{Key: aws.String(dst.Key), Size: aws.Int64(end - start)})
+	}
+
+	ci := in.Template
+	ci.Size = src.size
+	ci.COI.Bucket = aws.String(in.Destination.Bucket)
+	ci.COI.CopySource = aws.String(in.Source.CopySource())
+	ci.COI.CopySourceIfMatch = src.etag
+
+	b := &bulk{progress: progress}
+	err = b.run(ctx, in.Concurrency, func(ctx aws.Context, out chan<- *s3.Object) error {
+		for _, o := range objects {
+			select {
+			case out <- o:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	}, func(ctx aws.Context, o *s3.Object) ObjectResult {
+		key := aws.StringValue(o.Key)
+		pci := ci
+		pci.COI.Key = aws.String(key)
+		rng := ranges[key]
+		return ObjectResult{Key: key, Err: c.copyWith(ctx, pci, func(impl *copier) { impl.sourceRange = &rng })}
+	})
+	if err != nil {
+		return m, err
+	}
+	if b.sum.Failed > 0 {
+		return m, fmt.Errorf("%d of %d pieces failed", b.sum.Failed, len(objects))
+	}
+	return m, nil
+}
+
+func (in *SplitInput) validate() error {
+	if in.Source.Bucket == "" || in.Source.Key == "" {
+		return errors.New("split requires a source bucket and key")
+	}
+	if in.Destination.Bucket == "" {
+		return errors.New("split requires a destination bucket")
+	}
+	if in.Pieces < 0 || in.PieceSize < 0 {
+		return errors.New("split piece count and size can't be negative")
+	}
+	if (in.Pieces > 0) == (in.PieceSize > 0) {
+		return errors.New("split requires either a piece count or a piece size")
+	}
+	if in.Template.Delete {
+		return errors.New("split can't delete its source")
+	}
+	return nil
+}
+
+// splitCuts returns the offsets at which the pieces of an object of size
+// bytes start: pieces of about the same size, or one every pieceSize bytes.
+func splitCuts(size int64, pieces int, pieceSize int64) []int64 {
+	if pieces > 0 {
+		pieceSize = int64(math.Ceil(float64(size) / float64(pieces)))
+	}
+	var cuts []int64
+	for off := int64(0); off < size; off += pieceSize {
+		cuts = append(cuts, off)
+	}
+	return cuts
+}
+
+// lineCuts moves each cut after the first to just past the next newline,
+// dropping cuts that reach the end or one another.
+func (c *copier) lineCuts(src composeSource, cuts []int64) ([]int64, error) {
+	aligned := []int64{0}
+	for _, cut := range cuts[1:] {
+		if cut <= aligned[len(aligned)-1] {
+			// The previous cut's line ran past this one, so look for the
+			// line after it.
+			cut = aligned[len(aligned)-1] + 1
+		}
+		next, err := c.lineCut(src, cut)
+		if err != nil {
+			return nil, err
+		}
+		if next > aligned[len(aligned)-1] && next < src.size {
+			aligned = append(aligned, next)
+		}
+	}
+	return aligned, nil
+}
+
+// lineCut returns the offset just past the first newline at or after
+// cut-1, or the size of src if there is none.
+func (c *copier) lineCut(src composeSource, cut int64) (int64, error) {
+	for start := cut - 1; start < src.size; start += splitLineWindow {
+		end := start + splitLineWindow
+		if end > src.size {
+			end = src.size
+		}
+		b, err := c.download(src, byteRange{start: start, end: end})
+		if err != nil {
+			return 0, err
+		}
+		if i := bytes.IndexByte(b, '\n'); i >= 0 {
+			return start + int64(i) + 1, nil
+		}
+	}
+	return src.size, nil
+}
@@ -0,0 +1,166 @@
+package s3cp_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/reedobrien/checkers"
+	s3cp "github.com/reedobrien/s3cp/lib"
+	"github.com/reedobrien/s3cp/lib/dummy"
+)
+
+func newSplitFake(data []byte) *dummy.Fake {
+	return dummy.NewFakeWith(dummy.Buckets{"src": {"big": {Data: data}}, "dst": nil})
+}
+
+func TestSplit(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 10)
+	fake := newSplitFake(data)
+	tut := s3cp.NewCopier(fake, func(c *s3cp.Copier) { c.PartSize = 10 })
+
+	m, err := tut.Split(context.Background(), s3cp.SplitInput{
+		Source:      s3cp.Location{Bucket: "src", Key: "big"},
+		Destination: s3cp.Location{Bucket: "dst", Key: "pieces/"},
+		PieceSize:   40,
+	}, nil)
+	checkers.OK(t, err)
+	checkers.Equals(t, m, &s3cp.SplitManifest{
+		Source: "s3://src/big",
+		ETag:   fake.Object("src", "big").ETag,
+		Size:   100,
+		Pieces: []s3cp.SplitPiece{
+			{Location: "s3://dst/pieces/00000", Offset: 0, Size: 40},
+			{Location: "s3://dst/pieces/00001", Offset: 40, Size: 40},
+			{Location: "s3://dst/pieces/00002", Offset: 80, Size: 20},
+		},
+	})
+
+	var got []byte
+	for _, k := range fake.Keys("dst") {
+		got = append(got, fake.Object("dst", k).Data...)
+	}
+	checkers.Equals(t, got, data)
+	checkers.Equals(t, fake.Calls("HeadObject"), 4)
+	checkers.Equals(t, fake.Calls("UploadPartCopy"), 10)
+	checkers.Equals(t, fake.Calls("CopyObject"), 0)
+	checkers.Equals(t, fake.Calls("GetObject"), 0)
+}
+
+func TestSplitPieces(t *testing.T) {
+	fake := newSplitFake([]byte("0123456789"))
+	tut := s3cp.NewCopier(fake)
+
+	m, err := tut.Split(context.Background(), s3cp.SplitInput{
+		Source:      s3cp.Location{Bucket: "src", Key: "big"},
+		Destination: s3cp.Location{Bucket: "dst"},
+		Pieces:      3,
+	}, nil)
+	checkers.OK(t, err)
+	checkers.Equals(t, len(m.Pieces), 3)
+	checkers.Equals(t, fake.Keys("dst"), []string{"00000", "00001", "00002"})
+	checkers.Equals(t, fake.Object("dst", "00000").Data, []byte("0123"))
+	checkers.Equals(t, fake.Object("dst", "00001").Data, []byte("4567"))
+	checkers.Equals(t, fake.Object("dst", "00002").Data, []byte("89"))
+}
+
+func TestSplitLines(t *testing.T) {
+	fake := newSplitFake([]byte("aaa\nbbbbbb\ncc\ndddd\n"))
+	tut := s3cp.NewCopier(fake)
+
+	var results []s3cp.ObjectResult
+	m, err := tut.Split(context.Background(), s3cp.SplitInput{
+		Source:      s3cp.Location{Bucket: "src", Key: "big"},
+		Destination: s3cp.Location{Bucket: "dst", Key: "lines-"},
+		Pieces:      4,
+		Lines:       true,
+		Concurrency: 1,
+	}, func(r s3cp.ObjectResult) { results = append(results, r) })
+	checkers.OK(t, err)
+	checkers.Equals(t, m.Pieces, []s3cp.SplitPiece{
+		{Location: "s3://dst/lines-00000", Offset: 0, Size: 11},
+		{Location: "s3://dst/lines-00001", Offset: 11, Size: 3},
+		{Location: "s3://dst/lines-00002", Offset: 14, Size: 5},
+	})
+	checkers.Equals(t, fake.Object("dst", "lines-00000").Data, []byte("aaa\nbbbbbb\n"))
+	checkers.Equals(t, fake.Object("dst", "lines-00001").Data, []byte("cc\n"))
+	checkers.Equals(t, fake.Object("dst", "lines-00002").Data, []byte("dddd\n"))
+	checkers.Equals(t, results, []s3cp.ObjectResult{{Key: "lines-00000"}, {Key: "lines-00001"}, {Key: "lines-00002"}})
+	checkers.Equals(t, fake.Calls("GetObject"), 3)
+}
+
+func TestSplitLongLine(t *testing.T) {
+	fake := newSplitFake([]byte("aaaaaaaaaa\nbb\ncc\ndd\n"))
+	tut := s3cp.NewCopier(fake)
+
+	_, err := tut.Split(context.Background(), s3cp.SplitInput{
+		Source:      s3cp.Location{Bucket: "src", Key: "big"},
+		Destination: s3cp.Location{Bucket: "dst"},
+		PieceSize:   5,
+		Lines:       true,
+	}, nil)
+	checkers.OK(t, err)
+
+	// Only the line longer than PieceSize makes a longer piece.
+	var got []string
+	for _, k := range fake.Keys("dst") {
+		got = append(got, string(fake.Object("dst", k).Data))
+	}
+	checkers.Equals(t, got, []string{"aaaaaaaaaa\n", "bb\n", "cc\n", "dd\n"})
+}
+
+func TestSplitErrors(t *testing.T) {
+	src, dst := s3cp.Location{Bucket: "src", Key: "big"}, s3cp.Location{Bucket: "dst"}
+	for _, tc := range []struct {
+		name string
+		in   s3cp.SplitInput
+		err  string
+	}{
+		{
+			name: "no source",
+			in:   s3cp.SplitInput{Destination: dst, Pieces: 2},
+			err:  "split requires a source bucket and key",
+		},
+		{
+			name: "no destination",
+			in:   s3cp.SplitInput{Source: src, Pieces: 2},
+			err:  "split requires a destination bucket",
+		},
+		{
+			name: "no size",
+			in:   s3cp.SplitInput{Source: src, Destination: dst},
+			err:  "split requires either a piece count or a piece size",
+		},
+		{
+			name: "both sizes",
+			in:   s3cp.SplitInput{Source: src, Destination: dst, Pieces: 2, PieceSize: 10},
+			err:  "split requires either a piece count or a piece size",
+		},
+		{
+			name: "negative",
+			in:   s3cp.SplitInput{Source: src, Destination: dst, Pieces: -1},
+			err:  "split piece count and size can't be negative",
+		},
+		{
+			name: "delete",
+			in:   s3cp.SplitInput{Source: src, Destination: dst, Pieces: 2, Template: s3cp.CopyInput{Delete: true}},
+			err:  "split can't delete its source",
+		},
+		{
+			name: "empty",
+			in:   s3cp.SplitInput{Source: s3cp.Location{Bucket: "src", Key: "empty"}, Destination: dst, Pieces: 2},
+			err:  "s3://src/empty is empty",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := newSplitFake([]byte("data"))
+			fake.PutObject("src", "empty", &dummy.Object{})
+			tut := s3cp.NewCopier(fake)
+
+			_, err := tut.Split(context.Background(), tc.in, nil)
+			checkers.Assert(t, err != nil, "expected an error")
+			checkers.Equals(t, err.Error(), tc.err)
+			checkers.Equals(t, len(fake.Keys("dst")), 0)
+		})
+	}
+}
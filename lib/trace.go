@@ -0,0 +1,93 @@
+package s3cp
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation scope of the copier's spans.
+const tracerName = "github.com/reedobrien/s3cp/lib"
+
+// Span attribute keys.
+const (
+	attrBucket     = attribute.Key("aws.s3.bucket")
+	attrKey        = attribute.Key("aws.s3.key")
+	attrCopySource = attribute.Key("aws.s3.copy_source")
+	attrUploadID   = attribute.Key("aws.s3.upload_id")
+	attrPartNumber = attribute.Key("aws.s3.part_number")
+	attrRequestID  = attribute.Key("aws.request_id")
+	attrRange      = attribute.Key("s3cp.range")
+	attrRetry      = attribute.Key("s3cp.retry")
+	attrSize       = attribute.Key("s3cp.size")
+)
+
+// tracer returns the Tracer from the configured TracerProvider, or from the
+// global one if none is set.
+func (c *copier) tracer() trace.Tracer {
+	tp := c.cfg.TracerProvider
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return tp.Tracer(tracerName)
+}
+
+// startCall begins the S3 call op. It returns the context and request options
+// to make the call with, and a func to call with the call's error once it
+// returns, which records the call's span and latency.
+func (c *copier) startCall(op string, attrs ...attribute.KeyValue) (context.Context, []request.Option, func(error)) {
+	start := time.Now()
+	ctx, span := c.startSpan("s3cp."+op, attrs...)
+	return ctx, c.requestOptions(span), func(err error) {
+		c.observe(op, start)
+		endSpan(span, err)
+	}
+}
+
+// startSpan starts a child span of c.ctx carrying the copy's attributes and
+// attrs.
+func (c *copier) startSpan(name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append([]attribute.KeyValue{
+		attrCopySource.String(aws.StringValue(c.in.COI.CopySource)),
+		attrBucket.String(aws.StringValue(c.in.COI.Bucket)),
+		attrKey.String(aws.StringValue(c.in.COI.Key)),
+	}, attrs...)
+	if c.MultipartUploadID != nil {
+		attrs = append(attrs, attrUploadID.String(*c.MultipartUploadID))
+	}
+
+	return c.tracer().Start(c.ctx, name, trace.WithAttributes(attrs...))
+}
+
+// requestOptions returns the Copier's request options plus one recording the
+// AWS request ID on span.
+func (c *copier) requestOptions(span trace.Span) []request.Option {
+	opts := make([]request.Option, 0, len(c.cfg.RequestOptions)+1)
+	opts = append(opts, c.cfg.RequestOptions...)
+	return append(opts, func(r *request.Request) {
+		r.Handlers.Complete.PushBack(func(r *request.Request) {
+			if r.RequestID != "" {
+				span.SetAttributes(attrRequestID.String(r.RequestID))
+			}
+		})
+	})
+}
+
+// endSpan records err, if any, on span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		if rf, ok := err.(awserr.RequestFailure); ok && rf.RequestID() != "" {
+			span.SetAttributes(attrRequestID.String(rf.RequestID()))
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,134 @@
+package s3cp_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/reedobrien/checkers"
+	s3cp "github.com/reedobrien/s3cp/lib"
+	"github.com/reedobrien/s3cp/lib/dummy"
+)
+
+func newTracedCopier(api s3cp.API, exp *tracetest.InMemoryExporter) *s3cp.Copier {
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	return s3cp.NewCopier(api,
+		func(c *s3cp.Copier) { c.PartSize = 10 },
+		func(c *s3cp.Copier) { c.Concurrency = 2 },
+		func(c *s3cp.Copier) { c.TracerProvider = tp },
+	)
+}
+
+func spanAttrs(s tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range s.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestCopySpans(t *testing.T) {
+	api := dummy.NewS3API("", func(d *dummy.S3API) {
+		d.Hoo = &s3.HeadObjectOutput{ContentLength: aws.Int64(20)}
+		d.Cmp = &s3.CreateMultipartUploadOutput{UploadId: aws.String("an-id")}
+		d.Upc = &s3.UploadPartCopyOutput{
+			CopyPartResult: &s3.CopyPartResult{ETag: aws.String("someetag")},
+		}
+		d.Doo = &s3.DeleteObjectOutput{}
+	})
+	in := s3cp.CopyInput{
+		Delete: true,
+		COI: s3.CopyObjectInput{
+			Bucket:     aws.String("abucket"),
+			CopySource: aws.String("bucket/key"),
+			Key:        aws.String("akey"),
+		},
+	}
+	exp := tracetest.NewInMemoryExporter()
+
+	err := newTracedCopier(api, exp).Copy(in)
+	checkers.OK(t, err)
+
+	spans := exp.GetSpans()
+	root := spans[len(spans)-1]
+	checkers.Equals(t, root.Name, "s3cp.Copy")
+
+	var names []string
+	for _, s := range spans[:len(spans)-1] {
+		names = append(names, s.Name)
+		checkers.Equals(t, s.Parent.SpanID(), root.SpanContext.SpanID())
+		checkers.Equals(t, s.SpanContext.TraceID(), root.SpanContext.TraceID())
+
+		attrs := spanAttrs(s)
+		checkers.Equals(t, attrs["aws.s3.bucket"].AsString(), "abucket")
+		checkers.Equals(t, attrs["aws.s3.key"].AsString(), "akey")
+		checkers.Equals(t, attrs["aws.s3.copy_source"].AsString(), "bucket/key")
+
+		if s.Name == "s3cp.UploadPartCopy" {
+			checkers.Equals(t, attrs["aws.s3.upload_id"].AsString(), "an-id")
+			checkers.Equals(t, attrs["s3cp.retry"].AsInt64(), int64(0))
+			switch attrs["aws.s3.part_number"].AsInt64() {
+			case 1:
+				checkers.Equals(t, attrs["s3cp.range"].AsString(), "bytes=0-9")
+			case 2:
+				checkers.Equals(t, attrs["s3cp.range"].AsString(), "bytes=10-19")
+			default:
+				t.Errorf("unexpected part %v", attrs["aws.s3.part_number"])
+			}
+		}
+	}
+	sort.Strings(names)
+	checkers.Equals(t, names, []string{
+		"s3cp.CompleteMultipartUpload",
+		"s3cp.CreateMultipartUpload",
+		"s3cp.DeleteObject",
+		"s3cp.GetObjectTagging",
+		"s3cp.HeadBucket",
+		"s3cp.HeadBucket",
+		"s3cp.HeadObject",
+		"s3cp.UploadPartCopy",
+		"s3cp.UploadPartCopy",
+	})
+}
+
+func TestCopySpansError(t *testing.T) {
+	api := dummy.NewS3API("", func(d *dummy.S3API) {
+		d.Cmp = &s3.CreateMultipartUploadOutput{UploadId: aws.String("an-id")}
+		d.UpcErr = awserr.NewRequestFailure(
+			awserr.New("InternalError", "boom", nil), 500, "req-123")
+	})
+	in := s3cp.CopyInput{
+		Size: 20,
+		COI: s3.CopyObjectInput{
+			Bucket:     aws.String("abucket"),
+			CopySource: aws.String("bucket/key"),
+			Key:        aws.String("akey"),
+		},
+	}
+	exp := tracetest.NewInMemoryExporter()
+
+	err := newTracedCopier(api, exp).CopyWithContext(context.Background(), in)
+	checkers.Assert(t, err != nil, "expected an error")
+
+	byName := make(map[string]tracetest.SpanStub)
+	for _, s := range exp.GetSpans() {
+		byName[s.Name] = s
+	}
+
+	part := byName["s3cp.UploadPartCopy"]
+	checkers.Equals(t, part.Status.Code, codes.Error)
+	checkers.Equals(t, spanAttrs(part)["aws.request_id"].AsString(), "req-123")
+	checkers.Equals(t, len(part.Events), 1)
+
+	_, ok := byName["s3cp.AbortMultipartUpload"]
+	checkers.Assert(t, ok, "missing abort span")
+	checkers.Equals(t, byName["s3cp.Copy"].Status.Code, codes.Error)
+}
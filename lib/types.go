@@ -1,6 +1,8 @@
 package s3cp
 
 import (
+	"bytes"
+
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/s3"
 )
@@ -13,7 +15,17 @@ type copyPartResult struct {
 type multipartCopyInput struct {
 	PartNumber      int64
 	CopySourceRange *string
+	Size            int64
 	UploadID        *string
+
+	// CopySource and CopySourceIfMatch, if set, replace the
+	// CopyObjectInput's, for parts copied from another source.
+	CopySource        *string
+	CopySourceIfMatch *string
+
+	// Body, if not nil, is uploaded with UploadPart instead of copying a
+	// range of the source.
+	Body []byte
 }
 
 func (m multipartCopyInput) FromCopyPartInput(c *s3.CopyObjectInput) *s3.UploadPartCopyInput {
@@ -24,8 +36,8 @@ func (m multipartCopyInput) FromCopyPartInput(c *s3.CopyObjectInput) *s3.UploadP
 
 		Bucket: c.Bucket,
 
-		CopySource:                     c.CopySource,
-		CopySourceIfMatch:              c.CopySourceIfMatch,
+		CopySource:                     firstString(m.CopySource, c.CopySource),
+		CopySourceIfMatch:              firstString(m.CopySourceIfMatch, c.CopySourceIfMatch),
 		CopySourceIfModifiedSince:      c.CopySourceIfModifiedSince,
 		CopySourceIfNoneMatch:          c.CopySourceIfNoneMatch,
 		CopySourceIfUnmodifiedSince:    c.CopySourceIfUnmodifiedSince,
@@ -33,6 +45,9 @@ func (m multipartCopyInput) FromCopyPartInput(c *s3.CopyObjectInput) *s3.UploadP
 		CopySourceSSECustomerKey:       c.CopySourceSSECustomerKey,
 		CopySourceSSECustomerKeyMD5:    c.CopySourceSSECustomerKeyMD5,
 
+		ExpectedBucketOwner:       c.ExpectedBucketOwner,
+		ExpectedSourceBucketOwner: c.ExpectedSourceBucketOwner,
+
 		Key: c.Key,
 
 		RequestPayer: c.RequestPayer,
@@ -42,3 +57,32 @@ func (m multipartCopyInput) FromCopyPartInput(c *s3.CopyObjectInput) *s3.UploadP
 		SSECustomerKeyMD5:    c.SSECustomerKeyMD5,
 	}
 }
+
+func (m multipartCopyInput) FromUploadPartInput(c *s3.CopyObjectInput) *s3.UploadPartInput {
+	return &s3.UploadPartInput{
+		Body:              bytes.NewReader(m.Body),
+		ChecksumAlgorithm: c.ChecksumAlgorithm,
+		ContentLength:     aws.Int64(int64(len(m.Body))),
+		PartNumber:        aws.Int64(m.PartNumber),
+		UploadId:          m.UploadID,
+
+		Bucket:              c.Bucket,
+		ExpectedBucketOwner: c.ExpectedBucketOwner,
+		Key:                 c.Key,
+		RequestPayer:        c.RequestPayer,
+
+		SSECustomerAlgorithm: c.SSECustomerAlgorithm,
+		SSECustomerKey:       c.SSECustomerKey,
+		SSECustomerKeyMD5:    c.SSECustomerKeyMD5,
+	}
+}
+
+// firstString returns the first of ss that isn't nil.
+func firstString(ss ...*string) *string {
+	for _, s := range ss {
+		if s != nil {
+			return s
+		}
+	}
+	return nil
+}
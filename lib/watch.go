@@ -0,0 +1,499 @@
+package s3cp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// DefaultWatchInterval is how often Watch lists the source by default.
+const DefaultWatchInterval = time.Minute
+
+// DefaultWatchAttempts is how many times by default Watch tries to copy a
+// key before giving up on it.
+const DefaultWatchAttempts = 5
+
+// DefaultSequencerAge is how long by default a watch keeps the sequencer of
+// the last event copied for a key.
+const DefaultSequencerAge = 24 * time.Hour
+
+// WatchInput is a parameter container for Copier.Watch and
+// Copier.WatchEvents.
+type WatchInput struct {
+	// Source is the bucket and key prefix of the objects to mirror.
+	Source Location
+
+	// Destination is the bucket and key prefix they are copied to. Each
+	// key has the Source prefix replaced with the Destination's.
+	Destination Location
+
+	// Template is the CopyInput each new object is copied with, e.g. with
+	// Encryption set. Its size and keys are filled in per object, which is
+	// only copied while it has the ETag it was listed or notified with.
+	Template CopyInput
+
+	// Cursor, if set, is where to resume a previous watch.
+	Cursor *WatchCursor
+
+	// Checkpoint, if set, is called with the cursor whenever keys have been
+	// copied, e.g. to save it for a restart. An error stops the watch.
+	Checkpoint func(WatchCursor) error
+
+	// Interval is how often Watch lists the source. Defaults to
+	// DefaultWatchInterval.
+	Interval time.Duration
+
+	// How many objects to copy at once. Defaults to
+	// DefaultObjectConcurrency.
+	Concurrency int
+
+	// MaxAttempts is how many polls Watch tries to copy a key in before
+	// adding it to the cursor's DeadLetters and moving past it. Defaults to
+	// DefaultWatchAttempts.
+	MaxAttempts int
+
+	// SequencerAge is how long the sequencer of the last event copied for
+	// a key is kept. A repeated event arriving later is copied again.
+	// Defaults to DefaultSequencerAge.
+	SequencerAge time.Duration
+}
+
+// WatchCursor is how far a watch has got.
+type WatchCursor struct {
+	// StartAfter is the last key listed that it and every key before it
+	// were copied or given up on.
+	StartAfter string `json:"startAfter,omitempty"`
+
+	// Sequencers are the sequencers of the last event copied for each key,
+	// so events that are repeated or arrive out of order are skipped.
+	Sequencers map[string]WatchSequencer `json:"sequencers,omitempty"`
+
+	// Attempts counts the polls each key after StartAfter has failed in.
+	Attempts map[string]int `json:"attempts,omitempty"`
+
+	// DeadLetters are the keys Watch gave up on after MaxAttempts.
+	DeadLetters []string `json:"deadLetters,omitempty"`
+
+	// Finished are the keys after StartAfter that were copied or given up
+	// on while one before them was still failing. Polls skip them until
+	// StartAfter passes them.
+	Finished []string `json:"finished,omitempty"`
+}
+
+// WatchSequencer is the sequencer of the last event copied for a key and
+// when it was copied.
+type WatchSequencer struct {
+	Sequencer string    `json:"sequencer"`
+	Copied    time.Time `json:"copied"`
+}
+
+// Watch mirrors new objects under the source prefix until ctx is done. Every
+// Interval it lists the keys after the cursor's StartAfter and copies them,
+// moving StartAfter up to the first that fails, which is retried until it has
+// failed MaxAttempts times and is added to the DeadLetters. Keys copied past
+// a failing one are kept in the cursor's Finished so they aren't copied
+// again. Only keys
+// that sort after those seen are picked up, so it suits keys that grow, e.g.
+// with a timestamp; WatchEvents also sees objects that are overwritten.
+// progress, if not nil, is called with each object's result; calls are
+// serialized. Listing errors are logged and retried.
+func (c Copier) Watch(ctx aws.Context, in WatchInput, progress func(ObjectResult)) error {
+	w, err := c.newWatcher(ctx, in, progress)
+	if err != nil {
+		return err
+	}
+
+	interval := in.Interval
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.poll(ctx); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if errors.Is(err, errCheckpoint) {
+				return err
+			}
+			c.logger().Error("failed to list source", append([]interface{}{"source", in.Source.String()}, errAttrs(err)...)...)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// WatchEvents copies the objects created in the S3 event notifications read
+// from r, JSON messages one after another, until r ends or ctx is done.
+// Records for other objects or events are ignored, and those not later than
+// the cursor's sequencer for their key are skipped. progress, if not nil, is
+// called with each object's result; calls are serialized. A failed copy
+// doesn't stop it, but an invalid message does.
+func (c Copier) WatchEvents(ctx aws.Context, in WatchInput, r io.Reader, progress func(ObjectResult)) error {
+	w, err := c.newWatcher(ctx, in, progress)
+	if err != nil {
+		return err
+	}
+	return ReadEvents(r, func(records []EventRecord) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		_, err := w.copyEvents(ctx, records)
+		return err
+	})
+}
+
+// EventHandler returns a handler copying the objects created in the S3 event
+// notification POSTed to it, as WatchEvents does. It responds once they are
+// copied, with an error status if any failed so the sender retries.
+func (c Copier) EventHandler(ctx aws.Context, in WatchInput, progress func(ObjectResult)) (http.Handler, error) {
+	w, err := c.newWatcher(ctx, in, progress)
+	if err != nil {
+		return nil, err
+	}
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(rw, "POST an S3 event notification", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		records, err := ParseEvent(body)
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sum, err := w.copyEvents(req.Context(), records)
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if sum.Failed > 0 {
+			http.Error(rw, fmt.Sprintf("%d of %d objects failed", sum.Failed, sum.Copied+sum.Failed), http.StatusInternalServerError)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}), nil
+}
+
+// errCheckpoint wraps a Checkpoint's error, which stops a watch.
+var errCheckpoint = errors.New("checkpoint failed")
+
+// watcher copies the objects a watch finds and keeps its cursor.
+type watcher struct {
+	c        Copier
+	lister   Copier
+	in       WatchInput
+	progress func(ObjectResult)
+
+	// mu serializes batches of copies, so the copies of one key are made
+	// in order and the cursor is only changed by one at a time.
+	mu     sync.Mutex
+	cursor WatchCursor
+}
+
+func (c Copier) newWatcher(ctx aws.Context, in WatchInput, progress func(ObjectResult)) (*watcher, error) {
+	if err := in.validate(); err != nil {
+		return nil, err
+	}
+	lister, err := c.sourceLister(ctx, in.Source, in.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	if in.MaxAttempts <= 0 {
+		in.MaxAttempts = DefaultWatchAttempts
+	}
+	if in.SequencerAge <= 0 {
+		in.SequencerAge = DefaultSequencerAge
+	}
+	w := &watcher{c: c, lister: lister, in: in, progress: progress}
+	if in.Cursor != nil {
+		w.cursor = *in.Cursor
+	}
+	w.cursor = w.cursor.clone()
+	return w, nil
+}
+
+// poll lists the keys after the cursor and copies them.
+func (w *watcher) poll(ctx aws.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	src := w.in.Source
+	owner, payer := w.in.Template.COI.ExpectedSourceBucketOwner, w.in.Template.SourceRequestPayer
+
+	var (
+		listed   []string
+		mu       sync.Mutex
+		failed   = make(map[string]bool)
+		finished = make(map[string]bool, len(w.cursor.Finished))
+	)
+	for _, key := range w.cursor.Finished {
+		finished[key] = true
+	}
+	b := &bulk{progress: w.progress}
+	err := b.run(ctx, w.in.Concurrency, func(ctx aws.Context, out chan<- *s3.Object) error {
+		return w.lister.listObjectsAfter(ctx, src.Bucket, src.Key, w.cursor.StartAfter, aws.StringValue(owner), payer, func(o *s3.Object) bool {
+			if finished[aws.StringValue(o.Key)] {
+				listed = append(listed, aws.StringValue(o.Key))
+				return true
+			}
+			select {
+			case out <- o:
+				listed = append(listed, aws.StringValue(o.Key))
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+	}, func(ctx aws.Context, o *s3.Object) ObjectResult {
+		key := aws.StringValue(o.Key)
+		r := ObjectResult{Key: key, Err: w.copy(ctx, Location{Bucket: src.Bucket, Key: key}, aws.Int64Value(o.Size), o.ETag)}
+		if r.Err != nil {
+			mu.Lock()
+			failed[key] = true
+			mu.Unlock()
+		}
+		return r
+	})
+
+	// Copies cut short by ctx ending aren't counted as attempts.
+	var (
+		dead    map[string]bool
+		changed bool
+	)
+	if ctx.Err() == nil {
+		dead, changed = w.countFailures(listed, failed)
+	}
+	startAfter, blocked := w.cursor.StartAfter, false
+	var done []string
+	for _, key := range listed {
+		switch {
+		case failed[key] && !dead[key]:
+			blocked = true
+			continue
+		case blocked:
+			done = append(done, key)
+		default:
+			startAfter = key
+		}
+		delete(w.cursor.Attempts, key)
+	}
+	if changed || startAfter != w.cursor.StartAfter || !slices.Equal(done, w.cursor.Finished) {
+		w.cursor.StartAfter, w.cursor.Finished = startAfter, done
+		if cerr := w.checkpoint(); cerr != nil {
+			return cerr
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("error listing %s: %s", src, err)
+	}
+	return nil
+}
+
+// countFailures counts a failed attempt for each failed key and returns
+// those that have now had MaxAttempts, which are added to the DeadLetters.
+// It reports whether the cursor changed.
+func (w *watcher) countFailures(listed []string, failed map[string]bool) (map[string]bool, bool) {
+	dead := make(map[string]bool)
+	var changed bool
+	for _, key := range listed {
+		if !failed[key] {
+			continue
+		}
+		changed = true
+		w.cursor.Attempts[key]++
+		if w.cursor.Attempts[key] < w.in.MaxAttempts {
+			continue
+		}
+		dead[key] = true
+		w.cursor.DeadLetters = append(w.cursor.DeadLetters, key)
+		w.c.logger().Warn("gave up copying", "source", w.in.Source.String(), "key", key, "attempts", w.in.MaxAttempts)
+	}
+	return dead, changed
+}
+
+// copyEvents copies the objects created in records that are under the source
+// prefix and later than the cursor. Only the latest record for a key is
+// copied. It returns an error only if the checkpoint fails.
+func (w *watcher) copyEvents(ctx aws.Context, records []EventRecord) (Summary, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	b := &bulk{progress: w.progress}
+	latest := make(map[string]EventRecord)
+	var keys []string
+	for _, rec := range records {
+		if !rec.Created() || rec.S3.Bucket.Name != w.in.Source.Bucket {
+			continue
+		}
+		loc, err := rec.Location()
+		if err != nil {
+			b.report(ObjectResult{Key: rec.S3.Object.Key, Err: err})
+			continue
+		}
+		if !strings.HasPrefix(loc.Key, w.in.Source.Key) {
+			continue
+		}
+		seq := rec.S3.Object.Sequencer
+		if seq != "" && !sequencerAfter(seq, w.cursor.Sequencers[loc.Key].Sequencer) {
+			b.report(ObjectResult{Key: loc.Key, Skipped: true})
+			continue
+		}
+		prev, seen := latest[loc.Key]
+		if !seen {
+			keys = append(keys, loc.Key)
+		}
+		if !seen || seq == "" || sequencerAfter(seq, prev.S3.Object.Sequencer) {
+			latest[loc.Key] = rec
+		}
+	}
+	if len(keys) == 0 {
+		return b.sum, nil
+	}
+
+	var (
+		mu     sync.Mutex
+		copied []string
+	)
+	// Sending the keys only fails once ctx is done, which fails their
+	// copies too.
+	b.run(ctx, w.in.Concurrency, func(ctx aws.Context, out chan<- *s3.Object) error {
+		for _, key := range keys {
+			select {
+			case out <- &s3.Object{Key: aws.String(key)}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	}, func(ctx aws.Context, o *s3.Object) ObjectResult {
+		key := aws.StringValue(o.Key)
+		rec := latest[key]
+		loc, _ := rec.Location()
+		r := ObjectResult{Key: key, Err: w.copy(ctx, loc, rec.S3.Object.Size, rec.S3.Object.IfMatch())}
+		if r.Err == nil {
+			mu.Lock()
+			copied = append(copied, key)
+			mu.Unlock()
+		}
+		return r
+	})
+
+	now := time.Now()
+	changed := w.pruneSequencers(now)
+	for _, key := range copied {
+		if seq := latest[key].S3.Object.Sequencer; seq != "" {
+			w.cursor.Sequencers[key] = WatchSequencer{Sequencer: seq, Copied: now}
+			changed = true
+		}
+	}
+	if changed {
+		return b.sum, w.checkpoint()
+	}
+	return b.sum, nil
+}
+
+// copy copies the source object loc to its key under the destination prefix.
+// If etag is set the copy is of size bytes and only of the object with that
+// ETag. If the object has been replaced since, it is HEADed and copied as it
+// is now, rather than copying size bytes of another object.
+func (w *watcher) copy(ctx aws.Context, loc Location, size int64, etag *string) error {
+	ci := w.in.Template
+	ci.COI.Bucket = aws.String(w.in.Destination.Bucket)
+	ci.COI.Key = aws.String(w.in.Destination.Key + strings.TrimPrefix(loc.Key, w.in.Source.Key))
+	ci.COI.CopySource = aws.String(loc.CopySource())
+	if etag == nil {
+		return w.c.CopyWithContext(ctx, ci)
+	}
+
+	ci.Size = size
+	ci.COI.CopySourceIfMatch = etag
+	err := w.c.CopyWithContext(ctx, ci)
+	if !PreconditionFailed(err) {
+		return err
+	}
+	impl := copier{in: ci, cfg: w.lister, ctx: ctx}
+	info, err := impl.headSource(w.lister.S3, loc)
+	if err != nil {
+		return err
+	}
+	ci.Size = aws.Int64Value(info.ContentLength)
+	ci.COI.CopySourceIfMatch = info.ETag
+	return w.c.CopyWithContext(ctx, ci)
+}
+
+// pruneSequencers drops the sequencers copied more than SequencerAge before
+// now. It reports whether any were.
+func (w *watcher) pruneSequencers(now time.Time) bool {
+	var pruned bool
+	for key, s := range w.cursor.Sequencers {
+		if now.Sub(s.Copied) > w.in.SequencerAge {
+			delete(w.cursor.Sequencers, key)
+			pruned = true
+		}
+	}
+	return pruned
+}
+
+// checkpoint passes a copy of the cursor to the Checkpoint.
+func (w *watcher) checkpoint() error {
+	if w.in.Checkpoint == nil {
+		return nil
+	}
+	if err := w.in.Checkpoint(w.cursor.clone()); err != nil {
+		return fmt.Errorf("%w: %s", errCheckpoint, err)
+	}
+	return nil
+}
+
+// clone returns a copy of c that shares none of its maps or slices, with
+// the maps made.
+func (c WatchCursor) clone() WatchCursor {
+	out := WatchCursor{
+		StartAfter:  c.StartAfter,
+		Sequencers:  make(map[string]WatchSequencer, len(c.Sequencers)),
+		Attempts:    make(map[string]int, len(c.Attempts)),
+		DeadLetters: append([]string(nil), c.DeadLetters...),
+		Finished:    append([]string(nil), c.Finished...),
+	}
+	for k, v := range c.Sequencers {
+		out.Sequencers[k] = v
+	}
+	for k, v := range c.Attempts {
+		out.Attempts[k] = v
+	}
+	return out
+}
+
+func (in *WatchInput) validate() error {
+	src, dst := in.Source, in.Destination
+	if src.Bucket == "" || dst.Bucket == "" {
+		return errors.New("a watch requires source and destination buckets")
+	}
+	if src.VersionID != "" || dst.VersionID != "" {
+		return errors.New("a watch can't have a version ID")
+	}
+	// Each copy would be a new key under the source for the next poll.
+	if src.Bucket == dst.Bucket && strings.HasPrefix(dst.Key, src.Key) {
+		return fmt.Errorf("destination %s is under source %s", dst, src)
+	}
+	return nil
+}
@@ -0,0 +1,315 @@
+package s3cp_test
+
+import (
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/reedobrien/checkers"
+	s3cp "github.com/reedobrien/s3cp/lib"
+	"github.com/reedobrien/s3cp/lib/dummy"
+)
+
+func newWatchFake(keys ...string) *dummy.Fake {
+	src := make(map[string]*dummy.Object)
+	for _, k := range keys {
+		src[k] = &dummy.Object{Data: []byte(k)}
+	}
+	return dummy.NewFakeWith(dummy.Buckets{"src": src, "dst": nil})
+}
+
+// watchInput mirrors src/in/ to dst/mirror/, polling every millisecond.
+var watchInput = s3cp.WatchInput{
+	Source:      s3cp.Location{Bucket: "src", Key: "in/"},
+	Destination: s3cp.Location{Bucket: "dst", Key: "mirror/"},
+	Interval:    time.Millisecond,
+}
+
+func TestWatch(t *testing.T) {
+	fake := newWatchFake("in/a", "in/b", "other/c")
+	tut := s3cp.NewCopier(fake)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var cursors []string
+	in := watchInput
+	in.Checkpoint = func(c s3cp.WatchCursor) error {
+		cursors = append(cursors, c.StartAfter)
+		switch len(cursors) {
+		case 1:
+			// in/a0 sorts before the cursor, so it isn't seen.
+			fake.PutObject("src", "in/a0", &dummy.Object{Data: []byte("in/a0")})
+			fake.PutObject("src", "in/c", &dummy.Object{Data: []byte("in/c")})
+		case 2:
+			cancel()
+		}
+		return nil
+	}
+
+	var copied []string
+	err := tut.Watch(ctx, in, func(r s3cp.ObjectResult) {
+		checkers.OK(t, r.Err)
+		copied = append(copied, r.Key)
+	})
+	checkers.OK(t, err)
+	checkers.Equals(t, cursors, []string{"in/b", "in/c"})
+	checkers.Equals(t, len(copied), 3)
+	checkers.Equals(t, fake.Keys("dst"), []string{"mirror/a", "mirror/b", "mirror/c"})
+	checkers.Equals(t, fake.Object("dst", "mirror/c").Data, []byte("in/c"))
+}
+
+func TestWatchResumesAndRetries(t *testing.T) {
+	fake := newWatchFake("in/a", "in/b", "in/c", "in/d")
+	api := &failingCopyAPI{Fake: fake, key: "in/c"}
+	tut := s3cp.NewCopier(api)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		cursors  []string
+		finished [][]string
+	)
+	in := watchInput
+	in.Cursor = &s3cp.WatchCursor{StartAfter: "in/a"}
+	in.Concurrency = 1
+	in.Checkpoint = func(c s3cp.WatchCursor) error {
+		cursors = append(cursors, c.StartAfter)
+		finished = append(finished, c.Finished)
+		if len(cursors) == 1 {
+			// The next poll retries in/c but skips in/d, copied past it.
+			api.key = ""
+		} else {
+			cancel()
+		}
+		return nil
+	}
+
+	var (
+		failed int
+		copied []string
+	)
+	err := tut.Watch(ctx, in, func(r s3cp.ObjectResult) {
+		if r.Err != nil {
+			failed++
+			return
+		}
+		copied = append(copied, r.Key)
+	})
+	checkers.OK(t, err)
+	checkers.Equals(t, failed, 1)
+	checkers.Equals(t, copied, []string{"in/b", "in/d", "in/c"})
+	checkers.Equals(t, cursors, []string{"in/b", "in/d"})
+	checkers.Equals(t, finished, [][]string{{"in/d"}, nil})
+	checkers.Equals(t, fake.Keys("dst"), []string{"mirror/b", "mirror/c", "mirror/d"})
+}
+
+func TestWatchGivesUp(t *testing.T) {
+	fake := newWatchFake("in/a", "in/b", "in/c")
+	tut := s3cp.NewCopier(&failingCopyAPI{Fake: fake, key: "in/b"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var cursors []s3cp.WatchCursor
+	in := watchInput
+	in.MaxAttempts = 2
+	in.Checkpoint = func(c s3cp.WatchCursor) error {
+		cursors = append(cursors, c)
+		if len(cursors) == 2 {
+			cancel()
+		}
+		return nil
+	}
+
+	err := tut.Watch(ctx, in, nil)
+	checkers.OK(t, err)
+	checkers.Equals(t, len(cursors), 2)
+	checkers.Equals(t, cursors[0].StartAfter, "in/a")
+	checkers.Equals(t, cursors[0].Attempts, map[string]int{"in/b": 1})
+	checkers.Equals(t, cursors[1].StartAfter, "in/c")
+	checkers.Equals(t, cursors[1].Attempts, map[string]int{})
+	checkers.Equals(t, cursors[1].DeadLetters, []string{"in/b"})
+	checkers.Equals(t, fake.Keys("dst"), []string{"mirror/a", "mirror/c"})
+}
+
+func TestWatchCheckpointError(t *testing.T) {
+	fake := newWatchFake("in/a")
+	in := watchInput
+	in.Checkpoint = func(s3cp.WatchCursor) error { return errors.New("disk full") }
+
+	err := s3cp.NewCopier(fake).Watch(context.Background(), in, nil)
+	checkers.Assert(t, err != nil, "expected an error")
+	checkers.Equals(t, err.Error(), "checkpoint failed: disk full")
+}
+
+// event returns an S3 event notification of the records, each an event name,
+// key and sequencer.
+func event(records ...[3]string) string {
+	var rs []string
+	for _, r := range records {
+		rs = append(rs, fmt.Sprintf(`{"eventVersion":"2.1","eventSource":"aws:s3","eventName":%q,"s3":{"bucket":{"name":"src"},"object":{"key":%q,"size":4,"sequencer":%q}}}`, r[0], r[1], r[2]))
+	}
+	return `{"Records":[` + strings.Join(rs, ",") + `]}`
+}
+
+func TestWatchEvents(t *testing.T) {
+	fake := newWatchFake("in/a", "in/b+c", "in/d", "other/e")
+	fake.PutObject("src", "in/b c", &dummy.Object{Data: []byte("in/b c")})
+	fake.PutObject("src", "in/a", &dummy.Object{Data: []byte("in/A")})
+	tut := s3cp.NewCopier(fake)
+
+	var cursor s3cp.WatchCursor
+	in := watchInput
+	// in/old's sequencer is past its age, so it is dropped.
+	in.Cursor = &s3cp.WatchCursor{Sequencers: map[string]s3cp.WatchSequencer{
+		"in/d":   {Sequencer: "0055AED6DCD90281E6", Copied: time.Now()},
+		"in/old": {Sequencer: "0055AED6DCD90281E1", Copied: time.Now().Add(-s3cp.DefaultSequencerAge - time.Minute)},
+	}}
+	in.Checkpoint = func(c s3cp.WatchCursor) error {
+		cursor = c
+		return nil
+	}
+
+	events := strings.Join([]string{
+		`{"Service":"Amazon S3","Event":"s3:TestEvent","Bucket":"src"}`,
+		event(
+			[3]string{"ObjectCreated:Put", "in/a", "0055AED6DCD90281E5"},
+			[3]string{"ObjectCreated:Put", "in/b+c", "0055AED6DCD90281E5"},
+			[3]string{"ObjectCreated:Put", "other/e", "0055AED6DCD90281E5"},
+			[3]string{"ObjectRemoved:Delete", "in/gone", "0055AED6DCD90281E5"},
+		),
+		// in/d is older than the cursor, and in/a was copied already.
+		event(
+			[3]string{"ObjectCreated:Put", "in/d", "0055AED6DCD90281E4"},
+			[3]string{"ObjectCreated:Copy", "in/a", "0055AED6DCD90281E5"},
+		),
+	}, "\n")
+
+	var results []s3cp.ObjectResult
+	err := tut.WatchEvents(context.Background(), in, strings.NewReader(events), func(r s3cp.ObjectResult) {
+		results = append(results, r)
+	})
+	checkers.OK(t, err)
+	checkers.Equals(t, len(results), 4)
+	checkers.Equals(t, fake.Keys("dst"), []string{"mirror/a", "mirror/b c"})
+	checkers.Equals(t, fake.Object("dst", "mirror/a").Data, []byte("in/A"))
+	checkers.Equals(t, fake.Object("dst", "mirror/b c").Data, []byte("in/b c"))
+	sequencers := make(map[string]string)
+	for k, s := range cursor.Sequencers {
+		sequencers[k] = s.Sequencer
+	}
+	checkers.Equals(t, sequencers, map[string]string{
+		"in/a":   "0055AED6DCD90281E5",
+		"in/b c": "0055AED6DCD90281E5",
+		"in/d":   "0055AED6DCD90281E6",
+	})
+	for _, r := range results[2:] {
+		checkers.Assert(t, r.Skipped, "expected %s to be skipped", r.Key)
+	}
+}
+
+func TestWatchEventsReplaced(t *testing.T) {
+	old := []byte(strings.Repeat("old", 10))
+	now := []byte(strings.Repeat("now", 20))
+	fake := newWatchFake()
+	fake.PutObject("src", "in/a", &dummy.Object{Data: now})
+	tut := s3cp.NewCopier(fake, func(c *s3cp.Copier) { c.PartSize = 10 })
+
+	// The event is for an earlier, shorter object under the key. Copying
+	// its size would truncate the one there now.
+	e := fmt.Sprintf(`{"Records":[{"eventName":"ObjectCreated:Put","s3":{"bucket":{"name":"src"},"object":{"key":"in/a","size":%d,"eTag":"%x"}}}]}`, len(old), md5.Sum(old))
+	err := tut.WatchEvents(context.Background(), watchInput, strings.NewReader(e), func(r s3cp.ObjectResult) {
+		checkers.OK(t, r.Err)
+	})
+	checkers.OK(t, err)
+	checkers.Equals(t, fake.Object("dst", "mirror/a").Data, now)
+}
+
+func TestWatchEventsInvalid(t *testing.T) {
+	fake := newWatchFake()
+	err := s3cp.NewCopier(fake).WatchEvents(context.Background(), watchInput, strings.NewReader(`{"Records":[`), nil)
+	checkers.Assert(t, err != nil, "expected an error")
+	checkers.Equals(t, err.Error(), "invalid event: unexpected EOF")
+}
+
+func TestEventHandler(t *testing.T) {
+	fake := newWatchFake("in/a")
+	h, err := s3cp.NewCopier(fake).EventHandler(context.Background(), watchInput, nil)
+	checkers.OK(t, err)
+
+	for _, tc := range []struct {
+		name   string
+		method string
+		body   string
+		status int
+	}{
+		{name: "copied", method: http.MethodPost, body: event([3]string{"ObjectCreated:Put", "in/a", "01"}), status: http.StatusNoContent},
+		{name: "failed", method: http.MethodPost, body: event([3]string{"ObjectCreated:Put", "in/nope", "01"}), status: http.StatusInternalServerError},
+		{name: "invalid", method: http.MethodPost, body: "{", status: http.StatusBadRequest},
+		{name: "get", method: http.MethodGet, status: http.StatusMethodNotAllowed},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(tc.method, "/", strings.NewReader(tc.body)))
+			checkers.Equals(t, rec.Code, tc.status)
+		})
+	}
+	checkers.Equals(t, fake.Keys("dst"), []string{"mirror/a"})
+}
+
+func TestWatchErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		src, dst s3cp.Location
+		err      string
+	}{
+		{
+			name: "no buckets",
+			src:  s3cp.Location{Key: "in/"},
+			err:  "a watch requires source and destination buckets",
+		},
+		{
+			name: "version",
+			src:  s3cp.Location{Bucket: "src", Key: "in/", VersionID: "v1"},
+			dst:  s3cp.Location{Bucket: "dst"},
+			err:  "a watch can't have a version ID",
+		},
+		{
+			name: "same prefix",
+			src:  s3cp.Location{Bucket: "src", Key: "in/"},
+			dst:  s3cp.Location{Bucket: "src", Key: "in/"},
+			err:  "destination s3://src/in/ is under source s3://src/in/",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			in := s3cp.WatchInput{Source: tc.src, Destination: tc.dst}
+			err := s3cp.NewCopier(newWatchFake()).Watch(context.Background(), in, nil)
+			checkers.Assert(t, err != nil, "expected an error")
+			checkers.Equals(t, err.Error(), tc.err)
+		})
+	}
+}
+
+// failingCopyAPI fails copies of the source key.
+type failingCopyAPI struct {
+	*dummy.Fake
+	key string
+}
+
+func (a *failingCopyAPI) CopyObjectWithContext(ctx aws.Context, in *s3.CopyObjectInput, opts ...request.Option) (*s3.CopyObjectOutput, error) {
+	if a.key != "" && strings.HasSuffix(aws.StringValue(in.CopySource), "/"+a.key) {
+		return nil, errors.New("InternalError: copy failed")
+	}
+	return a.Fake.CopyObjectWithContext(ctx, in, opts...)
+}
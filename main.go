@@ -1,29 +1,88 @@
 package main
 
 import (
+	"context"
+	"expvar"
 	"flag"
+	"fmt"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"path"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	s3cp "github.com/reedobrien/s3cp/lib"
+	"github.com/reedobrien/s3cp/lib/metrics"
 )
 
 var (
-	contentType = flag.String("contentType", "application/octet-stream", "The content type of object being copied.")
-	dest        = flag.String("dest", "", "The destination bucket and key.")
-	move        = flag.Bool("move", false, "Set to true to delete the file after copy.")
-	region      = flag.String("region", os.Getenv("AWS_DEFAULT_REGION"), "The region of the destination bucket.")
-	sha1        = flag.String("sha1", "", "The sha1 hash of the object.")
-	size        = flag.Int64("size", -1, "The size of the object being copied.")
-	source      = flag.String("source", "", "The source bucket and key. E.g. bucket/key/one")
-	srcRegion   = flag.String("srcRegion", "", "The source bucket region, if different from the destination region.")
+	acl                       = flag.String("acl", "", "The destination's canned ACL, e.g. bucket-owner-full-control.")
+	aclFromSource             = flag.Bool("aclFromSource", false, "Set to true to copy the source's ACL to the destination.")
+	checksum                  = flag.String("checksum", "", "If set, the destination's additional checksum algorithm, CRC32, CRC32C, SHA1 or SHA256, which is verified.")
+	contentType               = flag.String("contentType", "application/octet-stream", "The content type of object being copied.")
+	dest                      = newStringsFlag(flag.CommandLine, "dest", "The destination bucket and key. A bucket, or a key ending in /, gets the source key or its base name. Repeat to copy to several destinations at once, e.g. buckets in other regions.")
+	expectedBucketOwner       = flag.String("expectedBucketOwner", "", "If set, the account ID that must own the destination bucket.")
+	expectedSourceBucketOwner = flag.String("expectedSourceBucketOwner", "", "If set, the account ID that must own the source bucket.")
+	grantFullControl          = flag.String("grantFullControl", "", "Grantees given full control of the destination, e.g. id=\"canonical-id\".")
+	grantRead                 = flag.String("grantRead", "", "Grantees given read access to the destination.")
+	grantReadACP              = flag.String("grantReadACP", "", "Grantees given read access to the destination's ACL.")
+	grantWriteACP             = flag.String("grantWriteACP", "", "Grantees given write access to the destination's ACL.")
+	mapDatePartition          = flag.String("mapDatePartition", "", "With recursive, prefix keys with a Hive-style partition of their LastModified: year, month, day or hour.")
+	mapLower                  = flag.Bool("mapLower", false, "With recursive, set to true to lowercase keys.")
+	mapPattern                = flag.String("mapPattern", "", "With recursive, a regular expression replaced in each key, relative to the prefix, by mapTemplate.")
+	mapShards                 = flag.Int("mapShards", 0, "With recursive, prefix keys with one of this many hash-sharded prefixes.")
+	mapTemplate               = flag.String("mapTemplate", "", "The replacement for mapPattern, in which $1 or ${name} is a submatch.")
+	metricsAddr               = flag.String("metricsAddr", "", "If set, serve Prometheus metrics on /metrics and expvar on /debug/vars at this address.")
+	move                      = flag.Bool("move", false, "Set to true to delete the file after copy.")
+	plan                      = flag.Bool("plan", false, "Set to true to print what the copy would do, and what it costs us, without copying.")
+	preserveParts             = flag.Bool("preserveParts", false, "Set to true to copy the source in the parts it was uploaded in, so the destination has the same ETag.")
+	rangeHeader               = flag.String("range", "", "If set, copy only this range of the source, e.g. bytes=0-1023, bytes=1024- or bytes=-512.")
+	recursive                 = flag.Bool("recursive", false, "Set to true to copy every object under the source prefix, replacing it with the destination prefix.")
+	region                    = flag.String("region", "", "The region of the destination bucket. If empty it is discovered.")
+	requesterPays             = flag.Bool("requesterPays", false, "Set to true to pay for requests to a requester pays destination bucket.")
+	sha1                      = flag.String("sha1", "", "The sha1 hash of the object.")
+	size                      = flag.Int64("size", -1, "The size of the object being copied.")
+	source                    = flag.String("source", "", "The source bucket and key, e.g. bucket/key/one, s3://bucket/key/one?versionId=v1, an S3 URL or an access point ARN.")
+	srcRegion                 = flag.String("srcRegion", "", "The region of the source bucket. If empty it is discovered.")
+	srcRequesterPays          = flag.Bool("srcRequesterPays", false, "Set to true to pay for requests to, and data transfer out of, a requester pays source bucket.")
+
+	objectLockFromSource  = flag.Bool("objectLockFromSource", false, "Set to true to copy the source's Object Lock retention and legal hold.")
+	objectLockLegalHold   = flag.Bool("objectLockLegalHold", false, "Set to true to place a legal hold on the destination.")
+	objectLockMode        = flag.String("objectLockMode", "", "The destination's Object Lock retention mode: GOVERNANCE or COMPLIANCE.")
+	objectLockRetainUntil = flag.String("objectLockRetainUntil", "", "The RFC 3339 date the destination's retention expires.")
+
+	filters = addFilterFlags(flag.CommandLine)
+	logs    = addLogFlags(flag.CommandLine)
+	sse     = addEncryptionFlags(flag.CommandLine)
 )
 
+// commands are the subcommands, run as s3cp <command> [flags]. Without one
+// s3cp copies a single object.
+var commands = map[string]func(args []string){
+	"compose":   compose,
+	"reencrypt": reencrypt,
+	"rewrite":   rewrite,
+	"split":     split,
+	"watch":     watch,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			cmd(os.Args[2:])
+			return
+		}
+	}
+
 	var (
 		err      error
 		metadata map[string]*string
@@ -31,8 +90,26 @@ func main() {
 
 	flag.Parse()
 
-	// srcElems := strings.SplitN(*source, "/", 2)
-	destElems := strings.SplitN(*dest, "/", 2)
+	logger, err := logs.logger()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var (
+		src  s3cp.Location
+		dsts []s3cp.Location
+	)
+	if *recursive {
+		var dst s3cp.Location
+		src, dst, err = prefixesFromFlags()
+		dsts = []s3cp.Location{dst}
+	} else {
+		src, dsts, err = locationsFromFlags()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	dst := dsts[0]
 
 	if *sha1 != "" {
 		metadata = make(map[string]*string)
@@ -40,10 +117,10 @@ func main() {
 	}
 
 	coi := s3.CopyObjectInput{
-		Bucket:      &destElems[0],
+		Bucket:      aws.String(dst.Bucket),
 		ContentType: contentType,
-		CopySource:  source,
-		Key:         &destElems[1],
+		CopySource:  aws.String(src.CopySource()),
+		Key:         aws.String(dst.Key),
 	}
 
 	if metadata != nil {
@@ -51,22 +128,337 @@ func main() {
 		coi.MetadataDirective = aws.String("REPLACE")
 	}
 
+	if *requesterPays {
+		coi.RequestPayer = aws.String(s3.RequestPayerRequester)
+	}
+
+	if *expectedBucketOwner != "" {
+		coi.ExpectedBucketOwner = expectedBucketOwner
+	}
+	if *expectedSourceBucketOwner != "" {
+		coi.ExpectedSourceBucketOwner = expectedSourceBucketOwner
+	}
+
 	in := s3cp.CopyInput{
-		Delete:       *move,
-		Size:         *size,
-		Region:       region,
-		SourceRegion: srcRegion,
-		COI:          coi,
+		Delete:        *move,
+		Size:          *size,
+		Region:        region,
+		SourceRegion:  srcRegion,
+		PreserveParts: *preserveParts,
+		Checksum:      *checksum,
+		COI:           coi,
 	}
 
-	sess := session.Must(session.NewSession(&aws.Config{Region: in.Region}))
+	if *srcRequesterPays {
+		in.SourceRequestPayer = aws.String(s3.RequestPayerRequester)
+	}
 
-	copier := s3cp.NewCopier(s3.New(sess),
-		func(c *s3cp.Copier) { c.PartSize = s3cp.MinCopyPartSize },
-	)
+	if *rangeHeader != "" {
+		in.SourceRange = &s3cp.SourceRange{Header: *rangeHeader}
+		if err := in.SourceRange.Validate(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	in.Encryption, in.SourceEncryption, err = sse.encryption()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	in.ObjectLock, err = objectLockFromFlags()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *acl != "" || *aclFromSource || *grantFullControl != "" || *grantRead != "" || *grantReadACP != "" || *grantWriteACP != "" {
+		in.ACL = &s3cp.ACL{
+			Canned:           *acl,
+			FromSource:       *aclFromSource,
+			GrantFullControl: *grantFullControl,
+			GrantRead:        *grantRead,
+			GrantReadACP:     *grantReadACP,
+			GrantWriteACP:    *grantWriteACP,
+		}
+		if err := in.ACL.Validate(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	copier, err := newCopier(*region, logger)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *recursive {
+		copyPrefix(copier, src, dst, in, logger)
+		return
+	}
+
+	if len(dsts) > 1 {
+		fanOut(copier, src, dsts, in, logger)
+		return
+	}
+
+	if *plan {
+		p, err := copier.Plan(context.Background(), in)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Print(p)
+		return
+	}
+
+	if *metricsAddr != "" {
+		copier.Metrics, err = serveMetrics(*metricsAddr, logger)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	err = copier.Copy(in)
+	if err != nil {
+		logger.Error("copy failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+// copyPrefix copies the objects under src selected by the filter flags to
+// dst, with the settings of in and the keys mapped by the map flags, or
+// prints the plan for doing so.
+func copyPrefix(copier *s3cp.Copier, src, dst s3cp.Location, in s3cp.CopyInput, logger *slog.Logger) {
+	pin := s3cp.CopyPrefixInput{
+		Source:      src,
+		Destination: dst,
+		Template:    in,
+	}
+
+	var err error
+	pin.Filter, err = filters.filter()
+	if err != nil {
+		log.Fatal(err)
+	}
+	pin.Mapper, err = keyMapperFromFlags()
 	if err != nil {
 		log.Fatal(err)
 	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if *plan {
+		p, err := copier.PlanPrefix(ctx, pin)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Print(p)
+		return
+	}
+
+	if *metricsAddr != "" {
+		copier.Metrics, err = serveMetrics(*metricsAddr, logger)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	sum, err := copier.CopyPrefix(ctx, pin, func(r s3cp.ObjectResult) {
+		switch {
+		case r.Err != nil:
+			logger.Error("failed to copy", "key", r.Key, "error", r.Err)
+		case r.Filtered:
+			logger.Debug("filtered", "key", r.Key)
+		default:
+			logger.Info("copied", "key", r.Key)
+		}
+	})
+	logger.Info("copy finished", "copied", sum.Copied, "filtered", sum.Filtered, "failed", sum.Failed)
+	if err != nil {
+		logger.Error("copy failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+// fanOut copies src to every destination with the settings of in, deleting
+// it with move only if every copy succeeds.
+func fanOut(copier *s3cp.Copier, src s3cp.Location, dsts []s3cp.Location, in s3cp.CopyInput, logger *slog.Logger) {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var err error
+	if *metricsAddr != "" {
+		copier.Metrics, err = serveMetrics(*metricsAddr, logger)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	results, err := copier.FanOut(ctx, s3cp.FanOutInput{
+		Source:       src,
+		Destinations: dsts,
+		Template:     in,
+	})
+	for _, r := range results {
+		if r.Err != nil {
+			logger.Error("failed to copy", "dest", r.Destination.String(), "error", r.Err)
+			continue
+		}
+		logger.Info("copied", "dest", r.Destination.String())
+	}
+	if err != nil {
+		logger.Error("copy failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+// keyMapperFromFlags returns the KeyMapper set by the map flags, or nil if
+// none are set.
+func keyMapperFromFlags() (*s3cp.KeyMapper, error) {
+	if *mapPattern == "" && *mapTemplate == "" && !*mapLower && *mapDatePartition == "" && *mapShards == 0 {
+		return nil, nil
+	}
+
+	m := &s3cp.KeyMapper{
+		Pattern:       *mapPattern,
+		Template:      *mapTemplate,
+		Lower:         *mapLower,
+		DatePartition: *mapDatePartition,
+		Shards:        *mapShards,
+	}
+	return m, m.Validate()
+}
+
+// serveMetrics serves Prometheus and expvar metrics on addr in the
+// background and returns the s3cp.Metrics feeding them.
+func serveMetrics(addr string, logger *slog.Logger) (s3cp.Metrics, error) {
+	reg := prometheus.NewRegistry()
+	pm, err := metrics.NewPrometheus(reg)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	go func() {
+		err := http.ListenAndServe(addr, mux)
+		logger.Error("metrics server stopped", "addr", addr, "error", err)
+	}()
+
+	return metrics.Multi(pm, metrics.NewExpvar("s3cp")), nil
+}
+
+// newCopier returns a Copier logging to logger whose default client is in
+// region, or the one sessionRegion picks. It fails if the AWS session can't
+// be made, e.g. from an invalid shared config.
+func newCopier(region string, logger *slog.Logger) (*s3cp.Copier, error) {
+	sess, err := session.NewSession(&aws.Config{
+		Region:     aws.String(sessionRegion(region)),
+		HTTPClient: s3cp.NewHTTPClient(s3cp.DefaultCopyConcurrency),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating session: %s", err)
+	}
+	return s3cp.NewCopier(s3.New(sess),
+		func(c *s3cp.Copier) { c.PartSize = s3cp.MinCopyPartSize },
+		func(c *s3cp.Copier) { c.Logger = logger },
+	), nil
+}
+
+// sessionRegion returns the region of the default client, the destination's
+// if it is given, otherwise AWS_DEFAULT_REGION or us-east-1. Any region can
+// discover the others.
+func sessionRegion(region string) string {
+	if region != "" {
+		return region
+	}
+	if r := os.Getenv("AWS_DEFAULT_REGION"); r != "" {
+		return r
+	}
+	return "us-east-1"
+}
+
+// objectLockFromFlags returns the Object Lock settings set by the flags, or
+// nil if none are set.
+func objectLockFromFlags() (*s3cp.ObjectLock, error) {
+	if !*objectLockFromSource && !*objectLockLegalHold && *objectLockMode == "" && *objectLockRetainUntil == "" {
+		return nil, nil
+	}
+
+	l := &s3cp.ObjectLock{
+		FromSource: *objectLockFromSource,
+		LegalHold:  *objectLockLegalHold,
+		Mode:       *objectLockMode,
+	}
+	if *objectLockRetainUntil != "" {
+		t, err := time.Parse(time.RFC3339, *objectLockRetainUntil)
+		if err != nil {
+			return nil, fmt.Errorf("invalid objectLockRetainUntil: %s", err)
+		}
+		l.RetainUntil = t
+	}
+	return l, l.Validate()
+}
+
+// locationsFromFlags parses the source and destinations. A destination
+// without a key gets the source's key, and one ending in / gets the source
+// key's base name.
+func locationsFromFlags() (s3cp.Location, []s3cp.Location, error) {
+	src, err := s3cp.ParseLocation(*source)
+	if err != nil {
+		return src, nil, fmt.Errorf("source: %s", err)
+	}
+	if src.Key == "" || strings.HasSuffix(src.Key, "/") {
+		return src, nil, fmt.Errorf("source %s is not an object", src)
+	}
+
+	dests := []string(*dest)
+	if len(dests) == 0 {
+		dests = []string{""}
+	}
+	if len(dests) > 1 && *plan {
+		return src, nil, fmt.Errorf("plan can't be used with several dests")
+	}
+	var dsts []s3cp.Location
+	for _, d := range dests {
+		dst, err := s3cp.ParseLocation(d)
+		if err != nil {
+			return src, nil, fmt.Errorf("dest: %s", err)
+		}
+		if dst.VersionID != "" {
+			return src, nil, fmt.Errorf("dest %s can't have a version ID", dst)
+		}
+		switch {
+		case dst.Key == "":
+			dst.Key = src.Key
+		case strings.HasSuffix(dst.Key, "/"):
+			dst.Key += path.Base(src.Key)
+		}
+		dsts = append(dsts, dst)
+	}
+	return src, dsts, nil
+}
+
+// prefixesFromFlags parses the source and destination prefixes of a
+// recursive copy.
+func prefixesFromFlags() (s3cp.Location, s3cp.Location, error) {
+	if *sha1 != "" || *size >= 0 || *rangeHeader != "" {
+		return s3cp.Location{}, s3cp.Location{}, fmt.Errorf("sha1, size and range can't be used with recursive")
+	}
+
+	src, err := s3cp.ParseLocation(*source)
+	if err != nil {
+		return src, s3cp.Location{}, fmt.Errorf("source: %s", err)
+	}
+	if len(*dest) > 1 {
+		return src, s3cp.Location{}, fmt.Errorf("recursive takes one dest")
+	}
+	dst, err := s3cp.ParseLocation(dest.String())
+	if err != nil {
+		return src, dst, fmt.Errorf("dest: %s", err)
+	}
+	if src.VersionID != "" || dst.VersionID != "" {
+		return src, dst, fmt.Errorf("a recursive copy can't have a version ID")
+	}
+	return src, dst, nil
 }
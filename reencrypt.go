@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	s3cp "github.com/reedobrien/s3cp/lib"
+)
+
+// reencrypt rewrites objects in place with new server side encryption, e.g.
+// to rotate their KMS key.
+func reencrypt(args []string) {
+	fs := flag.NewFlagSet("reencrypt", flag.ExitOnError)
+	bucket := fs.String("bucket", "", "The bucket holding the objects.")
+	concurrency := fs.Int("concurrency", s3cp.DefaultObjectConcurrency, "How many objects to rewrite at once.")
+	expectedBucketOwner := fs.String("expectedBucketOwner", "", "If set, the account ID that must own the bucket.")
+	manifest := fs.String("manifest", "", "A file listing the keys to rewrite, one per line, or - for stdin. Overrides prefix.")
+	prefix := fs.String("prefix", "", "Rewrite every object under this prefix.")
+	filters := addFilterFlags(fs)
+	client := addClientFlags(fs)
+	logs := addLogFlags(fs)
+	sse := addEncryptionFlags(fs)
+	fs.Parse(args)
+
+	logger, err := logs.logger()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *bucket == "" {
+		log.Fatal("reencrypt requires a bucket")
+	}
+
+	dst, src, err := sse.encryption()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if dst == nil {
+		log.Fatal("reencrypt requires the new encryption, e.g. -sse aws:kms -sseKMSKeyID key")
+	}
+
+	filter, err := filters.filter()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	in := s3cp.ReencryptInput{
+		Bucket:           *bucket,
+		Prefix:           *prefix,
+		Encryption:       *dst,
+		Filter:           filter,
+		SourceEncryption: src,
+		Concurrency:      *concurrency,
+
+		ExpectedBucketOwner: *expectedBucketOwner,
+	}
+
+	if *manifest != "" {
+		in.Keys, err = readManifest(*manifest)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	copier, err := client.copier(logger)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	sum, err := copier.Reencrypt(ctx, in, func(r s3cp.ObjectResult) {
+		switch {
+		case r.Err != nil:
+			logger.Error("failed to reencrypt", "bucket", *bucket, "key", r.Key, "error", r.Err)
+		case r.Filtered:
+			logger.Debug("filtered", "bucket", *bucket, "key", r.Key)
+		case r.Skipped:
+			logger.Info("skipped", "bucket", *bucket, "key", r.Key)
+		default:
+			logger.Info("reencrypted", "bucket", *bucket, "key", r.Key)
+		}
+	})
+	logger.Info("reencrypt finished", "copied", sum.Copied, "skipped", sum.Skipped, "filtered", sum.Filtered, "failed", sum.Failed)
+	if err != nil {
+		logger.Error("reencrypt failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+// readManifest reads the non blank lines of the file at path, or of stdin if
+// path is -.
+func readManifest(path string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var keys []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if k := strings.TrimSpace(scanner.Text()); k != "" {
+			keys = append(keys, k)
+		}
+	}
+	return keys, scanner.Err()
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	s3cp "github.com/reedobrien/s3cp/lib"
+)
+
+// rewrite changes the storage class, content headers, metadata or tags of an
+// object in place.
+func rewrite(args []string) {
+	fs := flag.NewFlagSet("rewrite", flag.ExitOnError)
+	bucket := fs.String("bucket", "", "The bucket holding the object.")
+	key := fs.String("key", "", "The key of the object.")
+	expectedBucketOwner := fs.String("expectedBucketOwner", "", "If set, the account ID that must own the bucket.")
+	storageClass := fs.String("storageClass", "", "The new storage class, e.g. STANDARD_IA.")
+	cacheControl := fs.String("cacheControl", "", "The new Cache-Control.")
+	contentDisposition := fs.String("contentDisposition", "", "The new Content-Disposition.")
+	contentEncoding := fs.String("contentEncoding", "", "The new Content-Encoding.")
+	contentLanguage := fs.String("contentLanguage", "", "The new Content-Language.")
+	contentType := fs.String("contentType", "", "The new Content-Type.")
+	metadata := fs.String("metadata", "", "User metadata to merge as comma separated key=value pairs. An empty value removes the key.")
+	tags := fs.String("tags", "", "Tags to merge as comma separated key=value pairs. An empty value removes the tag.")
+	client := addClientFlags(fs)
+	logs := addLogFlags(fs)
+	sse := addEncryptionFlags(fs)
+	fs.Parse(args)
+
+	logger, err := logs.logger()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *bucket == "" || *key == "" {
+		log.Fatal("rewrite requires a bucket and key")
+	}
+
+	in := s3cp.RewriteInput{
+		Bucket:             *bucket,
+		Key:                *key,
+		StorageClass:       *storageClass,
+		CacheControl:       *cacheControl,
+		ContentDisposition: *contentDisposition,
+		ContentEncoding:    *contentEncoding,
+		ContentLanguage:    *contentLanguage,
+		ContentType:        *contentType,
+
+		ExpectedBucketOwner: *expectedBucketOwner,
+	}
+
+	in.Metadata, err = parsePairs("metadata", *metadata)
+	if err != nil {
+		log.Fatal(err)
+	}
+	in.Tags, err = parsePairs("tags", *tags)
+	if err != nil {
+		log.Fatal(err)
+	}
+	in.Encryption, in.SourceEncryption, err = sse.encryption()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	copier, err := client.copier(logger)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := copier.Rewrite(ctx, in); err != nil {
+		logger.Error("rewrite failed", "bucket", *bucket, "key", *key, "error", err)
+		os.Exit(1)
+	}
+	logger.Info("rewrote", "bucket", *bucket, "key", *key)
+}
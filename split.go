@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	s3cp "github.com/reedobrien/s3cp/lib"
+)
+
+// split copies byte ranges of an object into pieces, e.g. to process it in
+// parallel, and writes a manifest of the pieces.
+func split(args []string) {
+	fs := flag.NewFlagSet("split", flag.ExitOnError)
+	concurrency := fs.Int("concurrency", s3cp.DefaultObjectConcurrency, "How many pieces to copy at once.")
+	dest := fs.String("dest", "", "The destination bucket and key prefix of the pieces, as bucket/prefix or s3://bucket/prefix.")
+	expectedBucketOwner := fs.String("expectedBucketOwner", "", "If set, the account ID that must own the destination bucket.")
+	expectedSourceBucketOwner := fs.String("expectedSourceBucketOwner", "", "If set, the account ID that must own the source bucket.")
+	lines := fs.Bool("lines", false, "Set to true to cut after newlines, so no line is split.")
+	manifest := fs.String("manifest", "-", "The file to write the JSON manifest of the pieces to, or - for stdout.")
+	pieceSize := fs.Int64("pieceSize", 0, "The size of each piece in bytes. Set this or pieces.")
+	pieces := fs.Int("pieces", 0, "How many pieces of about the same size to make. Set this or pieceSize.")
+	source := fs.String("source", "", "The object to split, as bucket/key or s3://bucket/key.")
+	client := addClientFlags(fs)
+	logs := addLogFlags(fs)
+	sse := addEncryptionFlags(fs)
+	fs.Parse(args)
+
+	logger, err := logs.logger()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *source == "" || *dest == "" {
+		log.Fatal("split requires a source and dest")
+	}
+
+	in := s3cp.SplitInput{
+		Pieces:      *pieces,
+		PieceSize:   *pieceSize,
+		Lines:       *lines,
+		Concurrency: *concurrency,
+	}
+	in.Source, err = s3cp.ParseLocation(*source)
+	if err != nil {
+		log.Fatal(err)
+	}
+	in.Destination, err = s3cp.ParseLocation(*dest)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	in.Template.Encryption, in.Template.SourceEncryption, err = sse.encryption()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *expectedBucketOwner != "" {
+		in.Template.COI.ExpectedBucketOwner = expectedBucketOwner
+	}
+	if *expectedSourceBucketOwner != "" {
+		in.Template.COI.ExpectedSourceBucketOwner = expectedSourceBucketOwner
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	copier, err := client.copier(logger)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	m, err := copier.Split(ctx, in, func(r s3cp.ObjectResult) {
+		if r.Err != nil {
+			logger.Error("failed to copy piece", "key", r.Key, "error", r.Err)
+			return
+		}
+		logger.Info("copied piece", "key", r.Key)
+	})
+	if m != nil {
+		if werr := writeManifest(*manifest, m); werr != nil {
+			log.Fatal(werr)
+		}
+	}
+	if err != nil {
+		logger.Error("split failed", "source", *source, "error", err)
+		os.Exit(1)
+	}
+	logger.Info("split", "source", *source, "pieces", len(m.Pieces))
+}
+
+// writeManifest writes m as JSON to the file at path, or to stdout if path
+// is -.
+func writeManifest(path string, m *s3cp.SplitManifest) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	if path == "-" {
+		_, err = os.Stdout.Write(b)
+		return err
+	}
+	return os.WriteFile(path, b, 0o644)
+}
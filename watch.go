@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	s3cp "github.com/reedobrien/s3cp/lib"
+)
+
+// watch mirrors new objects under a prefix, found by listing it at an
+// interval or from S3 event notifications, until it is interrupted.
+func watch(args []string) {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	concurrency := fs.Int("concurrency", s3cp.DefaultObjectConcurrency, "How many objects to copy at once.")
+	cursor := fs.String("cursor", "", "If set, the file the watch's progress is kept in, so a restart resumes from it.")
+	dest := fs.String("dest", "", "The destination bucket and key prefix, as bucket/prefix or s3://bucket/prefix.")
+	events := fs.String("events", "", "If set, copy the objects in the S3 event notifications read from this file, or - for stdin, instead of listing.")
+	expectedBucketOwner := fs.String("expectedBucketOwner", "", "If set, the account ID that must own the destination bucket.")
+	expectedSourceBucketOwner := fs.String("expectedSourceBucketOwner", "", "If set, the account ID that must own the source bucket.")
+	interval := fs.Duration("interval", s3cp.DefaultWatchInterval, "How often to list the source.")
+	listen := fs.String("listen", "", "If set, copy the objects in the S3 event notifications POSTed to this address instead of listing.")
+	maxAttempts := fs.Int("maxAttempts", s3cp.DefaultWatchAttempts, "How many polls to try copying a key in before giving up on it and recording it in the cursor.")
+	source := fs.String("source", "", "The bucket and key prefix to watch, as bucket/prefix or s3://bucket/prefix.")
+	client := addClientFlags(fs)
+	logs := addLogFlags(fs)
+	sse := addEncryptionFlags(fs)
+	fs.Parse(args)
+
+	logger, err := logs.logger()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *source == "" || *dest == "" {
+		log.Fatal("watch requires a source and dest")
+	}
+	if *events != "" && *listen != "" {
+		log.Fatal("watch takes events or listen, not both")
+	}
+
+	in := s3cp.WatchInput{
+		Interval:    *interval,
+		Concurrency: *concurrency,
+		MaxAttempts: *maxAttempts,
+	}
+	in.Source, err = s3cp.ParseLocation(*source)
+	if err != nil {
+		log.Fatal(err)
+	}
+	in.Destination, err = s3cp.ParseLocation(*dest)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *cursor != "" {
+		in.Cursor, err = readCursor(*cursor)
+		if err != nil {
+			log.Fatal(err)
+		}
+		in.Checkpoint = func(c s3cp.WatchCursor) error { return writeCursor(*cursor, c) }
+	}
+
+	in.Template.Encryption, in.Template.SourceEncryption, err = sse.encryption()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *expectedBucketOwner != "" {
+		in.Template.COI.ExpectedBucketOwner = expectedBucketOwner
+	}
+	if *expectedSourceBucketOwner != "" {
+		in.Template.COI.ExpectedSourceBucketOwner = expectedSourceBucketOwner
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	copier, err := client.copier(logger)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	progress := func(r s3cp.ObjectResult) {
+		switch {
+		case r.Err != nil:
+			logger.Error("failed to copy", "key", r.Key, "error", r.Err)
+		case r.Skipped:
+			logger.Debug("skipped", "key", r.Key)
+		default:
+			logger.Info("copied", "key", r.Key)
+		}
+	}
+
+	switch {
+	case *listen != "":
+		var h http.Handler
+		h, err = copier.EventHandler(ctx, in, progress)
+		if err != nil {
+			break
+		}
+		srv := &http.Server{Addr: *listen, Handler: h}
+		go func() {
+			<-ctx.Done()
+			srv.Shutdown(context.Background())
+		}()
+		logger.Info("listening for events", "addr", *listen)
+		if err = srv.ListenAndServe(); errors.Is(err, http.ErrServerClosed) {
+			err = nil
+		}
+	case *events != "":
+		var r io.Reader = os.Stdin
+		if *events != "-" {
+			f, ferr := os.Open(*events)
+			if ferr != nil {
+				log.Fatal(ferr)
+			}
+			defer f.Close()
+			r = f
+		}
+		err = copier.WatchEvents(ctx, in, r, progress)
+	default:
+		err = copier.Watch(ctx, in, progress)
+	}
+	if err != nil && ctx.Err() == nil {
+		logger.Error("watch failed", "source", *source, "error", err)
+		os.Exit(1)
+	}
+}
+
+// readCursor reads the watch cursor kept in the file at path, or returns an
+// empty one if there is none yet.
+func readCursor(path string) (*s3cp.WatchCursor, error) {
+	c := &s3cp.WatchCursor{}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c, json.Unmarshal(b, c)
+}
+
+// writeCursor replaces the file at path with c as JSON. It writes a
+// temporary file and renames it, so a crash leaves the old or new cursor.
+func writeCursor(path string, c s3cp.WatchCursor) error {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}